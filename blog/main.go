@@ -1,15 +1,22 @@
 package main
 
 import (
+	"crypto/sha256"
+	"encoding/csv"
 	"fmt"
 	"github.com/Psychopath-H/psyweb-master/psygo"
 	psyLog "github.com/Psychopath-H/psyweb-master/psygo/logger"
 	"github.com/Psychopath-H/psyweb-master/psygo/pool"
 	"github.com/Psychopath-H/psyweb-master/psygo/psyerror"
+	"github.com/Psychopath-H/psyweb-master/psygo/resumable"
 	"github.com/Psychopath-H/psyweb-master/psygo/token"
 	"html/template"
+	"io"
 	"log"
 	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
 	"sync"
 	"time"
 )
@@ -151,22 +158,57 @@ func main() {
 			//ctx.FileAttachment("template/MyExcelTest.xlsx", "h.xlsx")
 			c.FileFromFS("MyExcelTest.xlsx", http.Dir("template"))
 		})
+		v2.GET("/ExportStudents", func(c *psygo.Context) {
+			// expect http://localhost:9999/v2/ExportStudents 边生成边下载，不需要先把整个文件写到磁盘或内存里
+			pr, pw := io.Pipe()
+			go func() {
+				w := csv.NewWriter(pw)
+				_ = w.Write([]string{"name", "age"})
+				for _, stu := range []*student{stu1, stu2} {
+					_ = w.Write([]string{stu.Name, strconv.Itoa(int(stu.Age))})
+				}
+				w.Flush()
+				_ = pw.CloseWithError(w.Error())
+			}()
+			c.DataFromReader(http.StatusOK, -1, "text/csv; charset=utf-8", pr, map[string]string{
+				"Content-Disposition": psygo.ContentDisposition("attachment", "学生名单.csv"),
+			})
+			//客户端中途断开时DataFromReader提前返回，关闭读端让上面的goroutine写入失败后退出，否则它会一直阻塞
+			_ = pr.Close()
+		})
 		v2.POST("/PostFiles", func(c *psygo.Context) {
 			// expect http://localhost:9999/v2/PostFiles POST的Body里有文件数据
-			//file := c.FormFile("file") 单个
-			files := c.FormFiles("file") //多个
-			for _, file := range files {
-				err := c.SaveUploadedFile(file, "./upload/"+file.Filename)
-				if err != nil {
-					log.Println(err)
-					return
-				}
+			//流式读取，大文件不会先缓存到内存或临时文件中，文件以随机的名字保存，不同用户上传的同名文件不会互相覆盖
+			files, _, err := c.SaveUploads("./upload", psygo.UploadOptions{
+				MaxFileSize:  4 << 30, //单个文件最大4G
+				MaxFiles:     10,
+				AllowedTypes: []string{"video/*", "image/*", "application/octet-stream"},
+				Hash:         sha256.New,
+			})
+			if err != nil {
+				c.Problem(err)
+				return
 			}
 			c.JSON(http.StatusOK, psygo.H{
 				"status": "ok",
 				"files":  files,
 			})
 		})
+		// 断点续传 expect http://localhost:9999/v2/uploads 网络中断后客户端HEAD得到已上传的偏移量，再PATCH剩下的部分
+		partial, err := resumable.NewFileStore("./upload/.partial")
+		if err != nil {
+			log.Fatal(err)
+		}
+		resumable.Mount(v2.Group("/uploads"), partial,
+			resumable.WithMaxSize(4<<30),
+			resumable.WithCleanupInterval(time.Hour),
+			resumable.WithOnComplete(func(c *psygo.Context, info *resumable.Info) error {
+				//用上传的ID作为文件名，不使用客户端提供的filename拼接路径
+				if err := os.Rename(partial.Path(info.ID), filepath.Join("./upload", info.ID)); err != nil {
+					return err
+				}
+				return partial.Delete(info.ID)
+			}))
 	}
 
 	//v3分组，参数处理
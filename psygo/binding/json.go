@@ -66,7 +66,9 @@ func validateParam(obj any, decoder *json.Decoder) error {
 			return checkParamSlice(elem, obj, decoder)
 		}
 	default:
-		_ = decoder.Decode(obj)
+		if err := decoder.Decode(obj); err != nil && err != io.EOF {
+			return err
+		}
 	}
 	return nil
 }
@@ -76,7 +78,9 @@ func checkParam(valueOf reflect.Value, obj any, decoder *json.Decoder) error {
 	//解析为map，然后根据map中的key进行对比
 	//判断类型为结构体才能解析为参数
 	mapValue := make(map[string]interface{})
-	_ = decoder.Decode(&mapValue) //将post传递过来的json参数解析到mapValue结构体中
+	if err := decoder.Decode(&mapValue); err != nil && err != io.EOF { //将post传递过来的json参数解析到mapValue结构体中
+		return err
+	}
 	if len(mapValue) <= 0 {
 		return nil
 	}
@@ -104,7 +108,9 @@ func checkParamSlice(valueType reflect.Type, obj any, decoder *json.Decoder) err
 	//解析为map，然后根据map中的key进行对比
 	//判断类型为结构体才能解析为参数
 	mapValue := make([]map[string]interface{}, 0)
-	_ = decoder.Decode(&mapValue)
+	if err := decoder.Decode(&mapValue); err != nil && err != io.EOF {
+		return err
+	}
 	if len(mapValue) <= 0 {
 		return nil
 	}
@@ -0,0 +1,475 @@
+package psygo
+
+import (
+	"container/list"
+	"context"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultCacheMaxBytes 是内存缓存推荐的最大占用字节数，可以直接传给NewMemoryCacheStore
+const DefaultCacheMaxBytes = 64 << 20
+
+// CacheStatusHeader 响应头中标明缓存命中情况的头部，值为HIT、MISS或STALE
+const CacheStatusHeader = "X-Cache"
+
+// CachedResponse 是缓存的一个完整响应，存入存储之后不再修改，多个请求会同时读取它
+type CachedResponse struct {
+	Status     int
+	Header     http.Header
+	Body       []byte
+	Tags       []string  //用于按标签批量清除
+	StoredAt   time.Time //生成的时间，用来计算Age头部
+	ExpireAt   time.Time //在这之前是新鲜的
+	StaleUntil time.Time //过期之后在这之前仍然可以先返回旧的响应，同时在后台重新生成，存储可以在这之后删除它
+}
+
+// CacheStore 响应缓存的存储，实现了这个接口就可以把缓存放到redis等外部存储中
+type CacheStore interface {
+	// Get 返回key对应的响应，超过StaleUntil的响应视为不存在
+	Get(key string) (*CachedResponse, bool)
+	// Set 保存key对应的响应，已经存在时覆盖
+	Set(key string, resp *CachedResponse)
+	// Delete 删除key对应的响应，返回它是否存在
+	Delete(key string) bool
+	// DeleteTag 删除所有带有tag标签的响应，返回删除的数量
+	DeleteTag(tag string) int
+}
+
+// CacheOptions 响应缓存中间件的配置
+type CacheOptions struct {
+	TTL                  time.Duration //响应保持新鲜的时间，默认1分钟
+	StaleWhileRevalidate time.Duration //过期之后仍然可以返回旧响应的时间，期间在后台重新生成，默认为0即不返回过期的响应
+	VaryHeaders          []string      //参与生成key的请求头，例如Accept-Language
+	KeyFunc              func(c *Context) string
+	Tags                 func(c *Context) []string //处理链结束后调用，返回这个响应的标签
+	Store                CacheStore                //必须设置，清除缓存时也要通过它
+	// AllowCredentials 为false(默认)时，带有Authorization或者Cookie请求头的请求不使用缓存，
+	// 避免把某个用户的响应返回给其他人；列在VaryHeaders中的请求头会参与生成key，不受这个限制。
+	// KeyFunc已经按照用户区分了缓存时可以设置为true
+	AllowCredentials bool
+}
+
+// Cache 返回一个缓存完整响应(状态码、头部和响应体)的中间件，适合对所有用户都相同且生成代价较高的GET和HEAD接口。
+// 默认的key由方法、主机名、路径、排好序的查询参数和VaryHeaders中的请求头组成，例如 GET api.example.com/goods?page=1&size=10 ，
+// 不同主机上的同名路径可能是不同的路由，所以它们的缓存是分开的。
+// 清除缓存时可以直接用这个key调用Store.Delete，或者用Tags返回的标签调用Store.DeleteTag。
+// 同一个key同时有多个请求没有命中时只有一个请求执行处理链，其它请求等待它的结果。
+// 只缓存200响应，带有Set-Cookie或者Cache-Control: no-store/private的响应不会被缓存，调用了Flush的流式响应也不会；
+// 带有认证信息的请求默认直接执行处理链，见CacheOptions.AllowCredentials
+func Cache(opts CacheOptions) HandlerFunc {
+	//调用方自己创建存储，才能在数据变化时拿它来清除缓存
+	assert1(opts.Store != nil, "Cache: Store must be set, for example NewMemoryCacheStore(DefaultCacheMaxBytes)")
+	return newResponseCache(opts).handle
+}
+
+// responseCache 记录每个key正在进行中的生成，合并同时发生的未命中
+type responseCache struct {
+	opts              CacheOptions
+	credentialHeaders []string         //带有这些请求头的请求不使用缓存
+	now               func() time.Time //判断是否过期时使用的时钟
+	mu                sync.Mutex
+	calls             map[string]*cacheCall
+}
+
+func newResponseCache(opts CacheOptions) *responseCache {
+	if opts.TTL <= 0 {
+		opts.TTL = time.Minute
+	}
+	if opts.KeyFunc == nil {
+		varyHeaders := opts.VaryHeaders
+		opts.KeyFunc = func(c *Context) string {
+			return cacheKey(c, varyHeaders)
+		}
+	}
+	rc := &responseCache{opts: opts, now: time.Now, calls: make(map[string]*cacheCall)}
+	if !opts.AllowCredentials {
+		for _, header := range []string{"Authorization", "Cookie"} {
+			if !containsHeader(opts.VaryHeaders, header) {
+				rc.credentialHeaders = append(rc.credentialHeaders, header)
+			}
+		}
+	}
+	return rc
+}
+
+// containsHeader 判断headers中是否有name，不区分大小写
+func containsHeader(headers []string, name string) bool {
+	for _, h := range headers {
+		if strings.EqualFold(h, name) {
+			return true
+		}
+	}
+	return false
+}
+
+func (rc *responseCache) handle(c *Context) {
+	if c.Req.Method != http.MethodGet && c.Req.Method != http.MethodHead {
+		c.Next()
+		return
+	}
+	for _, header := range rc.credentialHeaders {
+		if c.requestHeader(header) != "" { //可能是某个用户私有的响应，既不读也不写缓存
+			c.Next()
+			return
+		}
+	}
+	key := rc.opts.KeyFunc(c)
+	if key == "" {
+		c.Next()
+		return
+	}
+	if resp, ok := rc.opts.Store.Get(key); ok {
+		if rc.now().Before(resp.ExpireAt) {
+			writeCachedResponse(c, resp, "HIT", rc.now())
+			return
+		}
+		rc.revalidate(c, key) //在写出旧的响应之前拷贝Context，之后c就被中止了
+		writeCachedResponse(c, resp, "STALE", rc.now())
+		return
+	}
+	rc.fill(c, key)
+}
+
+// cacheCall 是一次正在进行的生成，done关闭后resp为nil表示结果不能缓存
+type cacheCall struct {
+	done chan struct{}
+	resp *CachedResponse
+}
+
+// fill 处理没有命中的请求：第一个请求执行处理链并把结果存入缓存，其它请求等待它完成后直接使用结果
+func (rc *responseCache) fill(c *Context, key string) {
+	rc.mu.Lock()
+	if call, ok := rc.calls[key]; ok {
+		rc.mu.Unlock()
+		select {
+		case <-call.done:
+			if call.resp != nil {
+				writeCachedResponse(c, call.resp, "HIT", rc.now())
+				return
+			}
+		case <-c.Req.Context().Done():
+			c.Abort()
+			return
+		}
+		//结果不能缓存(例如返回了错误)，自己执行处理链
+		c.Next()
+		return
+	}
+	//查询存储和拿到锁之间，上一次生成可能刚好完成，再检查一次，避免重复执行处理链
+	if resp, ok := rc.opts.Store.Get(key); ok && rc.now().Before(resp.ExpireAt) {
+		rc.mu.Unlock()
+		writeCachedResponse(c, resp, "HIT", rc.now())
+		return
+	}
+	call := &cacheCall{done: make(chan struct{})}
+	rc.calls[key] = call
+	rc.mu.Unlock()
+
+	defer rc.finish(key, call) //处理链panic时也要唤醒等待的请求
+	c.Header(CacheStatusHeader, "MISS")
+	w := newCacheWriter(c.Writer)
+	c.Writer = w
+	defer func() { c.Writer = w.ResponseWriter }()
+	c.Next()
+	call.resp = rc.store(c, key, w)
+}
+
+// revalidate 在后台用当前请求的副本重新执行处理链，刷新过期的缓存，同一个key同时只有一个
+func (rc *responseCache) revalidate(c *Context, key string) {
+	rc.mu.Lock()
+	if _, ok := rc.calls[key]; ok {
+		rc.mu.Unlock()
+		return
+	}
+	call := &cacheCall{done: make(chan struct{})}
+	rc.calls[key] = call
+	rc.mu.Unlock()
+
+	w := newCacheWriter(&nopResponseWriter{header: make(http.Header)})
+	cp := c.copyWithWriter(w)
+	cp.Req = c.Req.WithContext(context.WithoutCancel(c.Req.Context())) //客户端拿到旧的响应就会断开，不能取消后台的生成
+	go func() {
+		defer rc.finish(key, call)
+		defer func() {
+			if p := recover(); p != nil {
+				log.Printf("psygo: panic while revalidating cache %q: %v\n", key, p)
+			}
+		}()
+		cp.Next()
+		call.resp = rc.store(cp, key, w)
+	}()
+}
+
+func (rc *responseCache) finish(key string, call *cacheCall) {
+	rc.mu.Lock()
+	delete(rc.calls, key)
+	rc.mu.Unlock()
+	close(call.done)
+}
+
+// store 把处理链写出的响应存入缓存，不能缓存时返回nil
+func (rc *responseCache) store(c *Context, key string, w *cacheWriter) *CachedResponse {
+	if w.status != http.StatusOK || w.streaming || len(c.Errors) > 0 {
+		return nil
+	}
+	header := w.header()
+	if header.Get("Set-Cookie") != "" {
+		return nil
+	}
+	for _, directive := range strings.Split(header.Get("Cache-Control"), ",") {
+		switch strings.ToLower(strings.TrimSpace(directive)) {
+		case "no-store", "private":
+			return nil
+		}
+	}
+	header.Del(CacheStatusHeader)
+	now := rc.now()
+	resp := &CachedResponse{
+		Status:     w.status,
+		Header:     header,
+		Body:       w.buf,
+		StoredAt:   now,
+		ExpireAt:   now.Add(rc.opts.TTL),
+		StaleUntil: now.Add(rc.opts.TTL + rc.opts.StaleWhileRevalidate),
+	}
+	if rc.opts.Tags != nil {
+		resp.Tags = rc.opts.Tags(c)
+	}
+	rc.opts.Store.Set(key, resp)
+	return resp
+}
+
+// cacheKey 生成默认的缓存key：方法、主机名、路径和排好序的查询参数，之后每个请求头占一行
+func cacheKey(c *Context, varyHeaders []string) string {
+	var b strings.Builder
+	b.WriteString(c.Req.Method)
+	b.WriteByte(' ')
+	b.WriteString(hostname(c.Req.Host))
+	b.WriteString(c.Req.URL.Path)
+	if query := c.Req.URL.Query(); len(query) > 0 {
+		b.WriteByte('?')
+		b.WriteString(query.Encode()) //Encode按照参数名排序
+	}
+	for _, name := range varyHeaders {
+		b.WriteByte('\n')
+		b.WriteString(http.CanonicalHeaderKey(name))
+		b.WriteString(": ")
+		b.WriteString(c.requestHeader(name))
+	}
+	return b.String()
+}
+
+// writeCachedResponse 把缓存的响应写给客户端，cacheStatus写在X-Cache头部中
+func writeCachedResponse(c *Context, resp *CachedResponse, cacheStatus string, now time.Time) {
+	header := c.Writer.Header()
+	for k, v := range resp.Header {
+		header[k] = append([]string(nil), v...)
+	}
+	header.Set(CacheStatusHeader, cacheStatus)
+	header.Set("Age", strconv.Itoa(int(now.Sub(resp.StoredAt).Seconds())))
+	c.StatusCode = resp.Status
+	c.Writer.WriteHeader(resp.Status)
+	if c.Req.Method != http.MethodHead {
+		_, _ = c.Writer.Write(resp.Body)
+	}
+	c.Abort()
+}
+
+// cacheWriter 在写出响应的同时记录状态码、处理链新增的头部和响应体
+type cacheWriter struct {
+	http.ResponseWriter
+	before    http.Header //处理链开始前已经存在的头部，例如前面的中间件设置的X-Request-ID，不属于缓存的响应
+	status    int
+	buf       []byte
+	streaming bool
+}
+
+func newCacheWriter(w http.ResponseWriter) *cacheWriter {
+	return &cacheWriter{ResponseWriter: w, before: w.Header().Clone()}
+}
+
+func (w *cacheWriter) WriteHeader(code int) {
+	if w.status == 0 {
+		w.status = code
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *cacheWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	if !w.streaming {
+		w.buf = append(w.buf, b...)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// Flush 实现了http.Flusher，流式响应不缓存
+func (w *cacheWriter) Flush() {
+	w.streaming = true
+	w.buf = nil
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap 供http.ResponseController找到原始的ResponseWriter
+func (w *cacheWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// header 返回处理链新增或者修改过的头部
+func (w *cacheWriter) header() http.Header {
+	header := make(http.Header)
+	for k, v := range w.ResponseWriter.Header() {
+		if old, ok := w.before[k]; ok && strings.Join(old, "\n") == strings.Join(v, "\n") {
+			continue
+		}
+		header[k] = append([]string(nil), v...)
+	}
+	return header
+}
+
+// nopResponseWriter 后台重新生成缓存时使用，丢弃所有写入
+type nopResponseWriter struct {
+	header http.Header
+}
+
+func (w *nopResponseWriter) Header() http.Header         { return w.header }
+func (w *nopResponseWriter) Write(b []byte) (int, error) { return len(b), nil }
+func (w *nopResponseWriter) WriteHeader(int)             {}
+
+// MemoryCacheStore 是基于内存的响应缓存，占用超过maxBytes时淘汰最久没有使用的响应
+type MemoryCacheStore struct {
+	mu       sync.Mutex
+	maxBytes int64
+	size     int64
+	lru      *list.List //最近使用的在前面
+	entries  map[string]*list.Element
+	tags     map[string]map[string]struct{}
+}
+
+type memoryCacheEntry struct {
+	key  string
+	resp *CachedResponse
+	size int64
+}
+
+// NewMemoryCacheStore 新建一个内存缓存，maxBytes是响应体、头部和key合计最多占用的字节数
+func NewMemoryCacheStore(maxBytes int64) *MemoryCacheStore {
+	assert1(maxBytes > 0, "NewMemoryCacheStore: maxBytes must be greater than 0")
+	return &MemoryCacheStore{
+		maxBytes: maxBytes,
+		lru:      list.New(),
+		entries:  make(map[string]*list.Element),
+		tags:     make(map[string]map[string]struct{}),
+	}
+}
+
+// Get 实现了CacheStore接口
+func (s *MemoryCacheStore) Get(key string) (*CachedResponse, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	elem, ok := s.entries[key]
+	if !ok {
+		return nil, false
+	}
+	entry := elem.Value.(*memoryCacheEntry)
+	if !time.Now().Before(entry.resp.StaleUntil) {
+		s.remove(elem)
+		return nil, false
+	}
+	s.lru.MoveToFront(elem)
+	return entry.resp, true
+}
+
+// Set 实现了CacheStore接口，单个响应超过maxBytes时不保存
+func (s *MemoryCacheStore) Set(key string, resp *CachedResponse) {
+	size := int64(len(key) + len(resp.Body))
+	for k, v := range resp.Header {
+		size += int64(len(k))
+		for _, value := range v {
+			size += int64(len(value))
+		}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if elem, ok := s.entries[key]; ok {
+		s.remove(elem)
+	}
+	if size > s.maxBytes {
+		return
+	}
+	s.entries[key] = s.lru.PushFront(&memoryCacheEntry{key: key, resp: resp, size: size})
+	s.size += size
+	for _, tag := range resp.Tags {
+		if s.tags[tag] == nil {
+			s.tags[tag] = make(map[string]struct{})
+		}
+		s.tags[tag][key] = struct{}{}
+	}
+	for s.size > s.maxBytes {
+		s.remove(s.lru.Back())
+	}
+}
+
+// Delete 实现了CacheStore接口
+func (s *MemoryCacheStore) Delete(key string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	elem, ok := s.entries[key]
+	if ok {
+		s.remove(elem)
+	}
+	return ok
+}
+
+// DeleteTag 实现了CacheStore接口
+func (s *MemoryCacheStore) DeleteTag(tag string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := 0
+	for key := range s.tags[tag] {
+		s.remove(s.entries[key])
+		n++
+	}
+	return n
+}
+
+// Len 返回当前缓存的响应数量
+func (s *MemoryCacheStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.entries)
+}
+
+// Size 返回当前缓存占用的字节数
+func (s *MemoryCacheStore) Size() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.size
+}
+
+// remove 删除一个响应，同时从它的标签中去掉它，调用时需要持有s.mu
+func (s *MemoryCacheStore) remove(elem *list.Element) {
+	entry := s.lru.Remove(elem).(*memoryCacheEntry)
+	delete(s.entries, entry.key)
+	s.size -= entry.size
+	for _, tag := range entry.resp.Tags {
+		if keys := s.tags[tag]; keys != nil {
+			delete(keys, entry.key)
+			if len(keys) == 0 {
+				delete(s.tags, tag)
+			}
+		}
+	}
+}
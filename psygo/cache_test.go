@@ -0,0 +1,277 @@
+package psygo
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCache(t *testing.T) {
+	var mu sync.Mutex
+	calls := map[string]int{}
+	count := func(name string) int {
+		mu.Lock()
+		defer mu.Unlock()
+		calls[name]++
+		return calls[name]
+	}
+	store := NewMemoryCacheStore(1 << 20)
+	engine := New()
+	engine.Use(func(c *Context) {
+		c.Header(RequestIDHeader, fmt.Sprint(time.Now().UnixNano()))
+		c.Next()
+	})
+	cached := engine.Group("")
+	cached.Use(Cache(CacheOptions{
+		TTL:         time.Hour,
+		VaryHeaders: []string{"Accept-Language"},
+		Tags: func(c *Context) []string {
+			return []string{"goods"}
+		},
+		Store: store,
+	}))
+	cached.GET("/goods", func(c *Context) {
+		n := count("goods")
+		c.Header("X-Version", fmt.Sprint(n))
+		c.String(http.StatusOK, "goods %s %s v%d", c.GetQuery("page"), c.Req.Header.Get("Accept-Language"), n)
+	})
+	cached.GET("/private", func(c *Context) {
+		c.Header("Cache-Control", "private")
+		c.String(http.StatusOK, "private %d", count("private"))
+	})
+	cached.GET("/broken", func(c *Context) {
+		c.String(http.StatusInternalServerError, "broken %d", count("broken"))
+	})
+
+	first := performRequest(engine, http.MethodGet, "/goods?size=10&page=1", nil)
+	if first.Code != http.StatusOK || first.Header().Get(CacheStatusHeader) != "MISS" || first.Body.String() != "goods 1  v1" {
+		t.Fatalf("status = %d, cache = %q, body = %q", first.Code, first.Header().Get(CacheStatusHeader), first.Body.String())
+	}
+	//查询参数顺序不同也命中同一个缓存，前面的中间件设置的头部不会被缓存
+	second := performRequest(engine, http.MethodGet, "/goods?page=1&size=10", nil)
+	if second.Header().Get(CacheStatusHeader) != "HIT" || second.Body.String() != "goods 1  v1" {
+		t.Fatalf("cache = %q, body = %q", second.Header().Get(CacheStatusHeader), second.Body.String())
+	}
+	if second.Header().Get("X-Version") != "1" || second.Header().Get("Content-Type") != "text/plain; charset=utf-8" {
+		t.Fatalf("cached headers = %v", second.Header())
+	}
+	if first.Header().Get(RequestIDHeader) == second.Header().Get(RequestIDHeader) {
+		t.Fatalf("request id was cached")
+	}
+	w := performRequest(engine, http.MethodGet, "/goods?page=1&size=10", nil, "Accept-Language", "zh")
+	if w.Header().Get(CacheStatusHeader) != "MISS" || w.Body.String() != "goods 1 zh v2" {
+		t.Fatalf("cache = %q, body = %q", w.Header().Get(CacheStatusHeader), w.Body.String())
+	}
+
+	for _, want := range []string{"private 1", "private 2"} {
+		if w := performRequest(engine, http.MethodGet, "/private", nil); w.Body.String() != want {
+			t.Fatalf("body = %q, want %q", w.Body.String(), want)
+		}
+	}
+	for _, want := range []string{"broken 1", "broken 2"} {
+		w := performRequest(engine, http.MethodGet, "/broken", nil)
+		if w.Code != http.StatusInternalServerError || w.Body.String() != want {
+			t.Fatalf("status = %d, body = %q, want %q", w.Code, w.Body.String(), want)
+		}
+	}
+
+	if !store.Delete("GET example.com/goods?page=1&size=10\nAccept-Language: ") {
+		t.Fatalf("delete by key failed")
+	}
+	w = performRequest(engine, http.MethodGet, "/goods?page=1&size=10", nil)
+	if w.Header().Get(CacheStatusHeader) != "MISS" || w.Body.String() != "goods 1  v3" {
+		t.Fatalf("cache = %q, body = %q", w.Header().Get(CacheStatusHeader), w.Body.String())
+	}
+	if n := store.DeleteTag("goods"); n != 2 {
+		t.Fatalf("DeleteTag removed %d entries, want 2", n)
+	}
+	if store.Len() != 0 || store.Size() != 0 {
+		t.Fatalf("store len = %d, size = %d", store.Len(), store.Size())
+	}
+}
+
+func TestCacheSeparatesHosts(t *testing.T) {
+	engine := New()
+	engine.Use(Cache(CacheOptions{TTL: time.Hour, Store: NewMemoryCacheStore(1 << 20)}))
+	engine.Host("api.example.com").GET("/", func(c *Context) {
+		c.String(http.StatusOK, "api")
+	})
+	engine.Host("admin.example.com").GET("/", func(c *Context) {
+		c.String(http.StatusOK, "admin")
+	})
+
+	for _, host := range []string{"api.example.com", "admin.example.com", "API.example.com:8080"} {
+		want := strings.ToLower(strings.Split(host, ".")[0])
+		for i := 0; i < 2; i++ {
+			if w := performRequest(engine, http.MethodGet, "/", nil, "Host", host); w.Body.String() != want {
+				t.Fatalf("%s: body = %q, want %q", host, w.Body.String(), want)
+			}
+		}
+	}
+}
+
+func TestCacheCredentials(t *testing.T) {
+	newEngine := func(opts CacheOptions) *Engine {
+		opts.TTL, opts.Store = time.Hour, NewMemoryCacheStore(1<<20)
+		engine := New()
+		engine.Use(Cache(opts))
+		engine.GET("/me", func(c *Context) {
+			user := "anonymous"
+			if cookie, err := c.Cookie("session"); err == nil {
+				user = cookie
+			}
+			if auth := c.Req.Header.Get("Authorization"); auth != "" {
+				user = auth
+			}
+			c.String(http.StatusOK, "%s", user)
+		})
+		return engine
+	}
+
+	//默认情况下带有认证信息的请求既不读也不写缓存
+	engine := newEngine(CacheOptions{})
+	performRequest(engine, http.MethodGet, "/me", nil)
+	for _, tt := range []struct{ header, value, want string }{
+		{"Authorization", "Bearer alice", "Bearer alice"},
+		{"Cookie", "session=bob", "bob"},
+		{"Authorization", "Bearer carol", "Bearer carol"},
+	} {
+		w := performRequest(engine, http.MethodGet, "/me", nil, tt.header, tt.value)
+		if w.Body.String() != tt.want || w.Header().Get(CacheStatusHeader) != "" {
+			t.Fatalf("%s: body = %q, cache = %q", tt.value, w.Body.String(), w.Header().Get(CacheStatusHeader))
+		}
+	}
+	if w := performRequest(engine, http.MethodGet, "/me", nil); w.Body.String() != "anonymous" || w.Header().Get(CacheStatusHeader) != "HIT" {
+		t.Fatalf("anonymous: body = %q, cache = %q", w.Body.String(), w.Header().Get(CacheStatusHeader))
+	}
+
+	//Cookie参与生成key时按照Cookie分别缓存
+	engine = newEngine(CacheOptions{VaryHeaders: []string{"cookie"}})
+	for _, session := range []string{"alice", "bob", "alice"} {
+		if w := performRequest(engine, http.MethodGet, "/me", nil, "Cookie", "session="+session); w.Body.String() != session {
+			t.Fatalf("vary cookie: body = %q, want %q", w.Body.String(), session)
+		}
+	}
+	if w := performRequest(engine, http.MethodGet, "/me", nil, "Authorization", "Bearer dave"); w.Header().Get(CacheStatusHeader) != "" {
+		t.Fatalf("Authorization not in VaryHeaders should still bypass the cache")
+	}
+
+	//AllowCredentials为true时带有认证信息的请求也使用缓存
+	engine = newEngine(CacheOptions{AllowCredentials: true})
+	performRequest(engine, http.MethodGet, "/me", nil, "Authorization", "Bearer alice")
+	if w := performRequest(engine, http.MethodGet, "/me", nil, "Authorization", "Bearer eve"); w.Body.String() != "Bearer alice" || w.Header().Get(CacheStatusHeader) != "HIT" {
+		t.Fatalf("AllowCredentials: body = %q, cache = %q", w.Body.String(), w.Header().Get(CacheStatusHeader))
+	}
+}
+
+// signalCacheStore 在没有命中和保存响应时发出通知，让测试不依赖sleep
+type signalCacheStore struct {
+	CacheStore
+	misses chan string
+	sets   chan string
+}
+
+func (s *signalCacheStore) Get(key string) (*CachedResponse, bool) {
+	resp, ok := s.CacheStore.Get(key)
+	if !ok {
+		s.misses <- key
+	}
+	return resp, ok
+}
+
+func (s *signalCacheStore) Set(key string, resp *CachedResponse) {
+	s.CacheStore.Set(key, resp)
+	s.sets <- key
+}
+
+func TestCacheCoalescingAndStale(t *testing.T) {
+	const requests = 10
+	store := &signalCacheStore{
+		CacheStore: NewMemoryCacheStore(1 << 20),
+		misses:     make(chan string, 2*requests),
+		sets:       make(chan string, requests),
+	}
+	var clock atomic.Int64
+	clock.Store(time.Now().UnixNano())
+	rc := newResponseCache(CacheOptions{TTL: time.Minute, StaleWhileRevalidate: time.Hour, Store: store})
+	rc.now = func() time.Time {
+		return time.Unix(0, clock.Load())
+	}
+	var computed atomic.Int32
+	release := make(chan struct{})
+	engine := New()
+	engine.Use(rc.handle)
+	engine.GET("/catalog", func(c *Context) {
+		n := computed.Add(1)
+		<-release
+		c.String(http.StatusOK, "catalog v%d", n)
+	})
+
+	//并发的未命中只执行一次处理链
+	var wg sync.WaitGroup
+	bodies := make([]string, requests)
+	for i := range bodies {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			bodies[i] = performRequest(engine, http.MethodGet, "/catalog", nil).Body.String()
+		}(i)
+	}
+	for i := 0; i < requests; i++ {
+		<-store.misses
+	}
+	close(release)
+	wg.Wait()
+	for _, body := range bodies {
+		if body != "catalog v1" {
+			t.Fatalf("body = %q", body)
+		}
+	}
+	if n := computed.Load(); n != 1 {
+		t.Fatalf("handler computed %d times, want 1", n)
+	}
+	<-store.sets
+
+	//过期之后先返回旧的响应，同时在后台重新生成
+	clock.Add(int64(2 * time.Minute))
+	w := performRequest(engine, http.MethodGet, "/catalog", nil)
+	if w.Header().Get(CacheStatusHeader) != "STALE" || w.Body.String() != "catalog v1" {
+		t.Fatalf("cache = %q, body = %q", w.Header().Get(CacheStatusHeader), w.Body.String())
+	}
+	<-store.sets
+	w = performRequest(engine, http.MethodGet, "/catalog", nil)
+	if w.Header().Get(CacheStatusHeader) != "HIT" || w.Body.String() != "catalog v2" {
+		t.Fatalf("cache = %q, body = %q", w.Header().Get(CacheStatusHeader), w.Body.String())
+	}
+}
+
+func TestMemoryCacheStoreLRU(t *testing.T) {
+	store := NewMemoryCacheStore(30)
+	resp := func(body string) *CachedResponse {
+		return &CachedResponse{Status: http.StatusOK, Body: []byte(body), StaleUntil: time.Now().Add(time.Hour)}
+	}
+	store.Set("a", resp("0123456789"))
+	store.Set("b", resp("0123456789"))
+	if _, ok := store.Get("a"); !ok { //a成为最近使用的
+		t.Fatalf("a missing")
+	}
+	store.Set("c", resp("0123456789"))
+	if _, ok := store.Get("b"); ok {
+		t.Fatalf("b should be evicted")
+	}
+	if _, ok := store.Get("a"); !ok {
+		t.Fatalf("a should be kept")
+	}
+	store.Set("d", resp(strings.Repeat("x", 40)))
+	if _, ok := store.Get("d"); ok {
+		t.Fatalf("oversized response should not be stored")
+	}
+	store.Set("e", &CachedResponse{StaleUntil: time.Now().Add(-time.Second)})
+	if _, ok := store.Get("e"); ok {
+		t.Fatalf("expired response should not be returned")
+	}
+}
@@ -0,0 +1,166 @@
+package psygo
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+const (
+	// PlatformCloudflare 部署在Cloudflare之后时，客户端IP所在的头部
+	PlatformCloudflare = "CF-Connecting-IP"
+	// PlatformGoogleAppEngine 部署在Google App Engine上时，客户端IP所在的头部
+	PlatformGoogleAppEngine = "X-Appengine-Remote-Addr"
+	// PlatformFlyIO 部署在Fly.io上时，客户端IP所在的头部
+	PlatformFlyIO = "Fly-Client-IP"
+)
+
+// defaultRemoteIPHeaders 直连的对端是可信代理时，依次从这些头部中寻找客户端IP
+var defaultRemoteIPHeaders = []string{"X-Forwarded-For", "X-Real-IP", "Forwarded"}
+
+// SetTrustedProxies 设置可信代理的IP或CIDR列表，例如 []string{"127.0.0.1", "10.0.0.0/8", "::1"}。
+// 只有直连的对端在这个列表中时，ClientIP才会去读取X-Forwarded-For等头部，否则这些头部可以被客户端随意伪造。
+// 传入nil表示不信任任何代理
+func (engine *Engine) SetTrustedProxies(trustedProxies []string) error {
+	cidrs, err := parseCIDRs(trustedProxies)
+	if err != nil {
+		return err
+	}
+	engine.trustedCIDRs = cidrs
+	return nil
+}
+
+// isTrustedProxy 判断ip是否是可信代理
+func (engine *Engine) isTrustedProxy(ip net.IP) bool {
+	for _, cidr := range engine.trustedCIDRs {
+		if cidr.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseCIDRs 把IP或CIDR字符串解析成网段，单个IP会被当作/32或/128的网段
+func parseCIDRs(values []string) ([]*net.IPNet, error) {
+	cidrs := make([]*net.IPNet, 0, len(values))
+	for _, value := range values {
+		value = strings.TrimSpace(value)
+		if value == "" {
+			continue
+		}
+		if !strings.Contains(value, "/") {
+			ip := net.ParseIP(value)
+			if ip == nil {
+				return nil, fmt.Errorf("psygo: invalid IP address %q", value)
+			}
+			bits := net.IPv6len * 8
+			if ip4 := ip.To4(); ip4 != nil {
+				ip = ip4
+				bits = net.IPv4len * 8
+			}
+			cidrs = append(cidrs, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, cidr, err := net.ParseCIDR(value)
+		if err != nil {
+			return nil, fmt.Errorf("psygo: invalid CIDR %q: %w", value, err)
+		}
+		cidrs = append(cidrs, cidr)
+	}
+	return cidrs, nil
+}
+
+// RemoteIP 返回直连的对端IP，也就是 Request.RemoteAddr 去掉端口之后的部分
+func (c *Context) RemoteIP() string {
+	ip, _, err := net.SplitHostPort(strings.TrimSpace(c.Req.RemoteAddr))
+	if err != nil {
+		return strings.TrimSpace(c.Req.RemoteAddr)
+	}
+	return ip
+}
+
+// ClientIP 返回客户端的真实IP。
+// 设置了Engine.TrustedPlatform时优先读取平台提供的头部；
+// 直连的对端是可信代理时，依次读取Engine.RemoteIPHeaders中的头部，从右往左跳过可信代理，第一个不可信的地址就是客户端IP；
+// 其余情况都返回RemoteIP
+func (c *Context) ClientIP() string {
+	engine := c.Engine
+	if engine.TrustedPlatform != "" {
+		if ip := parseIP(c.requestHeader(engine.TrustedPlatform)); ip != nil {
+			return ip.String()
+		}
+	}
+	remoteIP := c.RemoteIP()
+	ip := net.ParseIP(remoteIP)
+	if ip == nil || !engine.isTrustedProxy(ip) {
+		return remoteIP
+	}
+	headers := engine.RemoteIPHeaders
+	if headers == nil {
+		headers = defaultRemoteIPHeaders
+	}
+	for _, header := range headers {
+		var addrs []string
+		if http.CanonicalHeaderKey(header) == "Forwarded" {
+			addrs = parseForwarded(c.Req.Header.Values(header))
+		} else {
+			for _, value := range c.Req.Header.Values(header) {
+				addrs = append(addrs, strings.Split(value, ",")...)
+			}
+		}
+		if clientIP, ok := engine.clientIPFromChain(addrs); ok {
+			return clientIP
+		}
+	}
+	return remoteIP
+}
+
+// clientIPFromChain 从代理链的最右边开始往左找，跳过可信代理，返回第一个不可信的地址，
+// 链中有无法解析的地址时整条链都不可信
+func (engine *Engine) clientIPFromChain(addrs []string) (string, bool) {
+	var ip net.IP
+	for i := len(addrs) - 1; i >= 0; i-- {
+		ip = parseIP(addrs[i])
+		if ip == nil {
+			return "", false
+		}
+		if i == 0 || !engine.isTrustedProxy(ip) {
+			return ip.String(), true
+		}
+	}
+	return "", false
+}
+
+// parseForwarded 从RFC 7239的Forwarded头部中取出所有for=参数，例如
+// Forwarded: for=192.0.2.60;proto=http, for="[2001:db8:cafe::17]:4711"
+func parseForwarded(values []string) []string {
+	var addrs []string
+	for _, value := range values {
+		for _, element := range strings.Split(value, ",") {
+			for _, pair := range strings.Split(element, ";") {
+				key, val, ok := strings.Cut(strings.TrimSpace(pair), "=")
+				if !ok || !strings.EqualFold(key, "for") {
+					continue
+				}
+				addrs = append(addrs, strings.Trim(val, `"`))
+			}
+		}
+	}
+	return addrs
+}
+
+// parseIP 解析头部中的地址，兼容带端口和带方括号的IPv6写法
+func parseIP(addr string) net.IP {
+	addr = strings.TrimSpace(addr)
+	if addr == "" {
+		return nil
+	}
+	if ip := net.ParseIP(addr); ip != nil {
+		return ip
+	}
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		return net.ParseIP(host)
+	}
+	return net.ParseIP(strings.Trim(addr, "[]"))
+}
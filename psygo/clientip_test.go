@@ -0,0 +1,166 @@
+package psygo
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClientIP(t *testing.T) {
+	tests := []struct {
+		name       string
+		remoteAddr string
+		headers    map[string][]string
+		trusted    []string
+		platform   string
+		ipHeaders  []string
+		want       string
+	}{
+		{
+			name:       "no trusted proxies ignores spoofed headers",
+			remoteAddr: "203.0.113.9:5000",
+			headers:    map[string][]string{"X-Forwarded-For": {"1.2.3.4"}, "X-Real-IP": {"1.2.3.4"}},
+			want:       "203.0.113.9",
+		},
+		{
+			name:       "spoofed XFF from an untrusted peer",
+			remoteAddr: "203.0.113.9:5000",
+			headers:    map[string][]string{"X-Forwarded-For": {"1.2.3.4"}},
+			trusted:    []string{"10.0.0.0/8"},
+			want:       "203.0.113.9",
+		},
+		{
+			name:       "single trusted proxy",
+			remoteAddr: "10.0.0.1:5000",
+			headers:    map[string][]string{"X-Forwarded-For": {"1.2.3.4"}},
+			trusted:    []string{"10.0.0.0/8"},
+			want:       "1.2.3.4",
+		},
+		{
+			name:       "multi-hop chain stops at the first untrusted address",
+			remoteAddr: "10.0.0.1:5000",
+			headers:    map[string][]string{"X-Forwarded-For": {"198.51.100.7, 1.2.3.4, 10.0.0.2"}},
+			trusted:    []string{"10.0.0.0/8"},
+			want:       "1.2.3.4",
+		},
+		{
+			name:       "chain split over several header lines",
+			remoteAddr: "10.0.0.1:5000",
+			headers:    map[string][]string{"X-Forwarded-For": {"198.51.100.7", "1.2.3.4,10.0.0.2"}},
+			trusted:    []string{"10.0.0.0/8"},
+			want:       "1.2.3.4",
+		},
+		{
+			name:       "every hop trusted returns the leftmost",
+			remoteAddr: "10.0.0.1:5000",
+			headers:    map[string][]string{"X-Forwarded-For": {"10.0.0.3, 10.0.0.2"}},
+			trusted:    []string{"10.0.0.0/8"},
+			want:       "10.0.0.3",
+		},
+		{
+			name:       "unparsable entry distrusts the chain and falls back to X-Real-IP",
+			remoteAddr: "10.0.0.1:5000",
+			headers:    map[string][]string{"X-Forwarded-For": {"1.2.3.4, garbage"}, "X-Real-IP": {"5.6.7.8"}},
+			trusted:    []string{"10.0.0.0/8"},
+			want:       "5.6.7.8",
+		},
+		{
+			name:       "unparsable entry without other headers falls back to the peer",
+			remoteAddr: "10.0.0.1:5000",
+			headers:    map[string][]string{"X-Forwarded-For": {"1.2.3.4, , 10.0.0.2"}},
+			trusted:    []string{"10.0.0.0/8"},
+			want:       "10.0.0.1",
+		},
+		{
+			name:       "Forwarded with quoted IPv6 and port",
+			remoteAddr: "10.0.0.1:5000",
+			headers:    map[string][]string{"Forwarded": {`for="[2001:db8:cafe::17]:4711";proto=https`}},
+			trusted:    []string{"10.0.0.0/8"},
+			want:       "2001:db8:cafe::17",
+		},
+		{
+			name:       "Forwarded with several elements and an IPv4 port",
+			remoteAddr: "10.0.0.1:5000",
+			headers:    map[string][]string{"Forwarded": {`for=192.0.2.43:8080, For="[2001:db8::1]";by=10.0.0.9`, "for=10.0.0.2"}},
+			trusted:    []string{"10.0.0.0/8"},
+			want:       "2001:db8::1",
+		},
+		{
+			name:       "Forwarded obfuscated identifier is unparsable",
+			remoteAddr: "10.0.0.1:5000",
+			headers:    map[string][]string{"Forwarded": {"for=unknown"}},
+			trusted:    []string{"10.0.0.0/8"},
+			want:       "10.0.0.1",
+		},
+		{
+			name:       "IPv6 peer in a trusted range",
+			remoteAddr: "[::1]:5000",
+			headers:    map[string][]string{"X-Forwarded-For": {"2001:db8::2"}},
+			trusted:    []string{"::1"},
+			want:       "2001:db8::2",
+		},
+		{
+			name:       "custom RemoteIPHeaders",
+			remoteAddr: "10.0.0.1:5000",
+			headers:    map[string][]string{"X-Forwarded-For": {"1.2.3.4"}, "X-Client-IP": {"5.6.7.8"}},
+			trusted:    []string{"10.0.0.1"},
+			ipHeaders:  []string{"X-Client-IP"},
+			want:       "5.6.7.8",
+		},
+		{
+			name:       "TrustedPlatform wins even from an untrusted peer",
+			remoteAddr: "203.0.113.9:5000",
+			headers:    map[string][]string{PlatformCloudflare: {"1.2.3.4"}, "X-Forwarded-For": {"5.6.7.8"}},
+			platform:   PlatformCloudflare,
+			want:       "1.2.3.4",
+		},
+		{
+			name:       "invalid TrustedPlatform header is ignored",
+			remoteAddr: "203.0.113.9:5000",
+			headers:    map[string][]string{PlatformCloudflare: {"not an ip"}},
+			platform:   PlatformCloudflare,
+			want:       "203.0.113.9",
+		},
+		{
+			name:       "RemoteAddr without a port",
+			remoteAddr: "203.0.113.9",
+			want:       "203.0.113.9",
+		},
+	}
+	for _, tt := range tests {
+		engine := New()
+		if err := engine.SetTrustedProxies(tt.trusted); err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		engine.TrustedPlatform = tt.platform
+		engine.RemoteIPHeaders = tt.ipHeaders
+		c := newTestContext(httptest.NewRecorder(), engine)
+		c.Req = httptest.NewRequest(http.MethodGet, "/", nil)
+		c.Req.RemoteAddr = tt.remoteAddr
+		for k, values := range tt.headers {
+			for _, v := range values {
+				c.Req.Header.Add(k, v)
+			}
+		}
+		if got := c.ClientIP(); got != tt.want {
+			t.Errorf("%s: ClientIP() = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestSetTrustedProxies(t *testing.T) {
+	engine := New()
+	for _, invalid := range [][]string{{"10.0.0.300"}, {"10.0.0.0/33"}, {"localhost"}} {
+		if err := engine.SetTrustedProxies(invalid); err == nil {
+			t.Errorf("SetTrustedProxies(%q) should fail", invalid)
+		}
+	}
+	if err := engine.SetTrustedProxies([]string{" 192.168.1.1 ", "", "fd00::/8"}); err != nil {
+		t.Fatal(err)
+	}
+	for ip, want := range map[string]bool{"192.168.1.1": true, "192.168.1.2": false, "fd00::1": true, "fe80::1": false} {
+		if got := engine.isTrustedProxy(parseIP(ip)); got != want {
+			t.Errorf("isTrustedProxy(%s) = %v, want %v", ip, got, want)
+		}
+	}
+}
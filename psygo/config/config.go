@@ -5,6 +5,7 @@ import (
 	"github.com/BurntSushi/toml"
 	psyLog "github.com/Psychopath-H/psyweb-master/psygo/logger"
 	"os"
+	"strings"
 )
 
 var Conf = &PsyConfig{
@@ -13,6 +14,7 @@ var Conf = &PsyConfig{
 	Template: make(map[string]any),
 	Db:       make(map[string]any),
 	Pool:     make(map[string]any),
+	IPFilter: make(map[string]any),
 }
 
 type PsyConfig struct {
@@ -21,6 +23,7 @@ type PsyConfig struct {
 	Template map[string]any
 	Db       map[string]any
 	Pool     map[string]any
+	IPFilter map[string]any
 }
 
 func init() {
@@ -28,7 +31,11 @@ func init() {
 }
 func loadToml() {
 	confFile := flag.String("conf", "conf/app.toml", "app config file")
-	flag.Parse()
+	//init时调用flag.Parse会因为不认识应用自己(以及go test)的参数而退出，这里只从命令行中找出-conf，
+	//-conf仍然注册在flag.CommandLine上，应用之后调用flag.Parse不会报错
+	if value, ok := lookupArg(os.Args[1:], "conf"); ok {
+		*confFile = value
+	}
 	if _, err := os.Stat(*confFile); err != nil {
 		Conf.logger.Info("conf/app.toml file not load，because not exist")
 		return
@@ -40,3 +47,28 @@ func loadToml() {
 		return
 	}
 }
+
+// lookupArg 按照flag包的语法(-name value、-name=value，可以用--)在args中查找name的值，遇到--时停止，
+// 因为不知道其它参数是否需要值，非参数的部分直接跳过
+func lookupArg(args []string, name string) (string, bool) {
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--" {
+			return "", false
+		}
+		if len(arg) < 2 || arg[0] != '-' {
+			continue
+		}
+		arg = strings.TrimPrefix(strings.TrimPrefix(arg, "-"), "-")
+		if key, value, found := strings.Cut(arg, "="); found {
+			if key == name {
+				return value, true
+			}
+			continue
+		}
+		if arg == name && i+1 < len(args) {
+			return args[i+1], true
+		}
+	}
+	return "", false
+}
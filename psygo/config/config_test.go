@@ -0,0 +1,31 @@
+package config
+
+import "testing"
+
+func TestLookupArg(t *testing.T) {
+	tests := []struct {
+		args  []string
+		value string
+		ok    bool
+	}{
+		{nil, "", false},
+		{[]string{"-conf", "a.toml"}, "a.toml", true},
+		{[]string{"--conf", "a.toml"}, "a.toml", true},
+		{[]string{"-conf=a.toml"}, "a.toml", true},
+		{[]string{"--conf=a.toml"}, "a.toml", true},
+		{[]string{"-conf="}, "", true},
+		//go test和应用自己的参数混在一起时也能找到
+		{[]string{"-test.v=true", "-test.run", "TestX", "-conf", "a.toml"}, "a.toml", true},
+		{[]string{"serve", "-port", "8080", "-conf=a.toml"}, "a.toml", true},
+		{[]string{"-config", "a.toml"}, "", false},
+		{[]string{"-conf"}, "", false},
+		{[]string{"--", "-conf", "a.toml"}, "", false},
+		{[]string{"-", "conf"}, "", false},
+	}
+	for _, tt := range tests {
+		value, ok := lookupArg(tt.args, "conf")
+		if value != tt.value || ok != tt.ok {
+			t.Errorf("lookupArg(%q) = %q, %v, want %q, %v", tt.args, value, ok, tt.value, tt.ok)
+		}
+	}
+}
@@ -15,6 +15,7 @@ import (
 	"sync"
 )
 
+// MaxMultipartMemory 是Engine.MaxMultipartMemory的默认值
 const MaxMultipartMemory = 32 << 20 //32M
 
 type H map[string]any
@@ -23,32 +24,42 @@ type Context struct {
 	//origin objects
 	Writer     http.ResponseWriter
 	Req        *http.Request
-	Path       string            //请求的路径
-	Method     string            //请求的方法
-	Params     map[string]string //本次路由得到的模糊参数
-	StatusCode int               //请求状态码
-	queryCache url.Values        //query参数缓存
-	formCache  url.Values        //form(表单)参数缓存
-	handlers   []HandlerFunc     //本次请求上下文中所用到的中间件
-	index      int               //中间件的下标索引
-	Errors     errorMsgs         //存储了本context中框架内部产生的错误
+	Path       string        //请求的路径
+	Method     string        //请求的方法
+	Params     Params        //本次路由得到的模糊参数，底层数组随Context复用
+	fullPath   string        //本次请求匹配到的路由规则，例如/p/:lang/doc
+	StatusCode int           //请求状态码
+	queryCache url.Values    //query参数缓存
+	formCache  url.Values    //form(表单)参数缓存
+	handlers   []HandlerFunc //本次请求上下文中所用到的中间件
+	index      int           //中间件的下标索引
+	Errors     errorMsgs     //存储了本context中框架内部产生的错误
 	mu         sync.RWMutex
 	Keys       map[string]any //存储了每个请求的key/value对
 	sameSite   http.SameSite
 	Engine     *Engine
+
+	writermem          responseWriter //Writer实际指向的包装，记录状态码和响应大小
+	maxBodySize        int64          //本次请求的请求体大小限制
+	maxMultipartMemory int64          //本次请求解析multipart表单时的内存阈值
 }
 
 func (c *Context) reset(w http.ResponseWriter, req *http.Request) {
-	c.Writer = w
+	c.writermem.reset(w)
+	c.Writer = &c.writermem
 	c.Req = req
 	c.Path = req.URL.Path
 	c.Method = req.Method
-	c.Params = nil
+	c.Params = c.Params[:0]
+	c.fullPath = ""
 	c.StatusCode = 0
-	c.queryCache = url.Values{}
-	c.formCache = url.Values{}
-	c.handlers = nil
+	c.queryCache = nil //用到时才会初始化
+	c.formCache = nil
+	c.handlers = c.handlers[:0]
 	c.index = -1
+	c.sameSite = 0
+	c.Errors = c.Errors[:0]
+	c.Keys = nil
 }
 
 //func newContext(w http.ResponseWriter, req *http.Request) *Context {
@@ -95,6 +106,10 @@ func (c *Context) Error(err error) *Error {
 			Err:  err,
 			Type: ErrorTypePrivate,
 		}
+		//应用错误本身就是为客户端准备的
+		if isProblemError(err) {
+			parsedError.Type = ErrorTypePublic
+		}
 	}
 
 	c.Errors = append(c.Errors, parsedError)
@@ -210,6 +225,9 @@ func (c *Context) Redirect(statusCode int, url string) {
 // Render 根据参数传递的特定格式进行渲染
 func (c *Context) Render(statusCode int, r render.Render) {
 	c.StatusCode = statusCode
+	if observe := c.Engine.renderObserver.Load(); observe != nil {
+		(*observe)(c, r)
+	}
 	if err := r.RenderData(c.Writer, statusCode); err != nil {
 		_ = c.Error(err)
 		c.Abort()
@@ -218,8 +236,12 @@ func (c *Context) Render(statusCode int, r render.Render) {
 
 // Param 获得模糊参数
 func (c *Context) Param(key string) string {
-	value, _ := c.Params[key]
-	return value
+	return c.Params.ByName(key)
+}
+
+// FullPath 返回本次请求匹配到的路由规则，例如 /user/:id，没有匹配到路由时返回空字符串
+func (c *Context) FullPath() string {
+	return c.fullPath
 }
 
 // Fail 以JSON形式返回请求错误的信息
@@ -238,7 +260,13 @@ func (c *Context) FormFile(name string) (*multipart.FileHeader, error) {
 	//		return nil, err
 	//	}
 	//}
-	f, fh, err := c.Req.FormFile(name) //把前面代码注释掉的原因是，FormFile方法里已经判断过了是否为多部分表单的请求，因此可以不再提前做判断
+	//FormFile方法里虽然也会解析表单，但用的是标准库默认的内存阈值，这里先按照分组的设置解析一次
+	if c.Req.MultipartForm == nil {
+		if _, err := c.MultipartForm(); err != nil {
+			return nil, err
+		}
+	}
+	f, fh, err := c.Req.FormFile(name)
 	if err != nil {
 		return nil, err
 	}
@@ -273,24 +301,31 @@ func (c *Context) SaveUploadedFile(file *multipart.FileHeader, dst string) error
 	return err
 }
 
-// MultipartForm 返回多部分表单
+// MultipartForm 返回多部分表单，请求体超出大小限制时和MustBindWith一样记录错误并把状态码设置为413，FormFile、FormFiles同样如此
 func (c *Context) MultipartForm() (*multipart.Form, error) {
-	err := c.Req.ParseMultipartForm(MaxMultipartMemory)
+	err := c.Req.ParseMultipartForm(c.multipartMemory())
+	if err != nil {
+		c.bodyTooLarge(err)
+	}
 	return c.Req.MultipartForm, err
 }
 
+// multipartMemory 返回本次请求解析multipart表单时的内存阈值
+func (c *Context) multipartMemory() int64 {
+	if c.maxMultipartMemory > 0 {
+		return c.maxMultipartMemory
+	}
+	return MaxMultipartMemory
+}
+
 // File 支持从web网站下载对应文件
 func (c *Context) File(filePath string) {
 	http.ServeFile(c.Writer, c.Req, filePath)
 }
 
-// FileAttachment 支持从web网站下载对应文件并进行改名
+// FileAttachment 支持从web网站下载对应文件并进行改名，filename按照RFC 6266编码，可以含有中文等字符
 func (c *Context) FileAttachment(filepath, filename string) {
-	if isASCII(filename) {
-		c.Writer.Header().Set("Content-Disposition", `attachment; filename="`+filename+`"`)
-	} else {
-		c.Writer.Header().Set("Content-Disposition", `attachment; filename*=UTF-8''`+url.QueryEscape(filename))
-	}
+	c.Writer.Header().Set("Content-Disposition", ContentDisposition("attachment", filename))
 	http.ServeFile(c.Writer, c.Req, filepath)
 }
 
@@ -357,11 +392,17 @@ func (c *Context) getMap(cache map[string][]string, key string) (map[string]stri
 	return dicts, exist
 }
 
-// initPostFromCache 初始化表单查询列表
+// initPostFromCache 初始化表单查询列表，请求体超出大小限制时记录错误并把状态码设置为413
 func (c *Context) initPostFormCache() {
 	if c.Req != nil {
-		if err := c.Req.ParseMultipartForm(MaxMultipartMemory); err != nil {
-			if !errors.Is(err, http.ErrNotMultipart) {
+		//ParseMultipartForm遇到非multipart请求时只返回http.ErrNotMultipart，先单独解析一次才能拿到读取请求体的错误
+		if c.Req.Form == nil {
+			if err := c.Req.ParseForm(); err != nil && !c.bodyTooLarge(err) {
+				log.Println(err)
+			}
+		}
+		if err := c.Req.ParseMultipartForm(c.multipartMemory()); err != nil {
+			if !c.bodyTooLarge(err) && !errors.Is(err, http.ErrNotMultipart) {
 				log.Println(err)
 			}
 		}
@@ -422,18 +463,40 @@ func (c *Context) BindXML(obj any) error {
 	return c.MustBindWith(obj, binding.XML)
 }
 
-// MustBindWith 在绑定发生错误时，会向response status code设置为400
+// MustBindWith 在绑定发生错误时，会向response status code设置为400，请求体超出大小限制时设置为413，
+// 错误以ErrorTypeBind类型记录在c.Errors中，交给ErrorHandler渲染
 func (c *Context) MustBindWith(obj any, bind binding.Binding) error {
 	if err := c.ShouldBindWith(obj, bind); err != nil {
+		if !c.bodyTooLarge(err) {
+			_ = c.AbortWithError(http.StatusBadRequest, err).SetType(ErrorTypeBind)
+		}
 		return err
 	}
 	return nil
 }
 
+// bodyTooLarge 判断err是否是请求体超出大小限制导致的，是的话记录错误并返回413，
+// 多次读取表单时同一个请求只记录一次
+func (c *Context) bodyTooLarge(err error) bool {
+	var maxBytesError *http.MaxBytesError
+	if !errors.As(err, &maxBytesError) {
+		return false
+	}
+	for _, e := range c.Errors {
+		var recorded *http.MaxBytesError
+		if errors.As(e.Err, &recorded) {
+			c.Abort()
+			return true
+		}
+	}
+	_ = c.AbortWithError(http.StatusRequestEntityTooLarge, err).SetType(ErrorTypePublic).SetMeta(H{"limit": maxBytesError.Limit})
+	return true
+}
+
 // ShouldBind 和 c.Bind()大致相同，但是对于当绑定发生错误时，并不把response status code设置为400
 func (c *Context) ShouldBind(obj any) error {
 	b := binding.Default(filterFlags(c.Req.Header.Get("Content-Type")))
-	if b != nil {
+	if b == nil {
 		return errors.New("can't match binding")
 	}
 	return c.ShouldBindWith(obj, b)
@@ -451,18 +514,12 @@ func (c *Context) ShouldBindWith(obj any, bind binding.Binding) error {
 	return bind.Bind(c.Req, obj)
 }
 
-// SetCookie 添加一个Set-Cookie头部放到ResponseWriter的头部中
+// SetCookie 添加一个Set-Cookie头部放到ResponseWriter的头部中，需要更多属性时使用SetCookieWithOptions
 func (c *Context) SetCookie(name, value string, maxAge int, path, domain string, secure, httpOnly bool) {
-	if path == "" {
-		path = "/"
-	}
-	http.SetCookie(c.Writer, &http.Cookie{
-		Name:     name,
-		Value:    url.QueryEscape(value),
+	c.SetCookieWithOptions(name, value, CookieOptions{
 		MaxAge:   maxAge,
 		Path:     path,
 		Domain:   domain,
-		SameSite: c.sameSite,
 		Secure:   secure,
 		HttpOnly: httpOnly,
 	})
@@ -0,0 +1,191 @@
+package psygo
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrCookieSignature 签名cookie的签名不正确，可能被篡改过，或者签名的密钥已经被轮换掉了
+	ErrCookieSignature = errors.New("psygo: invalid cookie signature")
+	// ErrCookieDecrypt 加密cookie无法用任何一个密钥解密
+	ErrCookieDecrypt = errors.New("psygo: cookie can not be decrypted")
+)
+
+// CookieOptions 设置cookie时的全部属性
+type CookieOptions struct {
+	MaxAge      int       //cookie的存活秒数，0表示不设置，<0表示立即删除
+	Expires     time.Time //cookie的过期时间，零值表示不设置
+	Path        string    //默认为 /
+	Domain      string
+	Secure      bool
+	HttpOnly    bool
+	SameSite    http.SameSite //不设置时使用 c.SetSameSite 设置的值
+	Partitioned bool          //CHIPS，第三方上下文中按顶级站点分区存储，要求同时设置Secure
+}
+
+// SetSameSite 设置之后通过SetCookie系列方法写入的cookie的SameSite属性
+func (c *Context) SetSameSite(samesite http.SameSite) {
+	c.sameSite = samesite
+}
+
+// Cookie 返回请求中名为name的cookie的值，值会按照SetCookie的方式反转义，cookie不存在时返回http.ErrNoCookie，
+// 值不是合法的转义字符串时返回反转义的错误
+func (c *Context) Cookie(name string) (string, error) {
+	cookie, err := c.Req.Cookie(name)
+	if err != nil {
+		return "", err
+	}
+	return url.QueryUnescape(cookie.Value)
+}
+
+// SetCookieWithOptions 按照opts写入一个cookie，值会被URL转义
+func (c *Context) SetCookieWithOptions(name, value string, opts CookieOptions) {
+	c.setCookie(name, url.QueryEscape(value), opts)
+}
+
+// setCookie 写入一个cookie，value需要已经是cookie中可以使用的字符
+func (c *Context) setCookie(name, value string, opts CookieOptions) {
+	if opts.Path == "" {
+		opts.Path = "/"
+	}
+	if opts.SameSite == 0 {
+		opts.SameSite = c.sameSite
+	}
+	cookie := &http.Cookie{
+		Name:     name,
+		Value:    value,
+		MaxAge:   opts.MaxAge,
+		Expires:  opts.Expires,
+		Path:     opts.Path,
+		Domain:   opts.Domain,
+		SameSite: opts.SameSite,
+		Secure:   opts.Secure || opts.Partitioned,
+		HttpOnly: opts.HttpOnly,
+	}
+	v := cookie.String()
+	if v == "" {
+		return
+	}
+	//标准库的http.Cookie在go1.23才支持Partitioned属性，这里自己拼接上去
+	if opts.Partitioned {
+		v += "; Partitioned"
+	}
+	c.Writer.Header().Add("Set-Cookie", v)
+}
+
+// SetCookieSigningKeys 设置签名cookie使用的HMAC密钥，第一个密钥用于签名，其余的密钥只用于验证，方便轮换密钥
+func (engine *Engine) SetCookieSigningKeys(keys ...[]byte) {
+	assert1(len(keys) > 0, "psygo: at least one cookie signing key is required")
+	engine.cookieSigningKeys = keys
+}
+
+// SetCookieEncryptionKeys 设置加密cookie使用的AES密钥(16、24或32字节)，第一个密钥用于加密，其余的密钥只用于解密
+func (engine *Engine) SetCookieEncryptionKeys(keys ...[]byte) {
+	assert1(len(keys) > 0, "psygo: at least one cookie encryption key is required")
+	aeads := make([]cipher.AEAD, 0, len(keys))
+	for _, key := range keys {
+		block, err := aes.NewCipher(key)
+		assert1(err == nil, "psygo: cookie encryption key must be 16, 24 or 32 bytes")
+		aead, err := cipher.NewGCM(block)
+		assert1(err == nil, "psygo: can not create AES-GCM cipher")
+		aeads = append(aeads, aead)
+	}
+	engine.cookieAEADs = aeads
+}
+
+// SetSignedCookie 写入一个带HMAC-SHA256签名的cookie，客户端可以读到内容但无法篡改
+func (c *Context) SetSignedCookie(name, value string, opts CookieOptions) {
+	keys := c.Engine.cookieSigningKeys
+	assert1(len(keys) > 0, "psygo: cookie signing keys are not set, use Engine.SetCookieSigningKeys first")
+	payload := base64.RawURLEncoding.EncodeToString([]byte(value))
+	mac := signCookie(keys[0], name, payload)
+	c.setCookie(name, payload+"."+base64.RawURLEncoding.EncodeToString(mac), opts)
+}
+
+// SignedCookie 读取并验证SetSignedCookie写入的cookie，签名不正确时返回ErrCookieSignature
+func (c *Context) SignedCookie(name string) (string, error) {
+	keys := c.Engine.cookieSigningKeys
+	assert1(len(keys) > 0, "psygo: cookie signing keys are not set, use Engine.SetCookieSigningKeys first")
+	cookie, err := c.Req.Cookie(name)
+	if err != nil {
+		return "", err
+	}
+	payload, sig, ok := strings.Cut(cookie.Value, ".")
+	if !ok {
+		return "", ErrCookieSignature
+	}
+	mac, err := base64.RawURLEncoding.DecodeString(sig)
+	if err != nil {
+		return "", ErrCookieSignature
+	}
+	for _, key := range keys {
+		//签名中包含了cookie的名字，防止把一个cookie的值挪到另一个cookie上使用
+		if hmac.Equal(mac, signCookie(key, name, payload)) {
+			value, err := base64.RawURLEncoding.DecodeString(payload)
+			if err != nil {
+				return "", ErrCookieSignature
+			}
+			return string(value), nil
+		}
+	}
+	return "", ErrCookieSignature
+}
+
+// SetEncryptedCookie 写入一个使用AES-GCM加密的cookie，客户端既无法读到内容也无法篡改
+func (c *Context) SetEncryptedCookie(name, value string, opts CookieOptions) error {
+	aeads := c.Engine.cookieAEADs
+	assert1(len(aeads) > 0, "psygo: cookie encryption keys are not set, use Engine.SetCookieEncryptionKeys first")
+	aead := aeads[0]
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+	//把cookie的名字作为附加数据，解密时名字不一致会失败
+	sealed := aead.Seal(nonce, nonce, []byte(value), []byte(name))
+	c.setCookie(name, base64.RawURLEncoding.EncodeToString(sealed), opts)
+	return nil
+}
+
+// EncryptedCookie 读取并解密SetEncryptedCookie写入的cookie，无法解密时返回ErrCookieDecrypt
+func (c *Context) EncryptedCookie(name string) (string, error) {
+	aeads := c.Engine.cookieAEADs
+	assert1(len(aeads) > 0, "psygo: cookie encryption keys are not set, use Engine.SetCookieEncryptionKeys first")
+	cookie, err := c.Req.Cookie(name)
+	if err != nil {
+		return "", err
+	}
+	sealed, err := base64.RawURLEncoding.DecodeString(cookie.Value)
+	if err != nil {
+		return "", ErrCookieDecrypt
+	}
+	for _, aead := range aeads {
+		size := aead.NonceSize()
+		if len(sealed) < size {
+			continue
+		}
+		value, err := aead.Open(nil, sealed[:size], sealed[size:], []byte(name))
+		if err == nil {
+			return string(value), nil
+		}
+	}
+	return "", ErrCookieDecrypt
+}
+
+// signCookie 计算cookie的签名
+func signCookie(key []byte, name, payload string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(name))
+	h.Write([]byte{'|'})
+	h.Write([]byte(payload))
+	return h.Sum(nil)
+}
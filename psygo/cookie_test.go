@@ -0,0 +1,187 @@
+package psygo
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// responseCookie 返回响应中名为name的cookie
+func responseCookie(t *testing.T, w *httptest.ResponseRecorder, name string) *http.Cookie {
+	t.Helper()
+	for _, cookie := range w.Result().Cookies() {
+		if cookie.Name == name {
+			return cookie
+		}
+	}
+	t.Fatalf("response has no cookie %s, header = %v", name, w.Header())
+	return nil
+}
+
+// readCookie 带着cookie请求/read，返回读取到的值，出错时返回 error: 加上错误信息
+func readCookie(engine *Engine, cookie *http.Cookie) string {
+	req := httptest.NewRequest(http.MethodGet, "/read", nil)
+	req.AddCookie(cookie)
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, req)
+	return w.Body.String()
+}
+
+// newCookieEngine /write 用write写入名为session的cookie，/read 用read读出它
+func newCookieEngine(write func(c *Context), read func(c *Context) (string, error)) *Engine {
+	engine := New()
+	engine.GET("/write", write)
+	engine.GET("/read", func(c *Context) {
+		value, err := read(c)
+		if err != nil {
+			c.String(http.StatusOK, "error: %v", err)
+			return
+		}
+		c.String(http.StatusOK, "%s", value)
+	})
+	return engine
+}
+
+func TestCookie(t *testing.T) {
+	engine := newCookieEngine(func(c *Context) {
+		c.SetCookieWithOptions("session", "a b;c=d/中文", CookieOptions{MaxAge: 60, HttpOnly: true, Secure: true, Partitioned: true})
+	}, func(c *Context) (string, error) {
+		return c.Cookie("session")
+	})
+
+	w := performRequest(engine, http.MethodGet, "/write", nil)
+	header := w.Header().Get("Set-Cookie")
+	for _, attr := range []string{"Path=/", "Max-Age=60", "HttpOnly", "Secure", "Partitioned"} {
+		if !strings.Contains(header, attr) {
+			t.Fatalf("Set-Cookie %q has no %s", header, attr)
+		}
+	}
+	if got := readCookie(engine, responseCookie(t, w, "session")); got != "a b;c=d/中文" {
+		t.Fatalf("value = %q", got)
+	}
+	if got := readCookie(engine, &http.Cookie{Name: "session", Value: "bad%zz"}); !strings.HasPrefix(got, "error: ") {
+		t.Fatalf("malformed cookie = %q, want an error", got)
+	}
+
+	c := newTestContext(httptest.NewRecorder(), New())
+	if _, err := c.Cookie("session"); !errors.Is(err, http.ErrNoCookie) {
+		t.Fatalf("missing cookie error = %v", err)
+	}
+	var escapeErr url.EscapeError
+	c.Req.AddCookie(&http.Cookie{Name: "session", Value: "bad%zz"})
+	if value, err := c.Cookie("session"); !errors.As(err, &escapeErr) || value != "" {
+		t.Fatalf("malformed cookie = %q, %v", value, err)
+	}
+}
+
+func TestSignedCookie(t *testing.T) {
+	oldKey, newKey := []byte("old signing key"), []byte("new signing key")
+	write := func(c *Context) {
+		c.SetSignedCookie("session", "user=42", CookieOptions{})
+	}
+	read := func(c *Context) (string, error) {
+		return c.SignedCookie("session")
+	}
+	old := newCookieEngine(write, read)
+	old.SetCookieSigningKeys(oldKey)
+	cookie := responseCookie(t, performRequest(old, http.MethodGet, "/write", nil), "session")
+	if got := readCookie(old, cookie); got != "user=42" {
+		t.Fatalf("value = %q", got)
+	}
+
+	//轮换之后旧的密钥仍然可以验证，新的cookie使用新的密钥签名
+	rotated := newCookieEngine(write, read)
+	rotated.SetCookieSigningKeys(newKey, oldKey)
+	if got := readCookie(rotated, cookie); got != "user=42" {
+		t.Fatalf("rotated value = %q", got)
+	}
+	renewed := responseCookie(t, performRequest(rotated, http.MethodGet, "/write", nil), "session")
+	if got := readCookie(old, renewed); got != "error: "+ErrCookieSignature.Error() {
+		t.Fatalf("cookie signed with the new key was accepted by the old key: %q", got)
+	}
+	dropped := newCookieEngine(write, read)
+	dropped.SetCookieSigningKeys(newKey)
+	if got := readCookie(dropped, cookie); got != "error: "+ErrCookieSignature.Error() {
+		t.Fatalf("cookie signed with a removed key = %q", got)
+	}
+
+	payload, mac, _ := strings.Cut(cookie.Value, ".")
+	tampered := []string{
+		base64RawURL("user=1") + "." + mac, //改了内容
+		payload + "." + mac[:len(mac)-2] + "AA",
+		payload,
+		payload + ".!!",
+	}
+	for _, value := range tampered {
+		if got := readCookie(old, &http.Cookie{Name: "session", Value: value}); got != "error: "+ErrCookieSignature.Error() {
+			t.Fatalf("tampered cookie %q = %q", value, got)
+		}
+	}
+	//签名和名字绑定，不能挪到别的cookie上使用
+	other := newCookieEngine(write, func(c *Context) (string, error) {
+		return c.SignedCookie("other")
+	})
+	other.SetCookieSigningKeys(oldKey)
+	if got := readCookie(other, &http.Cookie{Name: "other", Value: cookie.Value}); got != "error: "+ErrCookieSignature.Error() {
+		t.Fatalf("cookie moved to another name = %q", got)
+	}
+}
+
+func TestEncryptedCookie(t *testing.T) {
+	oldKey, newKey := bytes.Repeat([]byte{1}, 32), bytes.Repeat([]byte{2}, 16)
+	write := func(c *Context) {
+		if err := c.SetEncryptedCookie("session", "user=42", CookieOptions{}); err != nil {
+			c.AbortWithStatus(http.StatusInternalServerError)
+		}
+	}
+	read := func(c *Context) (string, error) {
+		return c.EncryptedCookie("session")
+	}
+	old := newCookieEngine(write, read)
+	old.SetCookieEncryptionKeys(oldKey)
+	cookie := responseCookie(t, performRequest(old, http.MethodGet, "/write", nil), "session")
+	if strings.Contains(cookie.Value, base64RawURL("user=42")) {
+		t.Fatalf("cookie value is not encrypted: %q", cookie.Value)
+	}
+	if got := readCookie(old, cookie); got != "user=42" {
+		t.Fatalf("value = %q", got)
+	}
+
+	rotated := newCookieEngine(write, read)
+	rotated.SetCookieEncryptionKeys(newKey, oldKey)
+	if got := readCookie(rotated, cookie); got != "user=42" {
+		t.Fatalf("rotated value = %q", got)
+	}
+	renewed := responseCookie(t, performRequest(rotated, http.MethodGet, "/write", nil), "session")
+	if got := readCookie(old, renewed); got != "error: "+ErrCookieDecrypt.Error() {
+		t.Fatalf("cookie encrypted with the new key was decrypted by the old key: %q", got)
+	}
+
+	sealed := []byte(cookie.Value)
+	if mid := len(sealed) / 2; sealed[mid] == 'A' {
+		sealed[mid] = 'B'
+	} else {
+		sealed[mid] = 'A'
+	}
+	for _, value := range []string{string(sealed), "AA", "not base64!"} {
+		if got := readCookie(old, &http.Cookie{Name: "session", Value: value}); got != "error: "+ErrCookieDecrypt.Error() {
+			t.Fatalf("tampered cookie %q = %q", value, got)
+		}
+	}
+	other := newCookieEngine(write, func(c *Context) (string, error) {
+		return c.EncryptedCookie("other")
+	})
+	other.SetCookieEncryptionKeys(oldKey)
+	if got := readCookie(other, &http.Cookie{Name: "other", Value: cookie.Value}); got != "error: "+ErrCookieDecrypt.Error() {
+		t.Fatalf("cookie moved to another name = %q", got)
+	}
+}
+
+func base64RawURL(s string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(s))
+}
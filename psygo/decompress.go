@@ -0,0 +1,95 @@
+package psygo
+
+import (
+	"bufio"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// Decompress 返回一个解压请求体的中间件，请求头中带有 Content-Encoding: gzip/deflate 时，
+// 会把c.Req.Body替换成解压后的数据流，之后的绑定和表单解析拿到的都是原始数据。
+// 解压后的数据同样受MaxBodySize限制，防止压缩炸弹
+func Decompress() HandlerFunc {
+	return func(c *Context) {
+		encoding := strings.ToLower(strings.TrimSpace(c.requestHeader("Content-Encoding")))
+		if encoding == "" || encoding == "identity" || c.Req.Body == nil || c.Req.Body == http.NoBody {
+			c.Next()
+			return
+		}
+		var (
+			body io.ReadCloser
+			err  error
+		)
+		switch encoding {
+		case "gzip", "x-gzip":
+			body, err = newGzipBody(c.Req.Body)
+		case "deflate":
+			body, err = newDeflateBody(c.Req.Body)
+		default:
+			c.Fail(http.StatusUnsupportedMediaType, "unsupported content encoding: "+encoding)
+			return
+		}
+		if err != nil {
+			if !c.bodyTooLarge(err) {
+				_ = c.Error(err).SetType(ErrorTypePublic)
+				c.Fail(http.StatusBadRequest, "invalid "+encoding+" request body")
+			}
+			return
+		}
+		if c.maxBodySize > 0 {
+			body = http.MaxBytesReader(c.Writer, body, c.maxBodySize)
+		}
+		c.Req.Body = body
+		c.Req.Header.Del("Content-Encoding")
+		c.Req.Header.Del("Content-Length")
+		c.Req.ContentLength = -1
+		c.Next()
+	}
+}
+
+// decompressBody 关闭时同时关闭解压器和原始的请求体
+type decompressBody struct {
+	io.Reader
+	closers []io.Closer
+}
+
+func (b *decompressBody) Close() error {
+	var err error
+	for _, closer := range b.closers {
+		if e := closer.Close(); e != nil && err == nil {
+			err = e
+		}
+	}
+	return err
+}
+
+func newGzipBody(src io.ReadCloser) (io.ReadCloser, error) {
+	zr, err := gzip.NewReader(src)
+	if err != nil {
+		return nil, err
+	}
+	return &decompressBody{Reader: zr, closers: []io.Closer{zr, src}}, nil
+}
+
+// newDeflateBody HTTP中的deflate按规范是zlib格式，但有不少客户端直接发送裸的deflate数据，这里两种都支持
+func newDeflateBody(src io.ReadCloser) (io.ReadCloser, error) {
+	br := bufio.NewReader(src)
+	header, err := br.Peek(2)
+	if err != nil {
+		return nil, err
+	}
+	//zlib头部：CMF的低4位为8(deflate)，并且 (CMF*256 + FLG) 能被31整除
+	if header[0]&0x0f == 8 && (uint16(header[0])<<8|uint16(header[1]))%31 == 0 {
+		zr, err := zlib.NewReader(br)
+		if err != nil {
+			return nil, err
+		}
+		return &decompressBody{Reader: zr, closers: []io.Closer{zr, src}}, nil
+	}
+	fr := flate.NewReader(br)
+	return &decompressBody{Reader: fr, closers: []io.Closer{fr, src}}, nil
+}
@@ -0,0 +1,108 @@
+package psygo
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// compress 按照encoding压缩data
+func compress(t *testing.T, encoding string, data []byte) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	var w io.WriteCloser
+	switch encoding {
+	case "gzip":
+		w = gzip.NewWriter(&buf)
+	case "zlib":
+		w = zlib.NewWriter(&buf)
+	case "flate":
+		w, _ = flate.NewWriter(&buf, flate.DefaultCompression)
+	default:
+		t.Fatalf("unknown encoding %s", encoding)
+	}
+	_, _ = w.Write(data)
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return &buf
+}
+
+// newDecompressEngine 返回一个使用Decompress并把请求体原样返回的Engine
+func newDecompressEngine() *Engine {
+	engine := New()
+	engine.Use(Decompress())
+	engine.POST("/echo", func(c *Context) {
+		var obj struct {
+			Name string `json:"name"`
+		}
+		if err := c.BindJson(&obj); err != nil {
+			return
+		}
+		c.String(http.StatusOK, "%s", obj.Name)
+	})
+	return engine
+}
+
+func TestDecompress(t *testing.T) {
+	engine := newDecompressEngine()
+	data := []byte(`{"name":"psygo"}`)
+
+	tests := []struct {
+		header, encoding string
+	}{
+		{"gzip", "gzip"},
+		{"x-gzip", "gzip"},
+		{"deflate", "zlib"},  //规范的deflate是zlib格式
+		{"deflate", "flate"}, //也兼容裸的deflate数据
+	}
+	for _, tt := range tests {
+		w := performRequest(engine, http.MethodPost, "/echo", compress(t, tt.encoding, data),
+			"Content-Type", "application/json", "Content-Encoding", tt.header)
+		if w.Code != http.StatusOK || w.Body.String() != "psygo" {
+			t.Fatalf("%s/%s: status = %d, body = %q", tt.header, tt.encoding, w.Code, w.Body.String())
+		}
+	}
+
+	//没有Content-Encoding时不做处理
+	w := performRequest(engine, http.MethodPost, "/echo", bytes.NewReader(data), "Content-Type", "application/json")
+	if w.Code != http.StatusOK || w.Body.String() != "psygo" {
+		t.Fatalf("identity: status = %d, body = %q", w.Code, w.Body.String())
+	}
+}
+
+func TestDecompressErrors(t *testing.T) {
+	engine := newDecompressEngine()
+
+	w := performRequest(engine, http.MethodPost, "/echo", strings.NewReader("data"),
+		"Content-Type", "application/json", "Content-Encoding", "br")
+	if w.Code != http.StatusUnsupportedMediaType {
+		t.Fatalf("unknown encoding: status = %d, want 415", w.Code)
+	}
+	w = performRequest(engine, http.MethodPost, "/echo", strings.NewReader("not gzip"),
+		"Content-Type", "application/json", "Content-Encoding", "gzip")
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("invalid gzip: status = %d, want 400", w.Code)
+	}
+}
+
+func TestDecompressBomb(t *testing.T) {
+	engine := newDecompressEngine()
+	engine.MaxBodySize = 1 << 10
+
+	//压缩后不到1K，解压后有64K，解压后的大小同样受MaxBodySize限制
+	body := compress(t, "gzip", []byte(`{"name":"`+strings.Repeat("a", 64<<10)+`"}`))
+	if body.Len() >= 1<<10 {
+		t.Fatalf("compressed body is %d bytes", body.Len())
+	}
+	w := performRequest(engine, http.MethodPost, "/echo", body,
+		"Content-Type", "application/json", "Content-Encoding", "gzip")
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("status = %d, want 413", w.Code)
+	}
+}
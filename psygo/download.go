@@ -0,0 +1,105 @@
+package psygo
+
+import (
+	"fmt"
+	"github.com/Psychopath-H/psyweb-master/psygo/render"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// DataFromReader 把reader中的内容流式写入响应，适合边生成边下载的报表等内容。contentLength<0表示长度未知，使用分块传输，
+// 否则只发送reader中从当前位置开始的contentLength个字节；
+// extraHeaders是额外的响应头部，例如 {"Content-Disposition": psygo.ContentDisposition("attachment", "报表.xlsx")}。
+// code为200、长度已知并且reader同时实现了io.Seeker和io.ReaderAt(例如*os.File、*bytes.Reader)时，
+// 和FileFromReaderSeeker一样支持Range、If-Range和多段范围请求，extraHeaders中的ETag、Last-Modified会用于If-Range的判断
+func (c *Context) DataFromReader(code int, contentLength int64, contentType string, reader io.Reader, extraHeaders map[string]string) {
+	if section, ok := sectionOf(reader, contentLength); ok && code == http.StatusOK {
+		header := c.Writer.Header()
+		for k, v := range extraHeaders {
+			header.Set(k, v)
+		}
+		if contentType != "" {
+			header.Set("Content-Type", contentType)
+		}
+		var modtime time.Time
+		if lastModified := header.Get("Last-Modified"); lastModified != "" {
+			modtime, _ = http.ParseTime(lastModified)
+		}
+		c.StatusCode = code
+		http.ServeContent(c.Writer, c.Req, "", modtime, section)
+		return
+	}
+	c.Render(code, render.Reader{
+		ContentType:   contentType,
+		ContentLength: contentLength,
+		Reader:        reader,
+		Headers:       extraHeaders,
+	})
+}
+
+// sectionOf 返回reader从当前位置开始、长度为contentLength的一段，reader不支持随机读取或者长度未知时返回false
+func sectionOf(reader io.Reader, contentLength int64) (*io.SectionReader, bool) {
+	if contentLength < 0 {
+		return nil, false
+	}
+	rs, ok := reader.(interface {
+		io.Seeker
+		io.ReaderAt
+	})
+	if !ok {
+		return nil, false
+	}
+	offset, err := rs.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return nil, false
+	}
+	return io.NewSectionReader(rs, offset, contentLength), true
+}
+
+// FileFromReaderSeeker 以name为文件名提供rs中的内容，不需要内容存在于磁盘上。
+// 支持Range、If-Range和多段范围(multipart/byteranges)请求，客户端可以断点续传；
+// modtime不为零值时用于Last-Modified和If-Modified-Since，Content-Type由name的扩展名或者内容嗅探得到。
+// 需要下载为附件时先调用c.Header("Content-Disposition", psygo.ContentDisposition("attachment", name))
+func (c *Context) FileFromReaderSeeker(name string, modtime time.Time, rs io.ReadSeeker) {
+	c.StatusCode = http.StatusOK
+	http.ServeContent(c.Writer, c.Req, name, modtime, rs)
+}
+
+// ContentDisposition 按照RFC 6266生成Content-Disposition的值，dispositionType为attachment或者inline。
+// filename只含有可打印ASCII字符时只有filename参数，否则额外提供RFC 5987编码的filename*，
+// 同时filename中的其它字符替换成_，作为不支持filename*的客户端的备选
+func ContentDisposition(dispositionType, filename string) string {
+	if filename == "" {
+		return dispositionType
+	}
+	var fallback strings.Builder
+	for _, r := range filename {
+		if r < 0x20 || r >= 0x7f || r == '"' || r == '\\' || r == utf8.RuneError {
+			fallback.WriteByte('_')
+			continue
+		}
+		fallback.WriteRune(r)
+	}
+	value := dispositionType + `; filename="` + fallback.String() + `"`
+	if fallback.String() != filename {
+		value += "; filename*=UTF-8''" + encodeRFC5987(filename)
+	}
+	return value
+}
+
+// encodeRFC5987 按照RFC 5987对参数值进行百分号编码，只保留attr-char
+func encodeRFC5987(s string) string {
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		b := s[i]
+		if 'a' <= b && b <= 'z' || 'A' <= b && b <= 'Z' || '0' <= b && b <= '9' || strings.IndexByte("!#$&+-.^_`|~", b) >= 0 {
+			sb.WriteByte(b)
+			continue
+		}
+		fmt.Fprintf(&sb, "%%%02X", b)
+	}
+	return sb.String()
+}
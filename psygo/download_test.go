@@ -0,0 +1,171 @@
+package psygo
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDownloads(t *testing.T) {
+	modtime := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	content := "0123456789abcdefghij"
+	engine := New()
+	engine.GET("/report.csv", func(c *Context) {
+		c.Header("Content-Disposition", ContentDisposition("attachment", "月度报表 2024.csv"))
+		c.FileFromReaderSeeker("report.csv", modtime, strings.NewReader(content))
+	})
+	engine.GET("/stream", func(c *Context) {
+		pr, pw := io.Pipe()
+		go func() {
+			for i := 0; i < 3; i++ {
+				_, _ = fmt.Fprintf(pw, "row %d\n", i)
+			}
+			_ = pw.Close()
+		}()
+		c.DataFromReader(http.StatusOK, -1, "text/csv", pr, map[string]string{
+			"Content-Disposition": ContentDisposition("attachment", "rows.csv"),
+		})
+	})
+	engine.GET("/seekable", func(c *Context) {
+		c.DataFromReader(http.StatusOK, int64(len(content)), "application/octet-stream", strings.NewReader(content), map[string]string{
+			"ETag": `"v1"`,
+		})
+	})
+
+	tests := []struct {
+		name    string
+		target  string
+		headers []string
+		code    int
+		want    map[string]string
+		body    string
+	}{
+		{
+			name:   "attachment",
+			target: "/report.csv",
+			code:   http.StatusOK,
+			want: map[string]string{
+				"Content-Type":        "text/csv; charset=utf-8",
+				"Accept-Ranges":       "bytes",
+				"Content-Disposition": `attachment; filename="____ 2024.csv"; filename*=UTF-8''%E6%9C%88%E5%BA%A6%E6%8A%A5%E8%A1%A8%202024.csv`,
+			},
+			body: content,
+		},
+		{
+			name:    "range",
+			target:  "/report.csv",
+			headers: []string{"Range", "bytes=10-"},
+			code:    http.StatusPartialContent,
+			want:    map[string]string{"Content-Range": "bytes 10-19/20"},
+			body:    "abcdefghij",
+		},
+		{
+			name:    "If-Range mismatch returns the whole content",
+			target:  "/report.csv",
+			headers: []string{"Range", "bytes=10-", "If-Range", modtime.Add(-time.Hour).Format(http.TimeFormat)},
+			code:    http.StatusOK,
+			body:    content,
+		},
+		{
+			name:    "If-Range match",
+			target:  "/report.csv",
+			headers: []string{"Range", "bytes=10-", "If-Range", modtime.Format(http.TimeFormat)},
+			code:    http.StatusPartialContent,
+			body:    "abcdefghij",
+		},
+		{
+			name:   "stream",
+			target: "/stream",
+			code:   http.StatusOK,
+			want: map[string]string{
+				"Content-Type":        "text/csv",
+				"Content-Disposition": `attachment; filename="rows.csv"`,
+			},
+			body: "row 0\nrow 1\nrow 2\n",
+		},
+		{
+			name:    "seekable reader with matching ETag",
+			target:  "/seekable",
+			headers: []string{"Range", "bytes=0-3", "If-Range", `"v1"`},
+			code:    http.StatusPartialContent,
+			want:    map[string]string{"ETag": `"v1"`},
+			body:    "0123",
+		},
+		{
+			name:    "seekable reader with stale ETag",
+			target:  "/seekable",
+			headers: []string{"Range", "bytes=0-3", "If-Range", `"v0"`},
+			code:    http.StatusOK,
+			body:    content,
+		},
+	}
+	for _, tt := range tests {
+		w := performRequest(engine, http.MethodGet, tt.target, nil, tt.headers...)
+		if w.Code != tt.code {
+			t.Errorf("%s: status %d, want %d", tt.name, w.Code, tt.code)
+		}
+		for k, v := range tt.want {
+			if got := w.Header().Get(k); got != v {
+				t.Errorf("%s: %s = %q, want %q", tt.name, k, got, v)
+			}
+		}
+		if w.Body.String() != tt.body {
+			t.Errorf("%s: body %q, want %q", tt.name, w.Body.String(), tt.body)
+		}
+	}
+
+	w := performRequest(engine, http.MethodGet, "/report.csv", nil, "Range", "bytes=0-1,18-19")
+	if w.Code != http.StatusPartialContent ||
+		!strings.HasPrefix(w.Header().Get("Content-Type"), "multipart/byteranges; boundary=") ||
+		!strings.Contains(w.Body.String(), "Content-Range: bytes 0-1/20") ||
+		!strings.Contains(w.Body.String(), "Content-Range: bytes 18-19/20") {
+		t.Fatalf("multipart ranges: %d %q %q", w.Code, w.Header().Get("Content-Type"), w.Body.String())
+	}
+}
+
+// seekOnlyReader 只实现了io.ReadSeeker，不支持随机读取
+type seekOnlyReader struct {
+	io.ReadSeeker
+}
+
+func TestDataFromReaderContentLength(t *testing.T) {
+	content := "0123456789abcdefghij"
+	engine := New()
+	engine.GET("/section", func(c *Context) {
+		r := strings.NewReader(content)
+		_, _ = r.Seek(5, io.SeekStart)
+		c.DataFromReader(http.StatusOK, 10, "text/plain", r, nil)
+	})
+	engine.GET("/seek-only", func(c *Context) {
+		r := strings.NewReader(content)
+		_, _ = r.Seek(5, io.SeekStart)
+		c.DataFromReader(http.StatusOK, 10, "text/plain", seekOnlyReader{r}, nil)
+	})
+	engine.GET("/reader", func(c *Context) {
+		c.DataFromReader(http.StatusOK, 4, "text/plain", io.MultiReader(strings.NewReader(content)), nil)
+	})
+
+	tests := []struct {
+		target  string
+		headers []string
+		code    int
+		length  string
+		body    string
+	}{
+		{"/section", nil, http.StatusOK, "10", "56789abcde"},
+		{"/section", []string{"Range", "bytes=-3"}, http.StatusPartialContent, "3", "cde"},
+		{"/seek-only", nil, http.StatusOK, "10", "56789abcde"},
+		{"/seek-only", []string{"Range", "bytes=-3"}, http.StatusOK, "10", "56789abcde"},
+		{"/reader", nil, http.StatusOK, "4", "0123"},
+	}
+	for _, tt := range tests {
+		w := performRequest(engine, http.MethodGet, tt.target, nil, tt.headers...)
+		if w.Code != tt.code || w.Header().Get("Content-Length") != tt.length || w.Body.String() != tt.body {
+			t.Errorf("%s %v: got %d %s %q, want %d %s %q", tt.target, tt.headers,
+				w.Code, w.Header().Get("Content-Length"), w.Body.String(), tt.code, tt.length, tt.body)
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package psygo
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// ErrorHandlerConfig 错误处理中间件的配置
+type ErrorHandlerConfig struct {
+	// Render 把可以展示给客户端的错误写成响应，默认写成 application/problem+json 形式的问题详情文档
+	Render func(c *Context, code int, errs errorMsgs)
+	// Log 记录不能展示给客户端的错误，默认写入Engine.Logger
+	Log func(c *Context, errs errorMsgs)
+}
+
+// ErrorHandler 返回一个集中处理c.Errors的中间件，它在整个处理链执行完之后运行：
+// ErrorTypePublic和ErrorTypeBind类型的错误会在响应体还没有写出时渲染给客户端，
+// 其余的(私有)错误只记录到Engine.Logger中，不会泄露给客户端
+func ErrorHandler() HandlerFunc {
+	return ErrorHandlerWithConfig(ErrorHandlerConfig{})
+}
+
+// ErrorHandlerWithConfig 根据配置返回一个错误处理中间件
+func ErrorHandlerWithConfig(config ErrorHandlerConfig) HandlerFunc {
+	if config.Render == nil {
+		config.Render = defaultErrorRender
+	}
+	if config.Log == nil {
+		config.Log = defaultErrorLog
+	}
+	return func(c *Context) {
+		c.Next()
+		if len(c.Errors) == 0 {
+			return
+		}
+		if private := c.Errors.ByType(^(ErrorTypePublic | ErrorTypeBind)); len(private) > 0 {
+			config.Log(c, private)
+		}
+		//处理函数已经写出了响应体，就不再覆盖它
+		if c.ResponseSize() > 0 {
+			return
+		}
+		public := c.Errors.ByType(ErrorTypePublic | ErrorTypeBind)
+		code := c.ResponseStatus()
+		if c.responseState().Status() != 0 && code < http.StatusBadRequest {
+			//已经返回了成功的状态码(例如204)，错误只需要记录
+			return
+		}
+		if code < http.StatusBadRequest {
+			code = errorStatus(c.Errors)
+			//最后一个公开错误是应用错误时，使用它自己的状态码
+			if len(public) > 0 && isProblemError(public.Last()) {
+				code = ProblemFromError(public.Last(), code).Status
+			}
+		}
+		if len(public) == 0 {
+			//只有私有错误时，返回不带细节的状态码描述
+			config.Render(c, code, errorMsgs{{Err: fmt.Errorf("%s", http.StatusText(code)), Type: ErrorTypePublic}})
+			return
+		}
+		config.Render(c, code, public)
+	}
+}
+
+// errorStatus 处理函数没有设置错误状态码时，根据错误类型推断一个
+func errorStatus(errs errorMsgs) int {
+	if len(errs.ByType(ErrorTypeBind)) > 0 {
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
+
+func defaultErrorRender(c *Context, code int, errs errorMsgs) {
+	problem := problemFromErrors(code, errs)
+	problem.Status = code
+	c.ProblemJSON(problem)
+}
+
+func defaultErrorLog(c *Context, errs errorMsgs) {
+	message := fmt.Sprintf("%s %s\n%s", c.Method, c.Path, errs.String())
+	if c.Engine.Logger != nil {
+		_ = c.Engine.Logger.Error(message)
+		return
+	}
+	fmt.Fprint(DefaultWriter, message)
+}
+
+// AbortWithStatus 写入状态码并终止后续的处理函数
+func (c *Context) AbortWithStatus(code int) {
+	c.Status(code)
+	c.Abort()
+}
+
+// AbortWithStatusJSON 终止后续的处理函数，并以JSON形式返回obj
+func (c *Context) AbortWithStatusJSON(code int, obj any) {
+	c.Abort()
+	c.JSON(code, obj)
+}
+
+// AbortWithError 写入状态码、记录错误并终止后续的处理函数，响应体交给ErrorHandler渲染，
+// 返回的*Error可以继续设置类型和元数据，例如 c.AbortWithError(400, err).SetType(ErrorTypePublic)
+func (c *Context) AbortWithError(code int, err error) *Error {
+	c.AbortWithStatus(code)
+	return c.Error(err)
+}
@@ -0,0 +1,66 @@
+package psygo
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestErrorHandlerHeaders(t *testing.T) {
+	engine := New()
+	engine.Use(ErrorHandler())
+	engine.GET("/missing", func(c *Context) {
+		_ = c.AbortWithError(http.StatusNotFound, errors.New("goods not found")).SetType(ErrorTypePublic)
+	})
+	engine.POST("/bind", func(c *Context) {
+		var body struct {
+			Name string `json:"name" binding:"required"`
+		}
+		_ = c.BindJson(&body)
+	})
+	engine.GET("/no-content", func(c *Context) {
+		c.Status(http.StatusNoContent)
+		_ = c.Error(errors.New("logged only"))
+	})
+
+	tests := []struct {
+		method, path, body string
+		status             int
+		contentType        string
+		detail             string
+	}{
+		{http.MethodGet, "/missing", "", http.StatusNotFound, "application/problem+json", "goods not found"},
+		{http.MethodPost, "/bind", `{"name":`, http.StatusBadRequest, "application/problem+json", `"status":400`},
+		{http.MethodGet, "/no-content", "", http.StatusNoContent, "", ""},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+		req.Header.Set("Content-Type", "application/json")
+		engine.ServeHTTP(w, req)
+		resp := w.Result()
+		if resp.StatusCode != tt.status {
+			t.Fatalf("%s: status = %d, want %d", tt.path, resp.StatusCode, tt.status)
+		}
+		if got := resp.Header.Get("Content-Type"); !strings.HasPrefix(got, tt.contentType) || (tt.contentType == "") != (got == "") {
+			t.Fatalf("%s: content type = %q, want %q", tt.path, got, tt.contentType)
+		}
+		if !strings.Contains(w.Body.String(), tt.detail) {
+			t.Fatalf("%s: body = %q, want detail %q", tt.path, w.Body.String(), tt.detail)
+		}
+	}
+}
+
+func TestStatusWithoutBody(t *testing.T) {
+	engine := New()
+	engine.GET("/accepted", func(c *Context) {
+		c.Status(http.StatusAccepted)
+	})
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/accepted", nil))
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusAccepted)
+	}
+}
@@ -1,6 +1,11 @@
 package psygo
 
-import "reflect"
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+)
 
 // ErrorType is an unsigned 64-bit error code as defined in the gin spec.
 type ErrorType uint64
@@ -68,3 +73,86 @@ func (msg *Error) JSON() any {
 	}
 	return jsonData
 }
+
+// IsType 判断错误是否属于flags中的某种类型
+func (msg *Error) IsType(flags ErrorType) bool {
+	return (msg.Type & flags) > 0
+}
+
+// Unwrap 返回被包装的错误，使errors.Is和errors.As可以穿透Error
+func (msg *Error) Unwrap() error {
+	return msg.Err
+}
+
+// ByType 返回属于typ类型的所有错误，ByType(ErrorTypePublic)返回所有可以展示给客户端的错误
+func (a errorMsgs) ByType(typ ErrorType) errorMsgs {
+	if len(a) == 0 {
+		return nil
+	}
+	if typ == ErrorTypeAny {
+		return a
+	}
+	var result errorMsgs
+	for _, msg := range a {
+		if msg.IsType(typ) {
+			result = append(result, msg)
+		}
+	}
+	return result
+}
+
+// Last 返回最后一个错误，没有错误时返回nil
+func (a errorMsgs) Last() *Error {
+	if length := len(a); length > 0 {
+		return a[length-1]
+	}
+	return nil
+}
+
+// Errors 返回所有错误的信息
+func (a errorMsgs) Errors() []string {
+	if len(a) == 0 {
+		return nil
+	}
+	errorStrings := make([]string, len(a))
+	for i, msg := range a {
+		errorStrings[i] = msg.Error()
+	}
+	return errorStrings
+}
+
+// JSON 把错误转化成JSON格式，只有一个错误时直接返回它本身，否则返回数组
+func (a errorMsgs) JSON() any {
+	switch length := len(a); length {
+	case 0:
+		return nil
+	case 1:
+		return a.Last().JSON()
+	default:
+		jsonData := make([]any, length)
+		for i, msg := range a {
+			jsonData[i] = msg.JSON()
+		}
+		return jsonData
+	}
+}
+
+// MarshalJSON 实现了json.Marshaller
+func (a errorMsgs) MarshalJSON() ([]byte, error) {
+	return json.Marshal(a.JSON())
+}
+
+// String 把所有错误按顺序拼接成便于阅读的文本
+func (a errorMsgs) String() string {
+	if len(a) == 0 {
+		return ""
+	}
+	var buffer strings.Builder
+	for i, msg := range a {
+		fmt.Fprintf(&buffer, "Error #%02d: %s\n", i+1, msg.Err)
+		if msg.Meta != nil {
+			fmt.Fprintf(&buffer, "     Meta: %v\n", msg.Meta)
+		}
+	}
+	return buffer.String()
+}
@@ -0,0 +1,278 @@
+package psygo
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// DefaultETagMaxSize ETag中间件默认最多缓存的响应体大小
+const DefaultETagMaxSize = 1 << 20
+
+// ETagOptions ETag中间件的配置
+type ETagOptions struct {
+	Weak    bool //生成弱ETag(W/"...")，响应体在经过压缩等不改变语义的转换后仍然可以复用
+	MaxSize int  //最多缓存的响应体字节数，超过后直接写出且不再生成ETag，默认DefaultETagMaxSize
+}
+
+// ETag 返回一个生成强ETag并处理条件请求的中间件
+func ETag() HandlerFunc {
+	return ETagWithOptions(ETagOptions{})
+}
+
+// ETagWithOptions 根据配置返回一个ETag中间件。
+// GET和HEAD请求返回200时，先缓存处理函数写出的响应体，结束后根据内容计算ETag，
+// 请求头中的If-None-Match或者If-Modified-Since表明客户端的缓存仍然有效时只返回304，不再发送响应体。
+// 处理函数在写出响应之前已经设置了ETag头部(例如用数据的版本号)时，不再缓存和计算，直接用它判断；
+// 想在查询数据之前就结束请求，可以在处理函数中调用c.CheckNotModified。
+// 调用了Flush的流式响应不会生成ETag
+func ETagWithOptions(opts ETagOptions) HandlerFunc {
+	if opts.MaxSize <= 0 {
+		opts.MaxSize = DefaultETagMaxSize
+	}
+	return func(c *Context) {
+		if c.Req.Method != http.MethodGet && c.Req.Method != http.MethodHead {
+			c.Next()
+			return
+		}
+		w := &etagWriter{ResponseWriter: c.Writer, req: c.Req, opts: &opts}
+		c.Writer = w
+		defer func() { c.Writer = w.ResponseWriter }() //发生panic时缓存的数据被丢弃，Recovery直接写原来的Writer
+		c.Next()
+		w.finish()
+	}
+}
+
+// CheckNotModified 设置响应的ETag和Last-Modified头部，并判断客户端的缓存是否仍然有效，
+// 有效时返回304、中止后续的处理链并返回true，处理函数应当直接返回，例如
+//
+//	if c.CheckNotModified(fmt.Sprintf("goods-%d", goods.Version), goods.UpdatedAt) {
+//		return
+//	}
+//
+// etag为空或者lastModified为零值时不设置对应的头部，etag没有加引号时会自动加上。
+// 只有GET和HEAD请求会返回304
+func (c *Context) CheckNotModified(etag string, lastModified time.Time) bool {
+	header := c.Writer.Header()
+	if etag != "" {
+		header.Set("ETag", quoteETag(etag))
+	}
+	if !lastModified.IsZero() {
+		header.Set("Last-Modified", lastModified.UTC().Format(http.TimeFormat))
+	}
+	if !isNotModified(c.Req, header) {
+		return false
+	}
+	writeNotModified(c.Writer)
+	c.StatusCode = http.StatusNotModified
+	c.Abort()
+	return true
+}
+
+// etagWriter 在第一次写出响应体时决定如何处理：直接写出、只返回304，或者缓存起来等处理链结束后计算ETag
+type etagWriter struct {
+	http.ResponseWriter
+	req     *http.Request
+	opts    *ETagOptions
+	code    int
+	size    int
+	decided bool
+	buffer  bool //缓存响应体，结束后计算ETag
+	skip    bool //已经返回了304，丢弃响应体
+	buf     bytes.Buffer
+}
+
+func (w *etagWriter) WriteHeader(code int) {
+	if w.decided {
+		return
+	}
+	w.code = code
+	if code != http.StatusOK { //304、重定向和错误等直接写出
+		w.decide()
+	}
+}
+
+func (w *etagWriter) Write(b []byte) (int, error) {
+	if !w.decided {
+		w.decide()
+	}
+	if w.skip { //304没有响应体
+		return len(b), nil
+	}
+	w.size += len(b)
+	switch {
+	case !w.buffer:
+		return w.ResponseWriter.Write(b)
+	case w.buf.Len()+len(b) > w.opts.MaxSize:
+		w.passThrough()
+		return w.ResponseWriter.Write(b)
+	}
+	return w.buf.Write(b)
+}
+
+// Flush 实现了http.Flusher，流式响应放弃计算ETag
+func (w *etagWriter) Flush() {
+	if !w.decided {
+		w.decide()
+	}
+	if w.buffer {
+		w.passThrough()
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap 供http.ResponseController找到原始的ResponseWriter
+func (w *etagWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// Status 实现了responseState
+func (w *etagWriter) Status() int {
+	return w.code
+}
+
+// Size 实现了responseState，缓存中的数据也算已经写出
+func (w *etagWriter) Size() int {
+	return w.size
+}
+
+// Written 实现了responseState
+func (w *etagWriter) Written() bool {
+	return w.size > 0 || w.decided && !w.buffer
+}
+
+// decide 在写出响应体之前决定如何处理这个响应
+func (w *etagWriter) decide() {
+	w.decided = true
+	if w.code == 0 {
+		w.code = http.StatusOK
+	}
+	switch {
+	case w.code != http.StatusOK:
+		w.ResponseWriter.WriteHeader(w.code)
+	case w.Header().Get("ETag") != "" || w.Header().Get("Last-Modified") != "": //处理函数自己提供了版本
+		if isNotModified(w.req, w.Header()) {
+			w.notModified()
+			w.skip = true
+			return
+		}
+		w.ResponseWriter.WriteHeader(w.code)
+	default:
+		w.buffer = true
+	}
+}
+
+// notModified 返回304，之后的Status也报告304，访问日志和监控记录的是真正发出的状态码
+func (w *etagWriter) notModified() {
+	w.code = http.StatusNotModified
+	w.size = 0
+	writeNotModified(w.ResponseWriter)
+}
+
+// passThrough 放弃计算ETag，写出已经缓存的数据，之后的写入直接交给原来的Writer
+func (w *etagWriter) passThrough() {
+	w.buffer = false
+	w.ResponseWriter.WriteHeader(w.code)
+	if w.buf.Len() > 0 {
+		_, _ = w.ResponseWriter.Write(w.buf.Bytes())
+	}
+	w.buf = bytes.Buffer{}
+}
+
+// finish 处理链结束后计算ETag，写出304或者缓存的响应
+func (w *etagWriter) finish() {
+	if !w.decided {
+		if w.code == 0 { //处理函数什么都没有写
+			return
+		}
+		w.decide()
+	}
+	if !w.buffer {
+		return
+	}
+	w.buffer = false
+	w.Header().Set("ETag", computeETag(w.buf.Bytes(), w.opts.Weak))
+	if isNotModified(w.req, w.Header()) {
+		w.notModified()
+		return
+	}
+	w.ResponseWriter.WriteHeader(w.code)
+	if w.buf.Len() > 0 {
+		_, _ = w.ResponseWriter.Write(w.buf.Bytes())
+	}
+}
+
+// computeETag 用响应体的sha256摘要生成ETag
+func computeETag(body []byte, weak bool) string {
+	sum := sha256.Sum256(body)
+	tag := `"` + base64.RawURLEncoding.EncodeToString(sum[:16]) + `"`
+	if weak {
+		return "W/" + tag
+	}
+	return tag
+}
+
+// quoteETag 给没有加引号的ETag加上引号，已经是"..."或者W/"..."的保持不变
+func quoteETag(etag string) string {
+	if strings.HasPrefix(etag, `"`) || strings.HasPrefix(etag, `W/"`) {
+		return etag
+	}
+	return `"` + etag + `"`
+}
+
+// isNotModified 根据响应头中的ETag和Last-Modified判断GET和HEAD请求的客户端缓存是否仍然有效。
+// 按照RFC 9110，请求带有If-None-Match时忽略If-Modified-Since，If-None-Match使用弱比较
+func isNotModified(req *http.Request, header http.Header) bool {
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		return false
+	}
+	if inm := req.Header.Get("If-None-Match"); inm != "" {
+		etag := header.Get("ETag")
+		return etag != "" && etagMatch(inm, etag)
+	}
+	ims := req.Header.Get("If-Modified-Since")
+	lastModified := header.Get("Last-Modified")
+	if ims == "" || lastModified == "" {
+		return false
+	}
+	since, err := http.ParseTime(ims)
+	if err != nil {
+		return false
+	}
+	modified, err := http.ParseTime(lastModified)
+	if err != nil {
+		return false
+	}
+	return !modified.Truncate(time.Second).After(since)
+}
+
+// etagMatch 判断If-None-Match中的ETag列表是否包含etag，忽略W/前缀
+func etagMatch(list, etag string) bool {
+	if strings.TrimSpace(list) == "*" {
+		return true
+	}
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, candidate := range strings.Split(list, ",") {
+		if strings.TrimPrefix(strings.TrimSpace(candidate), "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// writeNotModified 返回304，和http.ServeContent一样去掉描述响应体的头部
+func writeNotModified(w http.ResponseWriter) {
+	header := w.Header()
+	header.Del("Content-Type")
+	header.Del("Content-Length")
+	header.Del("Content-Encoding")
+	if header.Get("ETag") != "" {
+		header.Del("Last-Modified")
+	}
+	w.WriteHeader(http.StatusNotModified)
+}
@@ -0,0 +1,136 @@
+package psygo
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestETagWithErrorHandler(t *testing.T) {
+	engine := New()
+	engine.Use(ETag(), ErrorHandler())
+	engine.GET("/goods", func(c *Context) {
+		c.JSON(http.StatusOK, H{"goods": []string{"apple"}})
+		_ = c.Error(errors.New("private failure"))
+	})
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/goods", nil))
+	if w.Code != http.StatusOK || strings.TrimSpace(w.Body.String()) != `{"goods":["apple"]}` {
+		t.Fatalf("status = %d, body = %q", w.Code, w.Body.String())
+	}
+	if w.Header().Get("ETag") == "" {
+		t.Fatalf("missing etag")
+	}
+}
+
+func TestETagNotModifiedStatus(t *testing.T) {
+	var status, size int
+	engine := New()
+	engine.Use(ETag(), func(c *Context) {
+		c.Next()
+		status, size = c.ResponseStatus(), c.ResponseSize()
+	})
+	engine.GET("/versioned", func(c *Context) {
+		c.Header("ETag", `"v1"`)
+		c.String(http.StatusOK, "versioned")
+	})
+	w := performRequest(engine, http.MethodGet, "/versioned", nil, "If-None-Match", `"v1"`)
+	if w.Code != http.StatusNotModified || status != http.StatusNotModified || size != 0 {
+		t.Fatalf("code = %d, reported status = %d, size = %d", w.Code, status, size)
+	}
+
+	//计算出ETag之后才返回的304
+	req := httptest.NewRequest(http.MethodGet, "/goods", nil)
+	req.Header.Set("If-None-Match", computeETag([]byte("goods"), false))
+	rec := httptest.NewRecorder()
+	ew := &etagWriter{ResponseWriter: rec, req: req, opts: &ETagOptions{MaxSize: DefaultETagMaxSize}}
+	_, _ = ew.Write([]byte("goods"))
+	ew.finish()
+	if rec.Code != http.StatusNotModified || ew.Status() != http.StatusNotModified || ew.Size() != 0 {
+		t.Fatalf("code = %d, reported status = %d, size = %d", rec.Code, ew.Status(), ew.Size())
+	}
+}
+
+func TestETag(t *testing.T) {
+	updated := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	computed := 0
+	engine := New()
+	engine.Use(ETag())
+	engine.GET("/goods", func(c *Context) {
+		c.JSON(http.StatusOK, H{"goods": []string{"apple", "pear"}})
+	})
+	engine.GET("/goods/:id", func(c *Context) {
+		if c.CheckNotModified("v"+c.Param("id"), updated) {
+			return
+		}
+		computed++
+		c.String(http.StatusOK, "goods %s", c.Param("id"))
+	})
+	engine.GET("/missing", func(c *Context) {
+		c.String(http.StatusNotFound, "missing")
+	})
+
+	w := performRequest(engine, http.MethodGet, "/goods", nil)
+	etag := w.Header().Get("ETag")
+	if w.Code != http.StatusOK || !strings.HasPrefix(etag, `"`) {
+		t.Fatalf("status = %d, etag = %q", w.Code, etag)
+	}
+	body := w.Body.String()
+	w = performRequest(engine, http.MethodGet, "/goods", nil, "If-None-Match", `"other", W/`+etag)
+	if w.Code != http.StatusNotModified || w.Header().Get("ETag") != etag || w.Header().Get("Content-Type") != "" || w.Body.Len() != 0 {
+		t.Fatalf("status = %d, header = %v, body = %q", w.Code, w.Header(), w.Body.String())
+	}
+	if w = performRequest(engine, http.MethodGet, "/goods", nil, "If-None-Match", `"other"`); w.Code != http.StatusOK || w.Body.String() != body {
+		t.Fatalf("status = %d, body = %q", w.Code, w.Body.String())
+	}
+
+	lastModified := updated.Format(http.TimeFormat)
+	w = performRequest(engine, http.MethodGet, "/goods/1", nil)
+	if w.Code != http.StatusOK || w.Header().Get("ETag") != `"v1"` || w.Header().Get("Last-Modified") != lastModified || w.Body.String() != "goods 1" {
+		t.Fatalf("status = %d, header = %v, body = %q", w.Code, w.Header(), w.Body.String())
+	}
+	tests := []struct {
+		headers []string
+		status  int
+	}{
+		{[]string{"If-None-Match", `"v1"`}, http.StatusNotModified},
+		//带有If-None-Match时忽略If-Modified-Since
+		{[]string{"If-None-Match", `"v0"`, "If-Modified-Since", lastModified}, http.StatusOK},
+		{[]string{"If-Modified-Since", lastModified}, http.StatusNotModified},
+		{[]string{"If-Modified-Since", updated.Add(-time.Second).Format(http.TimeFormat)}, http.StatusOK},
+	}
+	for _, tt := range tests {
+		w := performRequest(engine, http.MethodGet, "/goods/1", nil, tt.headers...)
+		if w.Code != tt.status || (tt.status == http.StatusNotModified) != (w.Body.Len() == 0) {
+			t.Fatalf("%v: status = %d, body = %q", tt.headers, w.Code, w.Body.String())
+		}
+	}
+	if computed != 3 {
+		t.Fatalf("handler computed %d times, want 3", computed)
+	}
+
+	w = performRequest(engine, http.MethodGet, "/missing", nil, "If-None-Match", "*")
+	if w.Code != http.StatusNotFound || w.Body.String() != "missing" || w.Header().Get("ETag") != "" {
+		t.Fatalf("status = %d, etag = %q, body = %q", w.Code, w.Header().Get("ETag"), w.Body.String())
+	}
+
+	weak := New()
+	weak.Use(ETagWithOptions(ETagOptions{Weak: true, MaxSize: 8}))
+	weak.GET("/small", func(c *Context) {
+		c.String(http.StatusOK, "small")
+	})
+	weak.GET("/large", func(c *Context) {
+		c.String(http.StatusOK, "larger than eight bytes")
+	})
+	w = performRequest(weak, http.MethodGet, "/small", nil)
+	if w.Body.String() != "small" || !strings.HasPrefix(w.Header().Get("ETag"), `W/"`) {
+		t.Fatalf("etag = %q, body = %q", w.Header().Get("ETag"), w.Body.String())
+	}
+	w = performRequest(weak, http.MethodGet, "/large", nil)
+	if w.Body.String() != "larger than eight bytes" || w.Header().Get("ETag") != "" {
+		t.Fatalf("large response got etag %q", w.Header().Get("ETag"))
+	}
+}
@@ -0,0 +1,185 @@
+package psygo
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"fmt"
+	"github.com/Psychopath-H/psyweb-master/psygo/pool"
+	"net/http"
+	"net/http/pprof"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultHealthCheckTimeout 是HealthCheck没有设置Timeout时单个检查的超时时间
+const DefaultHealthCheckTimeout = 2 * time.Second
+
+// HealthCheck 是就绪探针中的一项检查
+type HealthCheck struct {
+	Name    string                          //检查的名字，会出现在响应中
+	Check   func(ctx context.Context) error //返回nil表示检查通过，ctx在超时后会被取消
+	Timeout time.Duration                   //单个检查的超时时间，默认DefaultHealthCheckTimeout
+}
+
+// Pinger 是可以检查连接是否存活的对象，例如orm.Engine和*sql.DB
+type Pinger interface {
+	PingContext(ctx context.Context) error
+}
+
+// PingCheck 返回一个通过PingContext检查连接的HealthCheck，例如 psygo.PingCheck("db", ormEngine)
+func PingCheck(name string, p Pinger) HealthCheck {
+	return HealthCheck{Name: name, Check: p.PingContext}
+}
+
+// PoolCheck 返回一个检查协程池是否饱和的HealthCheck：协程池已经关闭，
+// 或者正在运行的协程数达到容量的maxUsage(0~1，<=0时为1)并且还有任务在排队等待时检查失败
+func PoolCheck(name string, p *pool.Pool, maxUsage float64) HealthCheck {
+	if maxUsage <= 0 || maxUsage > 1 {
+		maxUsage = 1
+	}
+	return HealthCheck{Name: name, Check: func(ctx context.Context) error {
+		if p.IsClosed() {
+			return errors.New("pool is closed")
+		}
+		capacity := p.Cap()
+		if capacity < 0 { //不限制容量的协程池不会饱和
+			return nil
+		}
+		running, waiting := p.Running(), p.Waiting()
+		if float64(running) >= float64(capacity)*maxUsage && waiting > 0 {
+			return fmt.Errorf("pool is saturated: %d/%d running, %d waiting", running, capacity, waiting)
+		}
+		return nil
+	}}
+}
+
+// healthResult 是单个检查的结果
+type healthResult struct {
+	Status   string `json:"status"`
+	Error    string `json:"error,omitempty"`
+	Duration string `json:"duration"`
+}
+
+// MountHealth 注册存活探针和就绪探针(GET和HEAD)：
+// livePath只要进程能够处理请求就返回200；readyPath并行执行所有checks，每个检查单独超时，
+// 全部通过时返回200，否则返回503，响应体中列出每个检查的结果。路径为空时不注册对应的探针
+func (engine *Engine) MountHealth(livePath, readyPath string, checks ...HealthCheck) {
+	if livePath != "" {
+		live := func(c *Context) {
+			c.JSON(http.StatusOK, H{"status": "ok"})
+		}
+		engine.GET(livePath, live)
+		engine.HEAD(livePath, live)
+	}
+	if readyPath != "" {
+		ready := func(c *Context) {
+			results, ok := runHealthChecks(c.Req.Context(), checks)
+			code, status := http.StatusOK, "ok"
+			if !ok {
+				code, status = http.StatusServiceUnavailable, "unavailable"
+			}
+			c.Header("Cache-Control", "no-store")
+			c.JSON(code, H{"status": status, "checks": results})
+		}
+		engine.GET(readyPath, ready)
+		engine.HEAD(readyPath, ready)
+	}
+}
+
+// runHealthChecks 并行执行checks，返回每个检查的结果和是否全部通过
+func runHealthChecks(ctx context.Context, checks []HealthCheck) (map[string]healthResult, bool) {
+	results := make(map[string]healthResult, len(checks))
+	var (
+		mu sync.Mutex
+		wg sync.WaitGroup
+		ok = true
+	)
+	for _, check := range checks {
+		wg.Add(1)
+		go func(check HealthCheck) {
+			defer wg.Done()
+			start := time.Now()
+			err := runHealthCheck(ctx, check)
+			result := healthResult{Status: "ok", Duration: time.Since(start).String()}
+			if err != nil {
+				result.Status = "fail"
+				result.Error = err.Error()
+			}
+			mu.Lock()
+			results[check.Name] = result
+			if err != nil {
+				ok = false
+			}
+			mu.Unlock()
+		}(check)
+	}
+	wg.Wait()
+	return results, ok
+}
+
+// runHealthCheck 在超时时间内执行一个检查，检查没有响应ctx的取消时也会在超时后直接返回
+func runHealthCheck(ctx context.Context, check HealthCheck) (err error) {
+	timeout := check.Timeout
+	if timeout <= 0 {
+		timeout = DefaultHealthCheckTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	done := make(chan error, 1)
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				done <- fmt.Errorf("panic: %v", r)
+			}
+		}()
+		done <- check.Check(ctx)
+	}()
+	select {
+	case err = <-done:
+		return err
+	case <-ctx.Done():
+		return fmt.Errorf("timed out after %s", timeout)
+	}
+}
+
+// MountPprof 在group下注册net/http/pprof和expvar的全部处理函数：
+// group前缀下的/pprof/是索引页(不带末尾斜杠的/pprof会重定向过去)，/pprof/profile、/pprof/heap等是各个profile，/vars是expvar。
+// 这些接口会暴露进程内部的信息，应当给group加上认证等中间件，例如
+//
+//	debug := engine.Group("/debug")
+//	debug.Use(psygo.BasicAuth(accounts))
+//	engine.MountPprof(debug)
+//
+// group为nil时注册在engine下。注意net/http/pprof和expvar在导入时会向http.DefaultServeMux注册/debug/pprof/和/debug/vars，
+// 使用DefaultServeMux对外提供服务(例如rpc的注册中心)时不要暴露在公网上
+func (engine *Engine) MountPprof(group *RouterGroup) {
+	if group == nil {
+		group = engine.RouterGroup
+	}
+	group.GET("/pprof/", pprofIndex)
+	group.GET("/pprof/cmdline", WrapF(pprof.Cmdline))
+	group.GET("/pprof/profile", WrapF(pprof.Profile))
+	group.GET("/pprof/symbol", WrapF(pprof.Symbol))
+	group.POST("/pprof/symbol", WrapF(pprof.Symbol))
+	group.GET("/pprof/trace", WrapF(pprof.Trace))
+	//pprof.Index只认识/debug/pprof/前缀，分组前缀不同时需要单独注册每个profile
+	for _, name := range []string{"allocs", "block", "goroutine", "heap", "mutex", "threadcreate"} {
+		group.GET("/pprof/"+name, WrapH(pprof.Handler(name)))
+	}
+	group.GET("/vars", WrapH(expvar.Handler()))
+}
+
+// pprofIndex 渲染pprof的索引页，索引页中的链接是相对路径，请求路径没有末尾的斜杠时先重定向到带斜杠的路径
+func pprofIndex(c *Context) {
+	if !strings.HasSuffix(c.Req.URL.Path, "/") {
+		target := c.Req.URL.Path + "/"
+		if c.Req.URL.RawQuery != "" {
+			target += "?" + c.Req.URL.RawQuery
+		}
+		c.Redirect(http.StatusMovedPermanently, target)
+		return
+	}
+	pprof.Index(c.Writer, c.Req)
+}
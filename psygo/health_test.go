@@ -0,0 +1,175 @@
+package psygo
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Psychopath-H/psyweb-master/psygo/pool"
+)
+
+// healthResponse 是就绪探针的响应体
+type healthResponse struct {
+	Status string                  `json:"status"`
+	Checks map[string]healthResult `json:"checks"`
+}
+
+func decodeHealth(t *testing.T, body []byte) healthResponse {
+	t.Helper()
+	var resp healthResponse
+	if err := json.Unmarshal(body, &resp); err != nil {
+		t.Fatalf("body = %s: %v", body, err)
+	}
+	return resp
+}
+
+func TestMountHealth(t *testing.T) {
+	var failing bool
+	engine := New()
+	engine.MountHealth("/livez", "/readyz",
+		HealthCheck{Name: "db", Check: func(ctx context.Context) error { return nil }},
+		HealthCheck{Name: "cache", Check: func(ctx context.Context) error {
+			if failing {
+				return errors.New("connection refused")
+			}
+			return nil
+		}},
+	)
+
+	if w := performRequest(engine, http.MethodGet, "/livez", nil); w.Code != http.StatusOK {
+		t.Fatalf("livez: status = %d", w.Code)
+	}
+	if w := performRequest(engine, http.MethodHead, "/readyz", nil); w.Code != http.StatusOK {
+		t.Fatalf("HEAD readyz: status = %d", w.Code)
+	}
+	w := performRequest(engine, http.MethodGet, "/readyz", nil)
+	resp := decodeHealth(t, w.Body.Bytes())
+	if w.Code != http.StatusOK || resp.Status != "ok" || resp.Checks["db"].Status != "ok" || resp.Checks["cache"].Status != "ok" {
+		t.Fatalf("status = %d, body = %s", w.Code, w.Body.String())
+	}
+	if w.Header().Get("Cache-Control") != "no-store" {
+		t.Fatalf("Cache-Control = %q", w.Header().Get("Cache-Control"))
+	}
+
+	//任何一个检查失败都返回503，并列出失败的原因
+	failing = true
+	w = performRequest(engine, http.MethodGet, "/readyz", nil)
+	resp = decodeHealth(t, w.Body.Bytes())
+	if w.Code != http.StatusServiceUnavailable || resp.Status != "unavailable" {
+		t.Fatalf("status = %d, body = %s", w.Code, w.Body.String())
+	}
+	if cache := resp.Checks["cache"]; cache.Status != "fail" || cache.Error != "connection refused" || resp.Checks["db"].Status != "ok" {
+		t.Fatalf("checks = %+v", resp.Checks)
+	}
+}
+
+func TestHealthChecksRunInParallelWithTimeout(t *testing.T) {
+	//两个检查都要等到对方开始才能返回，串行执行时会一直等到超时
+	started := make(chan struct{}, 2)
+	waitOther := func(ctx context.Context) error {
+		started <- struct{}{}
+		for {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			default:
+			}
+			if len(started) == 2 {
+				return nil
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+	block := make(chan struct{})
+	defer close(block)
+	engine := New()
+	engine.MountHealth("", "/readyz",
+		HealthCheck{Name: "a", Check: waitOther, Timeout: time.Second},
+		HealthCheck{Name: "b", Check: waitOther, Timeout: time.Second},
+		//不响应ctx取消的检查也会在自己的超时时间之后返回
+		HealthCheck{Name: "stuck", Check: func(ctx context.Context) error { <-block; return nil }, Timeout: 20 * time.Millisecond},
+	)
+
+	start := time.Now()
+	w := performRequest(engine, http.MethodGet, "/readyz", nil)
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Fatalf("readiness took %v", elapsed)
+	}
+	resp := decodeHealth(t, w.Body.Bytes())
+	if w.Code != http.StatusServiceUnavailable || resp.Checks["a"].Status != "ok" || resp.Checks["b"].Status != "ok" {
+		t.Fatalf("status = %d, body = %s", w.Code, w.Body.String())
+	}
+	if stuck := resp.Checks["stuck"]; stuck.Status != "fail" || !strings.Contains(stuck.Error, "timed out") {
+		t.Fatalf("stuck = %+v", stuck)
+	}
+	if w := performRequest(engine, http.MethodGet, "/livez", nil); w.Code != http.StatusNotFound {
+		t.Fatalf("empty livePath registered a route: %d", w.Code)
+	}
+}
+
+func TestPoolCheck(t *testing.T) {
+	p, err := pool.NewPool(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	check := PoolCheck("workers", p, 0)
+	if err := check.Check(context.Background()); err != nil {
+		t.Fatalf("idle pool: %v", err)
+	}
+
+	//唯一的协程在运行，并且还有任务在排队时检查失败
+	release := make(chan struct{})
+	_ = p.Submit(func() { <-release })
+	go func() { _ = p.Submit(func() {}) }()
+	waitFor(t, func() bool { return p.Waiting() == 1 })
+	if err := check.Check(context.Background()); err == nil || !strings.Contains(err.Error(), "saturated") {
+		t.Fatalf("saturated pool: %v", err)
+	}
+	close(release)
+	waitFor(t, func() bool { return p.Waiting() == 0 && p.Running() == 0 })
+	if err := check.Check(context.Background()); err != nil {
+		t.Fatalf("drained pool: %v", err)
+	}
+
+	p.Release()
+	if err := check.Check(context.Background()); err == nil || !strings.Contains(err.Error(), "closed") {
+		t.Fatalf("released pool: %v", err)
+	}
+}
+
+func TestMountPprof(t *testing.T) {
+	engine := New()
+	debug := engine.Group("/admin/debug")
+	debug.Use(BasicAuth(Accounts{"admin": "secret"}))
+	engine.MountPprof(debug)
+
+	if w := performRequest(engine, http.MethodGet, "/admin/debug/pprof/", nil); w.Code != http.StatusUnauthorized {
+		t.Fatalf("without credentials: status = %d, want 401", w.Code)
+	}
+	auth := "Basic " + "YWRtaW46c2VjcmV0" //admin:secret
+	tests := []struct {
+		path, contentType, body string
+	}{
+		{"/admin/debug/pprof/", "text/html", "heap"},
+		{"/admin/debug/pprof/heap?debug=1", "text/plain", "heap profile"},
+		{"/admin/debug/pprof/goroutine?debug=1", "text/plain", "goroutine profile"},
+		{"/admin/debug/pprof/cmdline", "text/plain", ""},
+		{"/admin/debug/vars", "application/json", "memstats"},
+	}
+	for _, tt := range tests {
+		w := performRequest(engine, http.MethodGet, tt.path, nil, "Authorization", auth)
+		if w.Code != http.StatusOK || !strings.HasPrefix(w.Header().Get("Content-Type"), tt.contentType) || !strings.Contains(w.Body.String(), tt.body) {
+			t.Fatalf("%s: status = %d, content type = %q", tt.path, w.Code, w.Header().Get("Content-Type"))
+		}
+	}
+
+	//索引页中的链接是相对路径，没有末尾斜杠时重定向
+	w := performRequest(engine, http.MethodGet, "/admin/debug/pprof?debug=1", nil, "Authorization", auth)
+	if w.Code != http.StatusMovedPermanently || w.Header().Get("Location") != "/admin/debug/pprof/?debug=1" {
+		t.Fatalf("status = %d, location = %q", w.Code, w.Header().Get("Location"))
+	}
+}
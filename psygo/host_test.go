@@ -0,0 +1,137 @@
+package psygo
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHost(t *testing.T) {
+	engine := New()
+	engine.Use(func(c *Context) {
+		c.Header("X-Global", "1")
+		c.Next()
+	})
+	engine.GET("/", func(c *Context) {
+		c.String(http.StatusOK, "default")
+	})
+	engine.GET("/only-default", func(c *Context) {
+		c.String(http.StatusOK, "only default")
+	})
+	admin := engine.Host("Admin.Example.com")
+	admin.Use(func(c *Context) {
+		c.Header("X-Admin", "1")
+		c.Next()
+	})
+	admin.GET("/", func(c *Context) {
+		c.String(http.StatusOK, "admin")
+	})
+	engine.Host(":tenant.example.com").Group("/users").GET("/:id", func(c *Context) {
+		c.String(http.StatusOK, "%s %s", c.Param("tenant"), c.Param("id"))
+	})
+	engine.Host("*.tenant.example.com").GET("/", func(c *Context) {
+		c.String(http.StatusOK, "wildcard")
+	})
+
+	tests := []struct {
+		host, target     string
+		body             string
+		global, adminHdr string
+	}{
+		{"admin.example.com:8080", "/", "admin", "1", "1"},
+		{"admin.example.com", "/only-default", "only default", "1", ""},
+		{"ADMIN.example.com.", "/", "admin", "1", "1"},
+		{"acme.example.com", "/users/7", "acme 7", "1", ""},
+		{"a.b.tenant.example.com", "/", "wildcard", "1", ""},
+		{"tenant.example.com", "/", "default", "1", ""},
+		{"api.example.com", "/", "default", "1", ""},
+		{"", "http://admin.example.com/", "admin", "1", "1"},
+	}
+	for _, tt := range tests {
+		var w *httptest.ResponseRecorder
+		if tt.host == "" {
+			w = performRequest(engine, http.MethodGet, tt.target, nil)
+		} else {
+			w = performRequest(engine, http.MethodGet, tt.target, nil, "Host", tt.host)
+		}
+		if w.Body.String() != tt.body {
+			t.Errorf("%s%s: body %q, want %q", tt.host, tt.target, w.Body.String(), tt.body)
+		}
+		if got := w.Header().Get("X-Global"); got != tt.global {
+			t.Errorf("%s%s: X-Global %q, want %q", tt.host, tt.target, got, tt.global)
+		}
+		if got := w.Header().Get("X-Admin"); got != tt.adminHdr {
+			t.Errorf("%s%s: X-Admin %q, want %q", tt.host, tt.target, got, tt.adminHdr)
+		}
+	}
+}
+
+func TestHostParamsPrecedence(t *testing.T) {
+	engine := New()
+	engine.Host(":id.:region.example.com").GET("/items/:id", func(c *Context) {
+		c.JSON(http.StatusOK, c.Params)
+	})
+	w := performRequest(engine, http.MethodGet, "/items/42", nil, "Host", "acme.eu.example.com")
+	//路径参数在前，同名的主机参数不再追加
+	if want := `[{"Key":"id","Value":"42"},{"Key":"region","Value":"eu"}]`; strings.TrimSpace(w.Body.String()) != want {
+		t.Fatalf("params %s, want %s", w.Body.String(), want)
+	}
+}
+
+func TestMatchHostParts(t *testing.T) {
+	tests := []struct {
+		rule, host string
+		ok         bool
+		params     Params
+	}{
+		{"example.com", "example.com", true, nil},
+		{"example.com", "www.example.com", false, nil},
+		{"www.example.com", "example.com", false, nil},
+		{":sub.example.com", "acme.example.com", true, Params{{Key: "sub", Value: "acme"}}},
+		{":sub.example.com", "example.com", false, nil},
+		{":sub.example.com", "a.b.example.com", false, nil},
+		{"*.example.com", "a.example.com", true, nil},
+		{"*.example.com", "a.b.example.com", true, nil},
+		{"*.example.com", "example.com", false, nil},
+		{"*.:env.example.com", "api.dev.example.com", true, Params{{Key: "env", Value: "dev"}}},
+		{"localhost", "", false, nil},
+	}
+	for _, tt := range tests {
+		parts := newRouter().hostRouter(tt.rule).hostParts
+		var params Params
+		if ok := matchHostParts(parts, tt.host, nil); ok != tt.ok {
+			t.Errorf("match(%q, %q) = %v, want %v", tt.rule, tt.host, ok, tt.ok)
+			continue
+		}
+		if !tt.ok {
+			continue
+		}
+		matchHostParts(parts, tt.host, &params)
+		if len(params) != len(tt.params) {
+			t.Errorf("match(%q, %q) params %v, want %v", tt.rule, tt.host, params, tt.params)
+			continue
+		}
+		for i := range params {
+			if params[i] != tt.params[i] {
+				t.Errorf("match(%q, %q) params %v, want %v", tt.rule, tt.host, params, tt.params)
+			}
+		}
+	}
+}
+
+func TestHostRoutingAllocs(t *testing.T) {
+	if raceEnabled {
+		t.Skip("sync.Pool drops objects under the race detector")
+	}
+	engine := New()
+	engine.GET("/", func(c *Context) {})
+	engine.Host(":tenant.example.com").GET("/users/:id", func(c *Context) {})
+	req := httptest.NewRequest(http.MethodGet, "/users/7", nil)
+	req.Host = "acme.example.com:8080"
+	w := &discardWriter{header: http.Header{}}
+	engine.ServeHTTP(w, req) //预热Context池
+	if allocs := testing.AllocsPerRun(100, func() { engine.ServeHTTP(w, req) }); allocs != 0 {
+		t.Fatalf("host routing allocates %v times per request", allocs)
+	}
+}
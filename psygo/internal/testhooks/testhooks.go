@@ -0,0 +1,35 @@
+// Package testhooks 把psygo中只给测试使用的内部操作交给psygotest，
+// 它在internal目录下，psygo之外的代码无法导入，这些操作也就不会出现在psygo对外的API中
+package testhooks
+
+import (
+	"github.com/Psychopath-H/psyweb-master/psygo/render"
+	"net/http"
+)
+
+// Hooks 是psygo注册的测试操作，C、E、H分别是*psygo.Context、*psygo.Engine和psygo.HandlerFunc，
+// 用类型参数表示是因为这个包不能导入psygo
+type Hooks[C, E, H any] struct {
+	// NewContext 返回一个属于engine、写入w的Context，它的请求是 GET /
+	NewContext func(w http.ResponseWriter, engine E) C
+	// Reset 让c改为处理req并写入w，同时清空c上一次请求留下的状态
+	Reset func(c C, w http.ResponseWriter, req *http.Request)
+	// SetFullPath 设置c匹配到的路由规则
+	SetFullPath func(c C, pattern string)
+	// Run 不经过路由，直接在c上依次执行handlers
+	Run func(c C, handlers ...H)
+	// SetRenderObserver 让engine每次通过c.Render渲染响应之前先调用observe，observe为nil时取消
+	SetRenderObserver func(engine E, observe func(c C, r render.Render))
+}
+
+var hooks any
+
+// Register 由psygo在初始化时调用
+func Register[C, E, H any](h Hooks[C, E, H]) {
+	hooks = h
+}
+
+// Get 返回psygo注册的测试操作
+func Get[C, E, H any]() Hooks[C, E, H] {
+	return hooks.(Hooks[C, E, H])
+}
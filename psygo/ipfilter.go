@@ -0,0 +1,221 @@
+package psygo
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"github.com/Psychopath-H/psyweb-master/psygo/config"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// IPFilterConfig IP黑白名单的配置，列表中可以是单个IP或CIDR，IPv4和IPv6都支持
+type IPFilterConfig struct {
+	Allow   []string    //白名单，为空表示除了黑名单之外全部放行
+	Deny    []string    //黑名单，优先级高于白名单
+	Handler HandlerFunc //请求被拒绝时的处理函数，默认返回403
+}
+
+// IPAccessList 根据ClientIP过滤请求，白名单和黑名单可以在运行时通过Reload、LoadFile或LoadConf更新，不需要重启Engine
+type IPAccessList struct {
+	rules   atomic.Pointer[ipRules]
+	handler HandlerFunc
+}
+
+// ipRules 某一时刻生效的名单，更新时整体替换，处理请求时不需要加锁
+type ipRules struct {
+	allow []*net.IPNet
+	deny  []*net.IPNet
+}
+
+// NewIPAccessList 新建一个IP访问列表，名单中有无法解析的地址时返回错误
+func NewIPAccessList(conf IPFilterConfig) (*IPAccessList, error) {
+	f := &IPAccessList{handler: conf.Handler}
+	if f.handler == nil {
+		f.handler = func(c *Context) {
+			c.Fail(http.StatusForbidden, "forbidden")
+		}
+	}
+	if err := f.Reload(conf.Allow, conf.Deny); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// IPFilter 返回一个IP过滤中间件，名单不合法时会panic，需要在运行时更新名单请使用NewIPAccessList
+func IPFilter(conf IPFilterConfig) HandlerFunc {
+	f, err := NewIPAccessList(conf)
+	if err != nil {
+		panic(err)
+	}
+	return f.Middleware()
+}
+
+// Middleware 返回使用该访问列表的中间件
+func (f *IPAccessList) Middleware() HandlerFunc {
+	return func(c *Context) {
+		if !f.Allowed(c.ClientIP()) {
+			f.handler(c)
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// Allowed 判断ip是否可以访问，无法解析的ip一律拒绝
+func (f *IPAccessList) Allowed(ip string) bool {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return false
+	}
+	rules := f.rules.Load()
+	for _, cidr := range rules.deny {
+		if cidr.Contains(parsed) {
+			return false
+		}
+	}
+	if len(rules.allow) == 0 {
+		return true
+	}
+	for _, cidr := range rules.allow {
+		if cidr.Contains(parsed) {
+			return true
+		}
+	}
+	return false
+}
+
+// Reload 替换白名单和黑名单，解析失败时保留原来的名单
+func (f *IPAccessList) Reload(allow, deny []string) error {
+	allowCIDRs, err := parseCIDRs(allow)
+	if err != nil {
+		return err
+	}
+	denyCIDRs, err := parseCIDRs(deny)
+	if err != nil {
+		return err
+	}
+	f.rules.Store(&ipRules{allow: allowCIDRs, deny: denyCIDRs})
+	return nil
+}
+
+// LoadFile 从文件中重新加载名单，文件每行一条规则，# 开头的是注释，例如
+//
+//	allow 10.0.0.0/8
+//	allow 2001:db8::/32
+//	deny  10.0.0.13
+func (f *IPAccessList) LoadFile(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	var allow, deny []string
+	scanner := bufio.NewScanner(file)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			return fmt.Errorf("psygo: %s:%d: expected \"allow|deny <cidr>\"", path, lineNo)
+		}
+		switch strings.ToLower(fields[0]) {
+		case "allow":
+			allow = append(allow, fields[1])
+		case "deny":
+			deny = append(deny, fields[1])
+		default:
+			return fmt.Errorf("psygo: %s:%d: unknown action %q", path, lineNo, fields[0])
+		}
+	}
+	if err = scanner.Err(); err != nil {
+		return err
+	}
+	return f.Reload(allow, deny)
+}
+
+// WatchFile 每隔interval检查一次文件的修改时间，文件变化后自动重新加载，返回的函数用于停止监听。
+// 修改时间的基准在返回之前取得，返回之后对文件的修改一定会被加载；stop等到监听的goroutine退出后才返回，之后不会再重新加载
+func (f *IPAccessList) WatchFile(path string, interval time.Duration) (stop func()) {
+	done, exited := make(chan struct{}), make(chan struct{})
+	var lastMod time.Time
+	if stat, err := os.Stat(path); err == nil {
+		lastMod = stat.ModTime()
+	}
+	go func() {
+		defer close(exited)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				stat, err := os.Stat(path)
+				if err != nil || !stat.ModTime().After(lastMod) {
+					continue
+				}
+				lastMod = stat.ModTime()
+				if err = f.LoadFile(path); err != nil {
+					log.Printf("psygo: reload ip filter from %s failed: %v\n", path, err)
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+	var closed atomic.Bool
+	return func() {
+		if closed.CompareAndSwap(false, true) {
+			close(done)
+		}
+		<-exited
+	}
+}
+
+// LoadConf 从config.Conf.IPFilter中重新加载名单，对应app.toml中的
+//
+//	[ipfilter]
+//	allow = ["10.0.0.0/8"]
+//	deny = ["10.0.0.13"]
+func (f *IPAccessList) LoadConf() error {
+	allow, err := confStrings(config.Conf.IPFilter["allow"])
+	if err != nil {
+		return fmt.Errorf("config ipfilter.allow: %w", err)
+	}
+	deny, err := confStrings(config.Conf.IPFilter["deny"])
+	if err != nil {
+		return fmt.Errorf("config ipfilter.deny: %w", err)
+	}
+	return f.Reload(allow, deny)
+}
+
+// confStrings 把toml解析出来的数组转换成[]string
+func confStrings(value any) ([]string, error) {
+	switch v := value.(type) {
+	case nil:
+		return nil, nil
+	case []string:
+		return v, nil
+	case []any:
+		values := make([]string, 0, len(v))
+		for _, item := range v {
+			s, ok := item.(string)
+			if !ok {
+				return nil, errors.New("must be an array of strings")
+			}
+			values = append(values, s)
+		}
+		return values, nil
+	default:
+		return nil, errors.New("must be an array of strings")
+	}
+}
@@ -0,0 +1,229 @@
+package psygo
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestIPAccessListAllowed(t *testing.T) {
+	tests := []struct {
+		name  string
+		allow []string
+		deny  []string
+		ips   map[string]bool
+	}{
+		{
+			name: "empty lists allow everything",
+			ips:  map[string]bool{"192.0.2.1": true, "2001:db8::1": true, "not an ip": false, "": false},
+		},
+		{
+			name: "deny only",
+			deny: []string{"10.0.0.13", "192.168.0.0/16"},
+			ips:  map[string]bool{"10.0.0.13": false, "10.0.0.14": true, "192.168.3.4": false},
+		},
+		{
+			name:  "allow only rejects everything else",
+			allow: []string{"10.0.0.0/8"},
+			ips:   map[string]bool{"10.1.2.3": true, "11.0.0.1": false},
+		},
+		{
+			name:  "deny takes precedence over allow",
+			allow: []string{"10.0.0.0/8"},
+			deny:  []string{"10.0.0.13", "10.9.0.0/16"},
+			ips:   map[string]bool{"10.0.0.12": true, "10.0.0.13": false, "10.9.1.1": false, "10.8.1.1": true},
+		},
+		{
+			name:  "IPv6 ranges",
+			allow: []string{"2001:db8::/32"},
+			deny:  []string{"2001:db8:dead::/48"},
+			ips:   map[string]bool{"2001:db8:1::1": true, "2001:db8:dead::1": false, "2001:db9::1": false, "10.0.0.1": false},
+		},
+		{
+			name:  "IPv4-mapped IPv6 matches IPv4 rules",
+			allow: []string{"10.0.0.0/8"},
+			ips:   map[string]bool{"::ffff:10.0.0.1": true, "::ffff:11.0.0.1": false},
+		},
+	}
+	for _, tt := range tests {
+		f, err := NewIPAccessList(IPFilterConfig{Allow: tt.allow, Deny: tt.deny})
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		for ip, want := range tt.ips {
+			if got := f.Allowed(ip); got != want {
+				t.Errorf("%s: Allowed(%q) = %v, want %v", tt.name, ip, got, want)
+			}
+		}
+	}
+}
+
+func TestIPAccessListInvalidCIDR(t *testing.T) {
+	for _, conf := range []IPFilterConfig{
+		{Allow: []string{"10.0.0.0/33"}},
+		{Deny: []string{"10.0.0.256"}},
+		{Allow: []string{"example.com"}},
+	} {
+		if _, err := NewIPAccessList(conf); err == nil {
+			t.Errorf("NewIPAccessList(%+v) should fail", conf)
+		}
+	}
+
+	f, err := NewIPAccessList(IPFilterConfig{Deny: []string{"10.0.0.13"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = f.Reload(nil, []string{"bad"}); err == nil {
+		t.Fatal("Reload should reject an invalid entry")
+	}
+	if f.Allowed("10.0.0.13") {
+		t.Fatal("a failed Reload must keep the previous rules")
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Fatal("IPFilter should panic on an invalid entry")
+		}
+	}()
+	IPFilter(IPFilterConfig{Allow: []string{"bad"}})
+}
+
+func TestIPFilterMiddleware(t *testing.T) {
+	r := New()
+	r.Use(IPFilter(IPFilterConfig{Deny: []string{"192.0.2.1"}}))
+	r.GET("/", func(c *Context) { c.String(http.StatusOK, "ok") })
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.RemoteAddr = "192.0.2.1:1234"
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("denied peer got %d", w.Code)
+	}
+
+	req.RemoteAddr = "192.0.2.2:1234"
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusOK || w.Body.String() != "ok" {
+		t.Fatalf("allowed peer got %d %q", w.Code, w.Body.String())
+	}
+
+	custom := New()
+	custom.Use(IPFilter(IPFilterConfig{
+		Allow:   []string{"10.0.0.0/8"},
+		Handler: func(c *Context) { c.String(http.StatusTeapot, "go away") },
+	}))
+	custom.GET("/", func(c *Context) { c.String(http.StatusOK, "ok") })
+	w = httptest.NewRecorder()
+	custom.ServeHTTP(w, req)
+	if w.Code != http.StatusTeapot || w.Body.String() != "go away" {
+		t.Fatalf("custom handler got %d %q", w.Code, w.Body.String())
+	}
+}
+
+func TestIPAccessListLoadFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "ipfilter.txt")
+	writeFile(t, path, `
+# office network
+allow 10.0.0.0/8
+ALLOW 2001:db8::/32
+deny  10.0.0.13   
+`)
+	f, err := NewIPAccessList(IPFilterConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = f.LoadFile(path); err != nil {
+		t.Fatal(err)
+	}
+	for ip, want := range map[string]bool{"10.0.0.1": true, "10.0.0.13": false, "2001:db8::1": true, "192.0.2.1": false} {
+		if got := f.Allowed(ip); got != want {
+			t.Errorf("Allowed(%q) = %v, want %v", ip, got, want)
+		}
+	}
+
+	for content, wantErr := range map[string]string{
+		"allow":                 ":1: expected",
+		"allow 10.0.0.1 extra":  ":1: expected",
+		"# ok\npermit 10.0.0.1": ":2: unknown action",
+		"deny 10.0.0.300":       "10.0.0.300",
+	} {
+		writeFile(t, path, content)
+		err = f.LoadFile(path)
+		if err == nil || !strings.Contains(err.Error(), wantErr) {
+			t.Errorf("LoadFile(%q) error = %v, want it to contain %q", content, err, wantErr)
+		}
+	}
+	if !f.Allowed("10.0.0.1") || f.Allowed("10.0.0.13") {
+		t.Fatal("a failed LoadFile must keep the previous rules")
+	}
+	if err = f.LoadFile(filepath.Join(dir, "missing.txt")); !os.IsNotExist(err) {
+		t.Fatalf("missing file error = %v", err)
+	}
+}
+
+func TestIPAccessListWatchFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ipfilter.txt")
+	writeFile(t, path, "deny 192.0.2.1\n")
+	f, err := NewIPAccessList(IPFilterConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = f.LoadFile(path); err != nil {
+		t.Fatal(err)
+	}
+	stop := f.WatchFile(path, 5*time.Millisecond)
+	defer stop()
+
+	//修改时间精度可能只有秒级，直接把新的修改时间设到未来
+	writeFile(t, path, "deny 192.0.2.2\n")
+	touch(t, path, time.Now().Add(time.Hour))
+	waitFor(t, func() bool { return f.Allowed("192.0.2.1") && !f.Allowed("192.0.2.2") })
+
+	//内容不合法时保留原来的名单
+	writeFile(t, path, "deny nonsense\n")
+	touch(t, path, time.Now().Add(2*time.Hour))
+	time.Sleep(50 * time.Millisecond)
+	if f.Allowed("192.0.2.2") {
+		t.Fatal("an invalid file must keep the previous rules")
+	}
+
+	stop()
+	stop() //可以重复调用
+	writeFile(t, path, "deny 192.0.2.3\n")
+	touch(t, path, time.Now().Add(3*time.Hour))
+	time.Sleep(50 * time.Millisecond)
+	if !f.Allowed("192.0.2.3") {
+		t.Fatal("the file is reloaded after stop")
+	}
+}
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func touch(t *testing.T, path string, modTime time.Time) {
+	t.Helper()
+	if err := os.Chtimes(path, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met before the deadline")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
@@ -1,11 +1,13 @@
 package psygo
 
 import (
+	"encoding/json"
 	"fmt"
 	"io"
 	"net"
 	"net/http"
 	"os"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -31,10 +33,19 @@ const (
 // DefaultWriter 是默认的输出函数
 var DefaultWriter io.Writer = os.Stdout
 
+// RequestIDHeader 是请求ID所在的头部，访问日志会优先从请求头中读取，其次是响应头
+const RequestIDHeader = "X-Request-ID"
+
 type LoggerConfig struct {
 	Formatter LoggerFormatter
 	Output    io.Writer
 	IsColor   bool
+	// SkipPaths 不记录这些路径的访问日志，例如健康检查的 /healthz
+	SkipPaths []string
+	// Skip 返回true时不记录本次请求，在处理链执行完之后调用，可以根据状态码等决定
+	Skip func(c *Context) bool
+	// UseEngineLogger 为true时访问日志写入Engine.Logger的输出器，和应用日志一起按照大小分页，此时Output不再生效
+	UseEngineLogger bool
 }
 
 type LoggerFormatter func(params *LogFormatterParams) string
@@ -48,6 +59,14 @@ type LogFormatterParams struct {
 	ClientIP   net.IP
 	Method     string
 	Path       string
+	Route      string //匹配到的路由规则，例如 /user/:id
+
+	RequestSize  int64 //请求体大小，未知时为-1
+	BodySize     int   //响应体大小
+	UserAgent    string
+	RequestID    string
+	User         string //BasicAuth认证的用户
+	ErrorMessage string //处理过程中记录在c.Errors中的错误
 
 	IsOutputColor bool
 }
@@ -67,24 +86,55 @@ func LoggerWithConfig(config LoggerConfig) HandlerFunc {
 		out = DefaultWriter
 		OutputColor = true
 	}
-	return func(c *Context) {
-		param := &LogFormatterParams{
-			Request:       c.Req,
-			IsOutputColor: OutputColor,
+	var skip map[string]struct{}
+	if len(config.SkipPaths) > 0 {
+		skip = make(map[string]struct{}, len(config.SkipPaths))
+		for _, p := range config.SkipPaths {
+			skip[p] = struct{}{}
 		}
+	}
+	return func(c *Context) {
 		// Start timer
 		start := time.Now()
 		path := c.Req.URL.Path
 		raw := c.Req.URL.RawQuery
 		//执行业务
 		c.Next()
+
+		if _, ok := skip[path]; ok {
+			return
+		}
+		if config.Skip != nil && config.Skip(c) {
+			return
+		}
+		w := out
+		param := &LogFormatterParams{
+			Request:       c.Req,
+			IsOutputColor: OutputColor,
+		}
+		if config.UseEngineLogger && c.Engine.Logger != nil {
+			w = c.Engine.Logger
+			param.IsOutputColor = false
+		}
 		// stop timer
 		param.TimeStamp = time.Now()
 		param.Latency = param.TimeStamp.Sub(start)
-		ip, _, _ := net.SplitHostPort(strings.TrimSpace(c.Req.RemoteAddr))
-		param.ClientIP = net.ParseIP(ip)
+		param.ClientIP = net.ParseIP(c.ClientIP())
 		param.Method = c.Req.Method
-		param.StatusCode = c.StatusCode
+		param.StatusCode = c.ResponseStatus()
+		param.Route = c.FullPath()
+		param.RequestSize = c.Req.ContentLength
+		param.BodySize = c.ResponseSize()
+		param.UserAgent = c.Req.UserAgent()
+		param.RequestID = c.requestID()
+		param.User = c.authUser()
+		if len(c.Errors) > 0 {
+			msgs := make([]string, 0, len(c.Errors))
+			for _, e := range c.Errors {
+				msgs = append(msgs, e.Error())
+			}
+			param.ErrorMessage = strings.Join(msgs, "; ")
+		}
 
 		if raw != "" {
 			path = path + "?" + raw
@@ -92,7 +142,7 @@ func LoggerWithConfig(config LoggerConfig) HandlerFunc {
 
 		param.Path = path
 
-		fmt.Fprint(out, formatter(param))
+		fmt.Fprint(w, formatter(param))
 	}
 }
 
@@ -159,3 +209,72 @@ var defaultLogFormatter = func(params *LogFormatterParams) string {
 		params.Path,
 	)
 }
+
+// JSONLogFormatter 以一行JSON的形式输出访问日志，方便日志系统采集
+var JSONLogFormatter = func(params *LogFormatterParams) string {
+	entry := make(map[string]any, 16)
+	entry["time"] = params.TimeStamp.Format(time.RFC3339Nano)
+	entry["status"] = params.StatusCode
+	entry["latency_ms"] = float64(params.Latency.Microseconds()) / 1000
+	entry["client_ip"] = params.ClientIP.String()
+	entry["method"] = params.Method
+	entry["path"] = params.Path
+	entry["route"] = params.Route
+	entry["req_size"] = params.RequestSize
+	entry["resp_size"] = params.BodySize
+	entry["user_agent"] = params.UserAgent
+	if params.RequestID != "" {
+		entry["request_id"] = params.RequestID
+	}
+	if params.User != "" {
+		entry["user"] = params.User
+	}
+	if params.ErrorMessage != "" {
+		entry["errors"] = params.ErrorMessage
+	}
+	b, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Sprintf("{\"error\":%q}\n", err.Error())
+	}
+	return string(b) + "\n"
+}
+
+// LogfmtLogFormatter 以logfmt(key=value)的形式输出访问日志
+var LogfmtLogFormatter = func(params *LogFormatterParams) string {
+	var sb strings.Builder
+	writeLogfmt(&sb, "time", params.TimeStamp.Format(time.RFC3339Nano))
+	writeLogfmt(&sb, "status", strconv.Itoa(params.StatusCode))
+	writeLogfmt(&sb, "latency", params.Latency.String())
+	writeLogfmt(&sb, "client_ip", params.ClientIP.String())
+	writeLogfmt(&sb, "method", params.Method)
+	writeLogfmt(&sb, "path", params.Path)
+	writeLogfmt(&sb, "route", params.Route)
+	writeLogfmt(&sb, "req_size", strconv.FormatInt(params.RequestSize, 10))
+	writeLogfmt(&sb, "resp_size", strconv.Itoa(params.BodySize))
+	writeLogfmt(&sb, "user_agent", params.UserAgent)
+	if params.RequestID != "" {
+		writeLogfmt(&sb, "request_id", params.RequestID)
+	}
+	if params.User != "" {
+		writeLogfmt(&sb, "user", params.User)
+	}
+	if params.ErrorMessage != "" {
+		writeLogfmt(&sb, "errors", params.ErrorMessage)
+	}
+	sb.WriteByte('\n')
+	return sb.String()
+}
+
+// writeLogfmt 写入一个key=value，value中有空格、引号或等号时加上引号
+func writeLogfmt(sb *strings.Builder, key, value string) {
+	if sb.Len() > 0 {
+		sb.WriteByte(' ')
+	}
+	sb.WriteString(key)
+	sb.WriteByte('=')
+	if value == "" || strings.ContainsAny(value, " \"=\t\n") {
+		sb.WriteString(strconv.Quote(value))
+		return
+	}
+	sb.WriteString(value)
+}
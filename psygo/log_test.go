@@ -0,0 +1,122 @@
+package psygo
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	psyLog "github.com/Psychopath-H/psyweb-master/psygo/logger"
+)
+
+// newLoggerEngine 返回一个使用config记录访问日志的Engine
+func newLoggerEngine(config LoggerConfig) *Engine {
+	engine := New()
+	engine.Use(LoggerWithConfig(config))
+	engine.GET("/healthz", func(c *Context) {
+		c.String(http.StatusOK, "ok")
+	})
+	engine.GET("/goods/:id", func(c *Context) {
+		_ = c.Error(errors.New("cache miss"))
+		c.String(http.StatusOK, "goods %s", c.Param("id"))
+	})
+	engine.GET("/missing", func(c *Context) {
+		c.String(http.StatusNotFound, "not found")
+	})
+	return engine
+}
+
+func TestLoggerSkip(t *testing.T) {
+	var out bytes.Buffer
+	engine := newLoggerEngine(LoggerConfig{
+		Output:    &out,
+		SkipPaths: []string{"/healthz"},
+		Skip: func(c *Context) bool {
+			return c.ResponseStatus() == http.StatusNotFound
+		},
+	})
+
+	performRequest(engine, http.MethodGet, "/healthz", nil)
+	performRequest(engine, http.MethodGet, "/missing", nil)
+	if out.Len() != 0 {
+		t.Fatalf("skipped requests were logged: %q", out.String())
+	}
+	performRequest(engine, http.MethodGet, "/goods/1?fields=name", nil)
+	if !strings.Contains(out.String(), `"/goods/1?fields=name"`) || strings.Count(out.String(), "\n") != 1 {
+		t.Fatalf("log = %q, want one line for /goods/1", out.String())
+	}
+}
+
+func TestJSONLogFormatter(t *testing.T) {
+	var out bytes.Buffer
+	engine := newLoggerEngine(LoggerConfig{Output: &out, Formatter: JSONLogFormatter})
+
+	performRequest(engine, http.MethodGet, "/goods/1?fields=name", nil, RequestIDHeader, "req-1", "User-Agent", "test-agent")
+	var entry map[string]any
+	if err := json.Unmarshal(out.Bytes(), &entry); err != nil {
+		t.Fatalf("log is not one JSON object: %q", out.String())
+	}
+	want := map[string]any{
+		"status":     float64(http.StatusOK),
+		"method":     http.MethodGet,
+		"path":       "/goods/1?fields=name",
+		"route":      "/goods/:id",
+		"client_ip":  "192.0.2.1",
+		"user_agent": "test-agent",
+		"request_id": "req-1",
+		"errors":     "cache miss",
+		"resp_size":  float64(len("goods 1")),
+	}
+	for k, v := range want {
+		if entry[k] != v {
+			t.Fatalf("%s = %v, want %v in %q", k, entry[k], v, out.String())
+		}
+	}
+	if _, ok := entry["user"]; ok {
+		t.Fatalf("unauthenticated request should not log a user: %q", out.String())
+	}
+}
+
+func TestLogfmtLogFormatter(t *testing.T) {
+	var out bytes.Buffer
+	engine := newLoggerEngine(LoggerConfig{Output: &out, Formatter: LogfmtLogFormatter})
+
+	performRequest(engine, http.MethodGet, "/goods/1", nil, "User-Agent", "test agent")
+	line := out.String()
+	for _, want := range []string{"status=200", "method=GET", "path=/goods/1", "route=/goods/:id", "client_ip=192.0.2.1", `user_agent="test agent"`, `errors="cache miss"`} {
+		if !strings.Contains(line, want) {
+			t.Fatalf("log = %q, want %s", line, want)
+		}
+	}
+	if !strings.HasSuffix(line, "\n") || strings.Count(line, "\n") != 1 {
+		t.Fatalf("log = %q, want a single line", line)
+	}
+}
+
+func TestLoggerUseEngineLogger(t *testing.T) {
+	var output, info, errs bytes.Buffer
+	engine := newLoggerEngine(LoggerConfig{Output: &output, Formatter: LogfmtLogFormatter, UseEngineLogger: true})
+	engine.Logger = psyLog.New()
+	_ = engine.Logger.SetLogWriter(psyLog.LevelInfo, &info)
+	_ = engine.Logger.SetLogWriter(psyLog.LevelError, &errs)
+
+	performRequest(engine, http.MethodGet, "/goods/1", nil)
+	if output.Len() != 0 {
+		t.Fatalf("Output should be ignored with UseEngineLogger: %q", output.String())
+	}
+	if !strings.Contains(info.String(), "route=/goods/:id") {
+		t.Fatalf("engine logger info writer = %q", info.String())
+	}
+	if errs.Len() != 0 {
+		t.Fatalf("access log reached the error writer: %q", errs.String())
+	}
+
+	//没有Engine.Logger时仍然写到Output
+	engine.Logger = nil
+	performRequest(engine, http.MethodGet, "/goods/2", nil)
+	if !strings.Contains(output.String(), "path=/goods/2") {
+		t.Fatalf("Output = %q", output.String())
+	}
+}
@@ -11,16 +11,18 @@ type JsonFormatter struct {
 }
 
 func (j *JsonFormatter) Format(param *LogFormatterParam) string {
-	if param.LoggerFields == nil {
-		param.LoggerFields = make(Fields)
+	//LoggerFields可能被多个goroutine共享，不能直接往里面写
+	fields := make(Fields, len(param.LoggerFields)+3)
+	for k, v := range param.LoggerFields {
+		fields[k] = v
 	}
 	now := time.Now()
 	if j.TimeDisplay {
-		param.LoggerFields["log_time"] = now.Format("2006/01/02 - 15:04:05")
+		fields["log_time"] = now.Format("2006/01/02 - 15:04:05")
 	}
-	param.LoggerFields["msg"] = param.Msg
-	param.LoggerFields["log_level"] = param.Level.Level()
-	marshal, err := json.Marshal(param.LoggerFields)
+	fields["msg"] = param.Msg
+	fields["log_level"] = param.Level.Level()
+	marshal, err := json.Marshal(fields)
 	if err != nil {
 		panic(err)
 	}
@@ -9,6 +9,7 @@ import (
 	"os"
 	"path"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -51,10 +52,16 @@ type LogFormatterParam struct {
 }
 
 type LogWriter struct {
+	mu     sync.Mutex //多个子Logger共享同一个输出器，写入和分页时需要加锁
 	level  LoggerLevel
 	writer io.Writer
 }
 
+// admits 判断输出器是否接受level级别的日志：全打印输出器(级别为-1)接受所有日志，其余的只接受和自己同级别的日志
+func (w *LogWriter) admits(level LoggerLevel) bool {
+	return w.level == -1 || w.level == level
+}
+
 type Fields map[string]any
 
 // Logger 是给框架使用者提供的一个日志记录工具，偏于开发者使用其进行调试，记录日志信息
@@ -136,44 +143,93 @@ func (l *Logger) Print(level LoggerLevel, msg any) error {
 	var err error
 	str := l.Formatter.Format(param)
 	for _, logWriter := range l.LogWriters {
+		logWriter.mu.Lock()
 		if logWriter.writer == os.Stdout { //承接上面,如果使用了本框架提供的日志工具，那么默认插入的LogWriter一定会在控制台打印输出,或者设置的io.Writer是在控制台输出的，那无论输出等级，全部打印
 			param.IsOutputColor = true
 			str = l.Formatter.Format(param)
 			_, _ = fmt.Fprintln(logWriter.writer, str)
+			logWriter.mu.Unlock()
 			continue
 		}
-		if logWriter.level == -1 || level == logWriter.level { //本日志里的这个输出器要么是全打印输出器，或者打印器的级别等于本次日志调试等级
+		if logWriter.admits(level) { //本日志里的这个输出器要么是全打印输出器，或者打印器的级别等于本次日志调试等级
 			_, err = fmt.Fprintln(logWriter.writer, str)
 			l.CheckFileSize(logWriter)
 		}
+		logWriter.mu.Unlock()
 	}
 	return err
 }
 
-// WithFields 返回一个带有字段的新Logger
+// WithFields 返回一个带有字段的子Logger，子Logger的字段是当前字段和fields合并后的副本，
+// 它和当前Logger共享日志输出器，但不会修改当前Logger，可以在多个goroutine中各自派生使用
 func (l *Logger) WithFields(fields Fields) *Logger {
-	l.LoggerFields = fields
-	return l
+	child := *l
+	child.LoggerFields = make(Fields, len(l.LoggerFields)+len(fields))
+	for k, v := range l.LoggerFields {
+		child.LoggerFields[k] = v
+	}
+	for k, v := range fields {
+		child.LoggerFields[k] = v
+	}
+	return &child
+}
+
+// WithField 返回一个增加了一个字段的子Logger
+func (l *Logger) WithField(key string, value any) *Logger {
+	return l.WithFields(Fields{key: value})
 }
 
-// CheckFileSize 判断对应文件的大小
+// writeLevel 是通过Write写入的内容的日志级别，psygo的访问日志按照Info级别处理
+const writeLevel = LevelInfo
+
+// Write 实现了io.Writer，把p原样(不经过Formatter)按照writeLevel写入日志输出器，写入文件的输出器同样会按照大小进行分页，
+// 这样psygo的访问日志等已经格式化好的内容也可以和应用日志写到一起。
+// 和Print一样，Logger的级别高于writeLevel时什么也不写，只有控制台、全打印和Info级别的输出器会写入
+func (l *Logger) Write(p []byte) (int, error) {
+	if l.Level > writeLevel {
+		return len(p), nil
+	}
+	for _, logWriter := range l.LogWriters {
+		logWriter.mu.Lock()
+		if logWriter.writer != os.Stdout && !logWriter.admits(writeLevel) { //分页时会替换writer，需要在锁内判断
+			logWriter.mu.Unlock()
+			continue
+		}
+		_, err := logWriter.writer.Write(p)
+		if err == nil && logWriter.writer != os.Stdout {
+			l.CheckFileSize(logWriter)
+		}
+		logWriter.mu.Unlock()
+		if err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+// CheckFileSize 判断对应文件的大小，调用方需要持有w的锁
 func (l *Logger) CheckFileSize(w *LogWriter) {
-	logFile := w.writer.(*os.File)
-	if logFile != nil {
+	logFile, ok := w.writer.(*os.File) //通过SetLogWriter设置的输出器不一定是文件
+	if ok && logFile != nil {
 		stat, err := logFile.Stat()
 		if err != nil {
 			log.Println(err)
 			return
 		}
 		size := stat.Size()
-		if l.LogFileSize <= 0 {
-			l.LogFileSize = 100 << 20 //100M
+		maxSize := l.LogFileSize
+		if maxSize <= 0 {
+			maxSize = 100 << 20 //100M
 		}
-		if size >= l.LogFileSize {
+		if size >= maxSize {
 			_, name := path.Split(stat.Name())           //分离目录和文件
 			fileName := name[0:strings.Index(name, ".")] //把文件名字提取出来
 			writer := FileWriter(path.Join(l.LogPath, psystrings.JoinStrings(fileName, ".", time.Now().UnixMilli(), ".log")))
+			if f, ok := writer.(*os.File); !ok || f == nil { //新文件打开失败时继续写旧文件
+				return
+			}
 			w.writer = writer //换个新的io.Writer流
+			_ = logFile.Close()
 		}
 
 	}
@@ -0,0 +1,96 @@
+package logger
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestLoggerConcurrentWrite(t *testing.T) {
+	dir := t.TempDir()
+	l := New()
+	l.Level = LevelDebug
+	l.Formatter = &TextFormatter{}
+	l.LogFileSize = 512 //很小的分页大小，让分页和写入并发发生
+	l.SetLogWriterOnFile(dir, "access.log", -1)
+
+	const goroutines, lines = 8, 50
+	var wg sync.WaitGroup
+	for i := 0; i < goroutines; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < lines; j++ {
+				if _, err := l.Write([]byte("raw access log line\n")); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			child := l.WithField("worker", "print")
+			for j := 0; j < lines; j++ {
+				_ = child.Info("formatted line")
+			}
+		}()
+	}
+	wg.Wait()
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) < 2 {
+		t.Fatalf("expected the log to be rotated, got %v", files)
+	}
+	raw, formatted := 0, 0
+	for _, name := range files {
+		data, err := os.ReadFile(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, line := range bytes.Split(bytes.TrimSuffix(data, []byte("\n")), []byte("\n")) {
+			switch {
+			case string(line) == "raw access log line":
+				raw++
+			case strings.Contains(string(line), "formatted line"):
+				formatted++
+			default:
+				t.Fatalf("interleaved line %q in %s", line, name)
+			}
+		}
+	}
+	if raw != goroutines*lines || formatted != goroutines*lines {
+		t.Fatalf("got %d raw and %d formatted lines, want %d each", raw, formatted, goroutines*lines)
+	}
+}
+
+func TestLoggerWriteLevels(t *testing.T) {
+	var all, info, errs bytes.Buffer
+	l := New()
+	l.Level = LevelDebug
+	_ = l.SetLogWriter(-1, &all)
+	_ = l.SetLogWriter(LevelInfo, &info)
+	_ = l.SetLogWriter(LevelError, &errs)
+
+	if n, err := l.Write([]byte("GET /goods 200\n")); err != nil || n != len("GET /goods 200\n") {
+		t.Fatalf("Write = %d, %v", n, err)
+	}
+	if all.String() != "GET /goods 200\n" || info.String() != "GET /goods 200\n" {
+		t.Fatalf("all = %q, info = %q", all.String(), info.String())
+	}
+	//访问日志按照Info级别处理，不会写进只记录错误的输出器
+	if errs.Len() != 0 {
+		t.Fatalf("error writer got %q", errs.String())
+	}
+
+	l.Level = LevelError
+	all.Reset()
+	if _, err := l.Write([]byte("GET /goods 200\n")); err != nil || all.Len() != 0 {
+		t.Fatalf("logger above Info level wrote %q, %v", all.String(), err)
+	}
+}
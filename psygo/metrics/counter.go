@@ -0,0 +1,68 @@
+package metrics
+
+// Counter 是只增不减的计数器，例如请求总数
+type Counter struct {
+	value  atomicFloat
+	family *CounterVec
+}
+
+// NewCounter 新建一个没有标签的计数器
+func NewCounter(opts Opts) *Counter {
+	return NewCounterVec(opts).WithLabelValues()
+}
+
+// Inc 加1
+func (c *Counter) Inc() {
+	c.value.Add(1)
+}
+
+// Add 加上v，v不能是负数
+func (c *Counter) Add(v float64) {
+	if v < 0 {
+		panic("metrics: counter cannot decrease")
+	}
+	c.value.Add(v)
+}
+
+// Value 返回当前的值
+func (c *Counter) Value() float64 {
+	return c.value.Load()
+}
+
+func (c *Counter) Describe() []string {
+	return c.family.Describe()
+}
+
+func (c *Counter) Collect() []*Family {
+	return c.family.Collect()
+}
+
+// CounterVec 是一组标签名相同的计数器
+type CounterVec struct {
+	*vec[*Counter]
+}
+
+// NewCounterVec 新建一组以labelNames为标签的计数器
+func NewCounterVec(opts Opts, labelNames ...string) *CounterVec {
+	v := &CounterVec{}
+	v.vec = newVec(opts, labelNames, func() *Counter { return &Counter{family: v} })
+	return v
+}
+
+// WithLabelValues 返回标签值对应的计数器，values的顺序和labelNames相同
+func (v *CounterVec) WithLabelValues(values ...string) *Counter {
+	return v.with(values)
+}
+
+// DeleteLabelValues 删除标签值对应的计数器
+func (v *CounterVec) DeleteLabelValues(values ...string) bool {
+	return v.delete(values)
+}
+
+func (v *CounterVec) Collect() []*Family {
+	family := &Family{Name: v.opts.Name, Help: v.opts.Help, Type: TypeCounter}
+	for _, c := range v.sorted() {
+		family.Samples = append(family.Samples, Sample{Labels: c.labels, Value: c.metric.Value()})
+	}
+	return []*Family{family}
+}
@@ -0,0 +1,140 @@
+package metrics
+
+import (
+	"sort"
+	"sync"
+)
+
+// Gauge 是可增可减的指标，例如正在处理的请求数
+type Gauge struct {
+	value  atomicFloat
+	family *GaugeVec
+}
+
+// NewGauge 新建一个没有标签的Gauge
+func NewGauge(opts Opts) *Gauge {
+	return NewGaugeVec(opts).WithLabelValues()
+}
+
+func (g *Gauge) Set(v float64) {
+	g.value.Set(v)
+}
+
+func (g *Gauge) Inc() {
+	g.value.Add(1)
+}
+
+func (g *Gauge) Dec() {
+	g.value.Add(-1)
+}
+
+func (g *Gauge) Add(v float64) {
+	g.value.Add(v)
+}
+
+func (g *Gauge) Sub(v float64) {
+	g.value.Add(-v)
+}
+
+// Value 返回当前的值
+func (g *Gauge) Value() float64 {
+	return g.value.Load()
+}
+
+func (g *Gauge) Describe() []string {
+	return g.family.Describe()
+}
+
+func (g *Gauge) Collect() []*Family {
+	return g.family.Collect()
+}
+
+// GaugeVec 是一组标签名相同的Gauge
+type GaugeVec struct {
+	*vec[*Gauge]
+}
+
+// NewGaugeVec 新建一组以labelNames为标签的Gauge
+func NewGaugeVec(opts Opts, labelNames ...string) *GaugeVec {
+	v := &GaugeVec{}
+	v.vec = newVec(opts, labelNames, func() *Gauge { return &Gauge{family: v} })
+	return v
+}
+
+// WithLabelValues 返回标签值对应的Gauge，values的顺序和labelNames相同
+func (v *GaugeVec) WithLabelValues(values ...string) *Gauge {
+	return v.with(values)
+}
+
+// DeleteLabelValues 删除标签值对应的Gauge
+func (v *GaugeVec) DeleteLabelValues(values ...string) bool {
+	return v.delete(values)
+}
+
+func (v *GaugeVec) Collect() []*Family {
+	family := &Family{Name: v.opts.Name, Help: v.opts.Help, Type: TypeGauge}
+	for _, c := range v.sorted() {
+		family.Samples = append(family.Samples, Sample{Labels: c.labels, Value: c.metric.Value()})
+	}
+	return []*Family{family}
+}
+
+// GaugeFunc 在每次输出时调用函数取值的Gauge，适合协程池大小这种本身就保存在别处的值
+type GaugeFunc struct {
+	opts      Opts
+	labelName string
+
+	mu    sync.RWMutex
+	funcs map[string]func() float64
+}
+
+// NewGaugeFunc 新建一个没有标签、通过fn取值的Gauge
+func NewGaugeFunc(opts Opts, fn func() float64) *GaugeFunc {
+	checkName(opts.Name, nil)
+	return &GaugeFunc{opts: opts, funcs: map[string]func() float64{"": fn}}
+}
+
+// NewGaugeFuncVec 新建一个只有一个标签labelName的GaugeFunc，之后通过Set为每个标签值设置取值函数
+func NewGaugeFuncVec(opts Opts, labelName string) *GaugeFunc {
+	checkName(opts.Name, []string{labelName})
+	return &GaugeFunc{opts: opts, labelName: labelName, funcs: make(map[string]func() float64)}
+}
+
+// Set 设置标签值对应的取值函数，fn为nil时删除它
+func (g *GaugeFunc) Set(labelValue string, fn func() float64) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if fn == nil {
+		delete(g.funcs, labelValue)
+		return
+	}
+	g.funcs[labelValue] = fn
+}
+
+func (g *GaugeFunc) Describe() []string {
+	return []string{g.opts.Name}
+}
+
+func (g *GaugeFunc) Collect() []*Family {
+	g.mu.RLock()
+	values := make([]string, 0, len(g.funcs))
+	for value := range g.funcs {
+		values = append(values, value)
+	}
+	funcs := make(map[string]func() float64, len(g.funcs))
+	for k, fn := range g.funcs {
+		funcs[k] = fn
+	}
+	g.mu.RUnlock()
+	sort.Strings(values)
+
+	family := &Family{Name: g.opts.Name, Help: g.opts.Help, Type: TypeGauge}
+	for _, value := range values {
+		var labels []Label
+		if g.labelName != "" {
+			labels = []Label{{Name: g.labelName, Value: value}}
+		}
+		family.Samples = append(family.Samples, Sample{Labels: labels, Value: funcs[value]()})
+	}
+	return []*Family{family}
+}
@@ -0,0 +1,131 @@
+package metrics
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"sync/atomic"
+)
+
+// DefBuckets 是默认的直方图桶，适合以秒为单位的网络请求耗时
+var DefBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// SizeBuckets 适合以字节为单位的请求和响应大小
+var SizeBuckets = []float64{100, 1000, 10000, 100000, 1e6, 1e7, 1e8}
+
+// HistogramOpts 描述一个直方图族
+type HistogramOpts struct {
+	Name    string
+	Help    string
+	Buckets []float64 //各个桶的上界，必须递增，不需要包含+Inf，默认DefBuckets
+}
+
+// Histogram 统计观测值的分布，例如请求耗时
+type Histogram struct {
+	upperBounds []float64
+	counts      []uint64 //每个桶(不累加)的观测次数，最后一个是+Inf
+	count       uint64
+	sum         atomicFloat
+	family      *HistogramVec
+}
+
+// NewHistogram 新建一个没有标签的直方图
+func NewHistogram(opts HistogramOpts) *Histogram {
+	return NewHistogramVec(opts).WithLabelValues()
+}
+
+// Observe 记录一个观测值
+func (h *Histogram) Observe(v float64) {
+	i := sort.SearchFloat64s(h.upperBounds, v)
+	atomic.AddUint64(&h.counts[i], 1)
+	h.sum.Add(v)
+	atomic.AddUint64(&h.count, 1)
+}
+
+// Count 返回观测的次数
+func (h *Histogram) Count() uint64 {
+	return atomic.LoadUint64(&h.count)
+}
+
+// Sum 返回观测值的总和
+func (h *Histogram) Sum() float64 {
+	return h.sum.Load()
+}
+
+func (h *Histogram) Describe() []string {
+	return h.family.Describe()
+}
+
+func (h *Histogram) Collect() []*Family {
+	return h.family.Collect()
+}
+
+// samples 生成直方图的_bucket、_sum和_count样本，桶的计数是累加的
+func (h *Histogram) samples(labels []Label) []Sample {
+	samples := make([]Sample, 0, len(h.counts)+2)
+	var cumulative uint64
+	for i := range h.counts {
+		bound := math.Inf(1)
+		if i < len(h.upperBounds) {
+			bound = h.upperBounds[i]
+		}
+		cumulative += atomic.LoadUint64(&h.counts[i])
+		bucketLabels := make([]Label, len(labels), len(labels)+1)
+		copy(bucketLabels, labels)
+		bucketLabels = append(bucketLabels, Label{Name: "le", Value: formatFloat(bound)})
+		samples = append(samples, Sample{Suffix: "_bucket", Labels: bucketLabels, Value: float64(cumulative)})
+	}
+	//并发观测时_count可能比最后一个桶稍大，以桶的累加值为准，保证输出自洽
+	samples = append(samples,
+		Sample{Suffix: "_sum", Labels: labels, Value: h.Sum()},
+		Sample{Suffix: "_count", Labels: labels, Value: float64(cumulative)},
+	)
+	return samples
+}
+
+// HistogramVec 是一组标签名和桶都相同的直方图
+type HistogramVec struct {
+	*vec[*Histogram]
+}
+
+// NewHistogramVec 新建一组以labelNames为标签的直方图
+func NewHistogramVec(opts HistogramOpts, labelNames ...string) *HistogramVec {
+	buckets := opts.Buckets
+	if len(buckets) == 0 {
+		buckets = DefBuckets
+	}
+	for i := 1; i < len(buckets); i++ {
+		if buckets[i] <= buckets[i-1] {
+			panic(fmt.Sprintf("metrics: buckets of %s must be in increasing order", opts.Name))
+		}
+	}
+	for _, label := range labelNames {
+		if label == "le" {
+			panic(fmt.Sprintf("metrics: histogram %s cannot use label le", opts.Name))
+		}
+	}
+	upperBounds := append([]float64(nil), buckets...)
+	v := &HistogramVec{}
+	v.vec = newVec(Opts{Name: opts.Name, Help: opts.Help}, labelNames, func() *Histogram {
+		return &Histogram{upperBounds: upperBounds, counts: make([]uint64, len(upperBounds)+1), family: v}
+	})
+	return v
+}
+
+// WithLabelValues 返回标签值对应的直方图，values的顺序和labelNames相同
+func (v *HistogramVec) WithLabelValues(values ...string) *Histogram {
+	return v.with(values)
+}
+
+// DeleteLabelValues 删除标签值对应的直方图
+func (v *HistogramVec) DeleteLabelValues(values ...string) bool {
+	return v.delete(values)
+}
+
+func (v *HistogramVec) Collect() []*Family {
+	family := &Family{Name: v.opts.Name, Help: v.opts.Help, Type: TypeHistogram}
+	for _, c := range v.sorted() {
+		family.Samples = append(family.Samples, c.metric.samples(c.labels)...)
+	}
+	return []*Family{family}
+}
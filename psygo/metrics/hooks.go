@@ -0,0 +1,112 @@
+package metrics
+
+import (
+	"github.com/Psychopath-H/psyweb-master/psygo/pool"
+	"strings"
+	"time"
+)
+
+// RegisterPool 在每次输出时读取协程池p的状态，指标为
+// psygo_pool_running{pool}、psygo_pool_waiting{pool}和psygo_pool_capacity{pool}，
+// 同一个name再次注册时会替换之前的协程池
+func RegisterPool(r *Registry, name string, p *pool.Pool) {
+	running := registerOrGet(r, NewGaugeFuncVec(Opts{
+		Name: "psygo_pool_running",
+		Help: "Number of running workers in the pool.",
+	}, "pool"))
+	waiting := registerOrGet(r, NewGaugeFuncVec(Opts{
+		Name: "psygo_pool_waiting",
+		Help: "Number of tasks waiting for a worker in the pool.",
+	}, "pool"))
+	capacity := registerOrGet(r, NewGaugeFuncVec(Opts{
+		Name: "psygo_pool_capacity",
+		Help: "Capacity of the pool, -1 means unlimited.",
+	}, "pool"))
+	running.Set(name, func() float64 { return float64(p.Running()) })
+	waiting.Set(name, func() float64 { return float64(p.Waiting()) })
+	capacity.Set(name, func() float64 { return float64(p.Cap()) })
+}
+
+// ORMQueryHook 返回记录SQL执行耗时的钩子函数，它和orm的session.QueryHook签名相同，例如
+//
+//	ormEngine.SetQueryHook(metrics.ORMQueryHook(metrics.DefaultRegistry))
+//
+// 指标为 psygo_orm_query_duration_seconds{operation} 和 psygo_orm_query_errors_total{operation}，
+// operation是SQL的第一个关键字(SELECT、INSERT等)，而不是完整的SQL
+func ORMQueryHook(r *Registry) func(sql string, duration time.Duration, err error) {
+	duration := registerOrGet(r, NewHistogramVec(HistogramOpts{
+		Name: "psygo_orm_query_duration_seconds",
+		Help: "ORM query latency in seconds by sql operation.",
+	}, "operation"))
+	errs := registerOrGet(r, NewCounterVec(Opts{
+		Name: "psygo_orm_query_errors_total",
+		Help: "Total number of failed ORM queries by sql operation.",
+	}, "operation"))
+	return func(sql string, d time.Duration, err error) {
+		operation := sqlOperation(sql)
+		duration.WithLabelValues(operation).Observe(d.Seconds())
+		if err != nil {
+			errs.WithLabelValues(operation).Inc()
+		}
+	}
+}
+
+// sqlOperation 返回SQL的第一个关键字，不认识的都归为OTHER
+func sqlOperation(sql string) string {
+	fields := strings.Fields(sql)
+	if len(fields) == 0 {
+		return "OTHER"
+	}
+	operation := strings.ToUpper(fields[0])
+	switch operation {
+	case "SELECT", "INSERT", "UPDATE", "DELETE", "CREATE", "DROP", "ALTER", "REPLACE":
+		return operation
+	default:
+		return "OTHER"
+	}
+}
+
+// RPCServerHook 返回记录RPC服务端调用的钩子函数，它和rpc.CallHook签名相同，例如 server.OnCall = metrics.RPCServerHook(reg)，
+// 指标为 psygo_rpc_server_calls_total{method}、psygo_rpc_server_errors_total{method} 和 psygo_rpc_server_duration_seconds{method}
+func RPCServerHook(r *Registry) func(serviceMethod string, duration time.Duration, err error) {
+	return rpcHook(r, "server")
+}
+
+// RPCClientHook 返回记录RPC客户端调用的钩子函数，设置在rpc.Option.OnCall上，
+// 指标为 psygo_rpc_client_calls_total{method}、psygo_rpc_client_errors_total{method} 和 psygo_rpc_client_duration_seconds{method}
+func RPCClientHook(r *Registry) func(serviceMethod string, duration time.Duration, err error) {
+	return rpcHook(r, "client")
+}
+
+func rpcHook(r *Registry, side string) func(serviceMethod string, duration time.Duration, err error) {
+	calls := registerOrGet(r, NewCounterVec(Opts{
+		Name: "psygo_rpc_" + side + "_calls_total",
+		Help: "Total number of RPC " + side + " calls by service method.",
+	}, "method"))
+	errs := registerOrGet(r, NewCounterVec(Opts{
+		Name: "psygo_rpc_" + side + "_errors_total",
+		Help: "Total number of failed RPC " + side + " calls by service method.",
+	}, "method"))
+	duration := registerOrGet(r, NewHistogramVec(HistogramOpts{
+		Name: "psygo_rpc_" + side + "_duration_seconds",
+		Help: "RPC " + side + " call latency in seconds by service method.",
+	}, "method"))
+	return func(serviceMethod string, d time.Duration, err error) {
+		calls.WithLabelValues(serviceMethod).Inc()
+		duration.WithLabelValues(serviceMethod).Observe(d.Seconds())
+		if err != nil {
+			errs.WithLabelValues(serviceMethod).Inc()
+		}
+	}
+}
+
+// RegisterBreaker 在每次输出时通过state读取熔断器的状态，指标为 psygo_rpc_breaker_state{breaker}，
+// 0表示关闭、1表示半开、2表示打开，和rpc/breaker的State取值相同，例如
+//
+//	metrics.RegisterBreaker(reg, cb.Name(), func() float64 { return float64(cb.State()) })
+func RegisterBreaker(r *Registry, name string, state func() float64) {
+	registerOrGet(r, NewGaugeFuncVec(Opts{
+		Name: "psygo_rpc_breaker_state",
+		Help: "State of the RPC circuit breaker: 0 closed, 1 half-open, 2 open.",
+	}, "breaker")).Set(name, state)
+}
@@ -0,0 +1,95 @@
+package metrics
+
+import (
+	"github.com/Psychopath-H/psyweb-master/psygo"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// unmatchedRoute 是没有匹配到路由的请求使用的route标签，避免把任意路径都变成标签值
+const unmatchedRoute = "unmatched"
+
+// otherMethod 是非标准方法使用的method标签，客户端可以发送任意方法名，不能直接作为标签值
+const otherMethod = "OTHER"
+
+// standardMethods 是原样作为method标签的方法
+var standardMethods = map[string]bool{
+	http.MethodGet:     true,
+	http.MethodHead:    true,
+	http.MethodPost:    true,
+	http.MethodPut:     true,
+	http.MethodPatch:   true,
+	http.MethodDelete:  true,
+	http.MethodConnect: true,
+	http.MethodOptions: true,
+	http.MethodTrace:   true,
+}
+
+// httpMetrics 是HTTP中间件记录的指标
+type httpMetrics struct {
+	requests     *CounterVec
+	duration     *HistogramVec
+	requestSize  *HistogramVec
+	responseSize *HistogramVec
+	inFlight     *Gauge
+}
+
+// Middleware 返回记录HTTP请求指标的中间件，标签route使用路由规则(例如/goods/:id)而不是实际路径，
+// 非标准的请求方法记为OTHER，记录的指标有：
+//   - psygo_http_requests_total{method,route,code}
+//   - psygo_http_request_duration_seconds{method,route}
+//   - psygo_http_request_size_bytes{method,route}
+//   - psygo_http_response_size_bytes{method,route}
+//   - psygo_http_requests_in_flight
+func Middleware(r *Registry) psygo.HandlerFunc {
+	m := &httpMetrics{
+		requests: registerOrGet(r, NewCounterVec(Opts{
+			Name: "psygo_http_requests_total",
+			Help: "Total number of HTTP requests by method, route pattern and status code.",
+		}, "method", "route", "code")),
+		duration: registerOrGet(r, NewHistogramVec(HistogramOpts{
+			Name: "psygo_http_request_duration_seconds",
+			Help: "HTTP request latency in seconds.",
+		}, "method", "route")),
+		requestSize: registerOrGet(r, NewHistogramVec(HistogramOpts{
+			Name:    "psygo_http_request_size_bytes",
+			Help:    "HTTP request body size in bytes.",
+			Buckets: SizeBuckets,
+		}, "method", "route")),
+		responseSize: registerOrGet(r, NewHistogramVec(HistogramOpts{
+			Name:    "psygo_http_response_size_bytes",
+			Help:    "HTTP response body size in bytes.",
+			Buckets: SizeBuckets,
+		}, "method", "route")),
+		inFlight: registerOrGet(r, NewGauge(Opts{
+			Name: "psygo_http_requests_in_flight",
+			Help: "Number of HTTP requests currently being served.",
+		})),
+	}
+	return func(c *psygo.Context) {
+		start := time.Now()
+		m.inFlight.Inc()
+		defer m.inFlight.Dec()
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = unmatchedRoute
+		}
+		method := c.Req.Method
+		if !standardMethods[method] {
+			method = otherMethod
+		}
+		code := c.ResponseStatus()
+		if code == 0 { //处理函数什么都没有写时net/http返回200
+			code = http.StatusOK
+		}
+		m.requests.WithLabelValues(method, route, strconv.Itoa(code)).Inc()
+		m.duration.WithLabelValues(method, route).Observe(time.Since(start).Seconds())
+		if c.Req.ContentLength >= 0 {
+			m.requestSize.WithLabelValues(method, route).Observe(float64(c.Req.ContentLength))
+		}
+		m.responseSize.WithLabelValues(method, route).Observe(float64(c.ResponseSize()))
+	}
+}
@@ -0,0 +1,192 @@
+// Package metrics 是一个不依赖第三方库的指标收集工具，提供Counter、Gauge和Histogram，
+// 并以Prometheus文本格式(text exposition format 0.0.4)输出，例如
+//
+//	engine.Use(metrics.Middleware(metrics.DefaultRegistry))
+//	engine.GET("/metrics", metrics.Handler(metrics.DefaultRegistry))
+//
+// 协程池、ORM和RPC的指标分别通过RegisterPool、ORMQueryHook、RPCServerHook、RPCClientHook和RegisterBreaker接入
+package metrics
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// 指标族的类型
+const (
+	TypeCounter   = "counter"
+	TypeGauge     = "gauge"
+	TypeHistogram = "histogram"
+)
+
+// Opts 描述一个指标族
+type Opts struct {
+	Name string //指标名，只能包含字母、数字、下划线和冒号，不能以数字开头
+	Help string //指标的说明
+}
+
+// Collector 是可以注册到Registry中的指标来源
+type Collector interface {
+	// Describe 返回它会输出的全部指标族的名字
+	Describe() []string
+	// Collect 返回当前的指标数据
+	Collect() []*Family
+}
+
+// Family 是一个指标族在某一时刻的数据
+type Family struct {
+	Name    string
+	Help    string
+	Type    string
+	Samples []Sample
+}
+
+// Sample 是指标族中的一个样本，Suffix用于直方图的_bucket、_sum和_count
+type Sample struct {
+	Suffix string
+	Labels []Label
+	Value  float64
+}
+
+// Label 是一个标签
+type Label struct {
+	Name  string
+	Value string
+}
+
+var nameRE = regexp.MustCompile(`^[a-zA-Z_:][a-zA-Z0-9_:]*$`)
+
+// checkName 检查指标名和标签名是否合法，不合法时panic，这种错误应该在程序启动时就暴露出来
+func checkName(name string, labelNames []string) {
+	if !nameRE.MatchString(name) {
+		panic(fmt.Sprintf("metrics: invalid metric name %q", name))
+	}
+	for _, label := range labelNames {
+		if !nameRE.MatchString(label) || strings.Contains(label, ":") || strings.HasPrefix(label, "__") {
+			panic(fmt.Sprintf("metrics: invalid label name %q of %s", label, name))
+		}
+	}
+}
+
+// atomicFloat 是可以并发修改的float64
+type atomicFloat struct {
+	bits uint64
+}
+
+func (f *atomicFloat) Add(v float64) {
+	for {
+		old := atomic.LoadUint64(&f.bits)
+		next := math.Float64bits(math.Float64frombits(old) + v)
+		if atomic.CompareAndSwapUint64(&f.bits, old, next) {
+			return
+		}
+	}
+}
+
+func (f *atomicFloat) Set(v float64) {
+	atomic.StoreUint64(&f.bits, math.Float64bits(v))
+}
+
+func (f *atomicFloat) Load() float64 {
+	return math.Float64frombits(atomic.LoadUint64(&f.bits))
+}
+
+// vec 是带标签指标的公共部分，按照标签值保存每个子指标
+type vec[T any] struct {
+	opts       Opts
+	labelNames []string
+	newMetric  func() T
+
+	mu       sync.RWMutex
+	children map[string]*child[T]
+}
+
+type child[T any] struct {
+	labels []Label
+	metric T
+}
+
+func newVec[T any](opts Opts, labelNames []string, newMetric func() T) *vec[T] {
+	checkName(opts.Name, labelNames)
+	return &vec[T]{
+		opts:       opts,
+		labelNames: labelNames,
+		newMetric:  newMetric,
+		children:   make(map[string]*child[T]),
+	}
+}
+
+// with 返回标签值对应的子指标，不存在时创建，标签值的数量和标签名不一致时panic
+func (v *vec[T]) with(values []string) T {
+	if len(values) != len(v.labelNames) {
+		panic(fmt.Sprintf("metrics: %s expects %d label values, got %d", v.opts.Name, len(v.labelNames), len(values)))
+	}
+	key := strings.Join(values, "\xff")
+	v.mu.RLock()
+	c, ok := v.children[key]
+	v.mu.RUnlock()
+	if ok {
+		return c.metric
+	}
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if c, ok = v.children[key]; ok {
+		return c.metric
+	}
+	labels := make([]Label, len(values))
+	for i, value := range values {
+		labels[i] = Label{Name: v.labelNames[i], Value: value}
+	}
+	c = &child[T]{labels: labels, metric: v.newMetric()}
+	v.children[key] = c
+	return c.metric
+}
+
+// delete 删除标签值对应的子指标
+func (v *vec[T]) delete(values []string) bool {
+	key := strings.Join(values, "\xff")
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	_, ok := v.children[key]
+	delete(v.children, key)
+	return ok
+}
+
+// sorted 按照标签值排序返回所有子指标，使每次输出的顺序稳定
+func (v *vec[T]) sorted() []*child[T] {
+	v.mu.RLock()
+	keys := make([]string, 0, len(v.children))
+	for key := range v.children {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	children := make([]*child[T], len(keys))
+	for i, key := range keys {
+		children[i] = v.children[key]
+	}
+	v.mu.RUnlock()
+	return children
+}
+
+func (v *vec[T]) Describe() []string {
+	return []string{v.opts.Name}
+}
+
+// formatFloat 按照Prometheus的格式输出浮点数
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
@@ -0,0 +1,127 @@
+package metrics
+
+import (
+	"errors"
+	"github.com/Psychopath-H/psyweb-master/psygo"
+	"github.com/Psychopath-H/psyweb-master/psygo/pool"
+	"github.com/Psychopath-H/psyweb-master/psygo/psygotest"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWriteText(t *testing.T) {
+	r := NewRegistry()
+	requests := NewCounterVec(Opts{Name: "requests_total", Help: "Total requests.\nSecond line."}, "path")
+	temperature := NewGauge(Opts{Name: "temperature", Help: "Temperature."})
+	latency := NewHistogram(HistogramOpts{Name: "latency_seconds", Buckets: []float64{0.1, 1}})
+	r.MustRegister(requests, temperature, latency)
+
+	requests.WithLabelValues(`/a"b`).Add(2)
+	requests.WithLabelValues("/").Inc()
+	temperature.Set(-1.5)
+	latency.Observe(0.1)
+	latency.Observe(0.5)
+	latency.Observe(3)
+
+	var sb strings.Builder
+	if err := r.WriteText(&sb); err != nil {
+		t.Fatal(err)
+	}
+	want := `# TYPE latency_seconds histogram
+latency_seconds_bucket{le="0.1"} 1
+latency_seconds_bucket{le="1"} 2
+latency_seconds_bucket{le="+Inf"} 3
+latency_seconds_sum 3.6
+latency_seconds_count 3
+# HELP requests_total Total requests.\nSecond line.
+# TYPE requests_total counter
+requests_total{path="/"} 1
+requests_total{path="/a\"b"} 2
+# HELP temperature Temperature.
+# TYPE temperature gauge
+temperature -1.5
+`
+	if sb.String() != want {
+		t.Fatalf("got:\n%s\nwant:\n%s", sb.String(), want)
+	}
+}
+
+func TestRegisterDuplicate(t *testing.T) {
+	r := NewRegistry()
+	r.MustRegister(NewCounter(Opts{Name: "dup"}))
+	err := r.Register(NewGauge(Opts{Name: "dup"}))
+	var are *AlreadyRegisteredError
+	if !errors.As(err, &are) || are.Name != "dup" {
+		t.Fatalf("err = %v, want AlreadyRegisteredError", err)
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	r := NewRegistry()
+	engine := psygo.New()
+	engine.Use(Middleware(r))
+	engine.GET("/goods/:id", func(c *psygo.Context) {
+		c.String(http.StatusOK, "goods %s", c.Param("id"))
+	})
+	engine.GET("/empty", func(c *psygo.Context) {})
+	engine.GET("/metrics", Handler(r))
+
+	psygotest.NewRequest(engine).GET("/empty").Do(t).AssertStatus(http.StatusOK)
+	psygotest.NewRequest(engine).GET("/goods/1").Do(t).AssertStatus(http.StatusOK)
+	psygotest.NewRequest(engine).GET("/goods/2").Do(t).AssertStatus(http.StatusOK)
+	psygotest.NewRequest(engine).GET("/missing").Do(t).AssertStatus(http.StatusNotFound)
+	psygotest.NewRequest(engine).Method("FOO1", "/missing").Do(t)
+	psygotest.NewRequest(engine).Method("FOO2", "/missing").Do(t)
+
+	resp := psygotest.NewRequest(engine).GET("/metrics").Do(t).
+		AssertStatus(http.StatusOK).
+		AssertHeader("Content-Type", ContentType).
+		AssertBodyContains(`psygo_http_requests_total{method="GET",route="/goods/:id",code="200"} 2`).
+		AssertBodyContains(`psygo_http_requests_total{method="GET",route="unmatched",code="404"} 1`).
+		AssertBodyContains(`psygo_http_requests_total{method="GET",route="/empty",code="200"} 1`).
+		AssertBodyContains(`psygo_http_request_duration_seconds_count{method="GET",route="/goods/:id"} 2`).
+		AssertBodyContains(`psygo_http_request_duration_seconds_count{method="OTHER",route="unmatched"} 2`)
+	if strings.Contains(resp.Body.String(), "/goods/1") {
+		t.Fatal("raw path should not be used as a label")
+	}
+	if strings.Contains(resp.Body.String(), "FOO1") {
+		t.Fatal("non-standard method should not be used as a label")
+	}
+}
+
+func TestHooks(t *testing.T) {
+	r := NewRegistry()
+	query := ORMQueryHook(r)
+	query("select * from user", 10*time.Millisecond, nil)
+	query("INSERT INTO user VALUES (?)", time.Millisecond, errors.New("duplicate"))
+	server := RPCServerHook(r)
+	server("Foo.Sum", time.Millisecond, nil)
+	server("Foo.Sum", time.Millisecond, errors.New("failed"))
+	RegisterBreaker(r, "goods", func() float64 { return 2 })
+	p, err := pool.NewPool(4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Release()
+	RegisterPool(r, "default", p)
+
+	var sb strings.Builder
+	if err := r.WriteText(&sb); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		`psygo_orm_query_duration_seconds_count{operation="SELECT"} 1`,
+		`psygo_orm_query_errors_total{operation="INSERT"} 1`,
+		`psygo_rpc_server_calls_total{method="Foo.Sum"} 2`,
+		`psygo_rpc_server_errors_total{method="Foo.Sum"} 1`,
+		`psygo_rpc_breaker_state{breaker="goods"} 2`,
+		`psygo_pool_capacity{pool="default"} 4`,
+		`psygo_pool_running{pool="default"} 0`,
+	} {
+		if !strings.Contains(sb.String(), want) {
+			t.Errorf("missing %q in:\n%s", want, sb.String())
+		}
+	}
+}
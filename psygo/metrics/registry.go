@@ -0,0 +1,184 @@
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"github.com/Psychopath-H/psyweb-master/psygo"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ContentType 是Prometheus文本格式的Content-Type
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// DefaultRegistry 是默认的Registry，MustRegister等包级函数都注册到这里
+var DefaultRegistry = NewRegistry()
+
+// AlreadyRegisteredError 表示同名的指标族已经注册过，Existing是已经注册的Collector
+type AlreadyRegisteredError struct {
+	Name     string
+	Existing Collector
+}
+
+func (e *AlreadyRegisteredError) Error() string {
+	return fmt.Sprintf("metrics: %s is already registered", e.Name)
+}
+
+// Registry 保存注册的Collector，并把它们的数据输出成Prometheus文本格式
+type Registry struct {
+	mu         sync.RWMutex
+	collectors map[string]Collector //指标族名 -> Collector
+}
+
+// NewRegistry 新建一个空的Registry
+func NewRegistry() *Registry {
+	return &Registry{collectors: make(map[string]Collector)}
+}
+
+// Register 注册c，c输出的指标族和已经注册的重名时返回*AlreadyRegisteredError
+func (r *Registry) Register(c Collector) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	names := c.Describe()
+	for _, name := range names {
+		if existing, ok := r.collectors[name]; ok {
+			return &AlreadyRegisteredError{Name: name, Existing: existing}
+		}
+	}
+	for _, name := range names {
+		r.collectors[name] = c
+	}
+	return nil
+}
+
+// MustRegister 注册cs，失败时panic
+func (r *Registry) MustRegister(cs ...Collector) {
+	for _, c := range cs {
+		if err := r.Register(c); err != nil {
+			panic(err)
+		}
+	}
+}
+
+// Unregister 注销c，返回c是否注册过
+func (r *Registry) Unregister(c Collector) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	removed := false
+	for _, name := range c.Describe() {
+		if r.collectors[name] == c {
+			delete(r.collectors, name)
+			removed = true
+		}
+	}
+	return removed
+}
+
+// registerOrGet 注册c，同名的指标族已经注册过并且类型相同时返回已经注册的那个，
+// 使中间件和钩子函数可以被多次创建而共享同一组指标
+func registerOrGet[T Collector](r *Registry, c T) T {
+	err := r.Register(c)
+	if err == nil {
+		return c
+	}
+	if are, ok := err.(*AlreadyRegisteredError); ok {
+		if existing, ok := are.Existing.(T); ok {
+			return existing
+		}
+	}
+	panic(err)
+}
+
+// Gather 返回所有指标族的数据，按照名字排序
+func (r *Registry) Gather() []*Family {
+	r.mu.RLock()
+	seen := make(map[Collector]bool, len(r.collectors))
+	collectors := make([]Collector, 0, len(r.collectors))
+	for _, c := range r.collectors {
+		if !seen[c] {
+			seen[c] = true
+			collectors = append(collectors, c)
+		}
+	}
+	r.mu.RUnlock()
+
+	var families []*Family
+	for _, c := range collectors {
+		families = append(families, c.Collect()...)
+	}
+	sort.Slice(families, func(i, j int) bool {
+		return families[i].Name < families[j].Name
+	})
+	return families
+}
+
+// WriteText 以Prometheus文本格式写出所有指标
+func (r *Registry) WriteText(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	for _, family := range r.Gather() {
+		if family.Help != "" {
+			fmt.Fprintf(bw, "# HELP %s %s\n", family.Name, escapeHelp(family.Help))
+		}
+		fmt.Fprintf(bw, "# TYPE %s %s\n", family.Name, family.Type)
+		for _, sample := range family.Samples {
+			bw.WriteString(family.Name)
+			bw.WriteString(sample.Suffix)
+			writeLabels(bw, sample.Labels)
+			bw.WriteByte(' ')
+			bw.WriteString(formatFloat(sample.Value))
+			bw.WriteByte('\n')
+		}
+	}
+	return bw.Flush()
+}
+
+// ServeHTTP 实现了http.Handler，可以直接挂到net/http的ServeMux上
+func (r *Registry) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", ContentType)
+	_ = r.WriteText(w)
+}
+
+// Handler 返回输出r中所有指标的处理函数，一般注册在 GET /metrics 上
+func Handler(r *Registry) psygo.HandlerFunc {
+	return func(c *psygo.Context) {
+		c.Header("Content-Type", ContentType)
+		c.Status(http.StatusOK)
+		if err := r.WriteText(c.Writer); err != nil {
+			_ = c.Error(err)
+		}
+	}
+}
+
+// MustRegister 把cs注册到DefaultRegistry，失败时panic
+func MustRegister(cs ...Collector) {
+	DefaultRegistry.MustRegister(cs...)
+}
+
+func writeLabels(w *bufio.Writer, labels []Label) {
+	if len(labels) == 0 {
+		return
+	}
+	w.WriteByte('{')
+	for i, label := range labels {
+		if i > 0 {
+			w.WriteByte(',')
+		}
+		w.WriteString(label.Name)
+		w.WriteString(`="`)
+		w.WriteString(labelValueReplacer.Replace(label.Value))
+		w.WriteByte('"')
+	}
+	w.WriteByte('}')
+}
+
+var (
+	labelValueReplacer = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+	helpReplacer       = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+)
+
+func escapeHelp(help string) string {
+	return helpReplacer.Replace(help)
+}
@@ -1,6 +1,13 @@
 package psygo
 
-import "github.com/Psychopath-H/psyweb-master/psygo/binding"
+import (
+	"github.com/Psychopath-H/psyweb-master/psygo/binding"
+	"os"
+	"sync/atomic"
+)
+
+// EnvPsyMode 通过这个环境变量可以设置psygo的运行模式
+const EnvPsyMode = "PSYGO_MODE"
 
 const (
 	// DebugMode indicates psygo mode is debug.
@@ -17,6 +24,43 @@ const (
 	testCode
 )
 
+var (
+	psyMode  int32 = debugCode
+	modeName atomic.Value
+)
+
+func init() {
+	SetMode(os.Getenv(EnvPsyMode))
+}
+
+// SetMode 设置psygo的运行模式，传入空字符串时为DebugMode
+func SetMode(value string) {
+	if value == "" {
+		value = DebugMode
+	}
+	switch value {
+	case DebugMode:
+		atomic.StoreInt32(&psyMode, debugCode)
+	case ReleaseMode:
+		atomic.StoreInt32(&psyMode, releaseCode)
+	case TestMode:
+		atomic.StoreInt32(&psyMode, testCode)
+	default:
+		panic("psygo mode unknown: " + value + " (available mode: debug release test)")
+	}
+	modeName.Store(value)
+}
+
+// Mode 返回当前的运行模式
+func Mode() string {
+	return modeName.Load().(string)
+}
+
+// IsDebugging 判断当前是否是DebugMode
+func IsDebugging() bool {
+	return atomic.LoadInt32(&psyMode) == debugCode
+}
+
 func EnableJsonDecoderDisallowUnknownFields() {
 	binding.DisallowUnknownFields = true
 }
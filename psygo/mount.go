@@ -0,0 +1,111 @@
+package psygo
+
+import (
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// anyMethods 是Any注册的全部请求方法
+var anyMethods = []string{
+	http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodHead,
+	http.MethodOptions, http.MethodDelete, http.MethodConnect, http.MethodTrace,
+}
+
+// WrapH 把http.Handler包装成HandlerFunc，响应经过c.Writer写出，日志、指标等中间件可以看到状态码和响应大小
+func WrapH(h http.Handler) HandlerFunc {
+	return func(c *Context) {
+		h.ServeHTTP(c.Writer, c.Req)
+	}
+}
+
+// WrapF 把http.HandlerFunc包装成HandlerFunc
+func WrapF(f http.HandlerFunc) HandlerFunc {
+	return WrapH(f)
+}
+
+// Any 为pattern注册所有请求方法(GET、POST、PUT、PATCH、HEAD、OPTIONS、DELETE、CONNECT、TRACE)
+func (group *RouterGroup) Any(pattern string, handler HandlerFunc) []*RouteInfo {
+	return group.Match(anyMethods, pattern, handler)
+}
+
+// Match 为pattern注册methods中的每一个请求方法，返回的RouteInfo与methods一一对应
+func (group *RouterGroup) Match(methods []string, pattern string, handler HandlerFunc) []*RouteInfo {
+	routes := make([]*RouteInfo, 0, len(methods))
+	for _, method := range methods {
+		routes = append(routes, group.addRoute(strings.ToUpper(method), pattern, handler))
+	}
+	return routes
+}
+
+// Mount 把h挂载到该分组的prefix下，prefix本身以及它下面的所有路径、所有请求方法都交给h处理，
+// 交给h之前会从请求路径中去掉分组前缀和prefix。h可以是任意http.Handler，也可以是另一个*Engine，
+// 这时请求先经过当前分组的中间件，再经过子引擎自己的中间件和路由，例如
+//
+//	admin := psygo.New()
+//	admin.GET("/users", listUsers)
+//	engine.Mount("/admin", admin) // GET /admin/users 由admin的/users处理
+//	engine.Mount("/_rpc_/registry", registry.DefaultRegister)
+//
+// 挂载的路由不出现在接口文档中
+func (group *RouterGroup) Mount(prefix string, h http.Handler) {
+	prefix = strings.TrimSuffix(prefix, "/")
+	mountPath := group.prefix + prefix
+	handler := func(c *Context) {
+		req, ok := stripPrefix(c.Req, mountPath)
+		if !ok {
+			notFound(c)
+			return
+		}
+		h.ServeHTTP(c.Writer, req)
+	}
+	routes := group.Any(prefix, handler)
+	routes = append(routes, group.Any(path.Join(prefix, "/*mountpath"), handler)...)
+	for _, route := range routes {
+		route.Hidden()
+	}
+}
+
+// stripPrefix 返回去掉了路径前缀prefix的请求副本，剩下的路径总是以/开头。
+// 路由匹配时会忽略多余的/，所以先清理路径(例如//api/x)再去掉前缀，
+// 清理之后不在prefix之下的路径(例如/api/../x)返回false
+func stripPrefix(req *http.Request, prefix string) (*http.Request, bool) {
+	if prefix == "" {
+		return req, true
+	}
+	p := cleanPath(req.URL.Path)
+	if p != prefix && !strings.HasPrefix(p, prefix+"/") {
+		return nil, false
+	}
+	r := new(http.Request)
+	*r = *req
+	r.URL = new(url.URL)
+	*r.URL = *req.URL
+	r.URL.Path = rootedPath(strings.TrimPrefix(p, prefix))
+	if req.URL.RawPath != "" {
+		//RawPath中的转义字符使它和Path的前缀长度不同，前缀对不上时交给url包从Path重新计算
+		r.URL.RawPath = rootedPath(strings.TrimPrefix(cleanPath(req.URL.RawPath), prefix))
+		if u, err := url.PathUnescape(r.URL.RawPath); err != nil || u != r.URL.Path {
+			r.URL.RawPath = ""
+		}
+	}
+	return r, true
+}
+
+// cleanPath 和path.Clean相同，但是保留末尾的/
+func cleanPath(p string) string {
+	cleaned := path.Clean(rootedPath(p))
+	if strings.HasSuffix(p, "/") && cleaned != "/" {
+		cleaned += "/"
+	}
+	return cleaned
+}
+
+// rootedPath 保证路径以/开头
+func rootedPath(p string) string {
+	if strings.HasPrefix(p, "/") {
+		return p
+	}
+	return "/" + p
+}
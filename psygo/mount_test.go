@@ -0,0 +1,104 @@
+package psygo
+
+import (
+	"io"
+	"net/http"
+	"testing"
+)
+
+func TestMount(t *testing.T) {
+	admin := New()
+	admin.Use(func(c *Context) {
+		c.Header("X-Admin", "1")
+		c.Next()
+	})
+	admin.GET("/users/:id", func(c *Context) {
+		c.String(http.StatusOK, "user %s", c.Param("id"))
+	})
+	admin.GET("/", func(c *Context) {
+		c.String(http.StatusOK, "admin index")
+	})
+
+	engine := New()
+	api := engine.Group("/api")
+	api.Use(func(c *Context) {
+		c.Header("X-Api", "1")
+		c.Next()
+	})
+	api.Mount("/admin", admin)
+	api.Mount("/raw/", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, r.Method+" "+r.URL.Path+" "+r.URL.RawPath)
+	}))
+	engine.Any("/any", func(c *Context) {
+		c.String(http.StatusOK, "%s", c.Method)
+	})
+	engine.Match([]string{"get", "post"}, "/match", WrapF(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+	}))
+
+	tests := []struct {
+		method, target string
+		code           int
+		body           string
+	}{
+		{http.MethodGet, "/api/admin/users/7", http.StatusOK, "user 7"},
+		{http.MethodGet, "/api/admin", http.StatusOK, "admin index"},
+		{http.MethodGet, "/api/admin/", http.StatusOK, "admin index"},
+		{http.MethodDelete, "/api/raw", http.StatusOK, "DELETE / "},
+		{http.MethodPut, "/api/raw/a%2Fb/c", http.StatusOK, "PUT /a/b/c /a%2Fb/c"},
+		{http.MethodGet, "//api/admin/users/8", http.StatusOK, "user 8"},
+		{http.MethodGet, "/api//raw//x/", http.StatusOK, "GET /x/ "},
+		{http.MethodPatch, "/any", http.StatusOK, "PATCH"},
+		{http.MethodPost, "/match", http.StatusAccepted, ""},
+		{http.MethodPut, "/match", http.StatusNotFound, "404 NOT FOUND: /match\n"},
+	}
+	for _, tt := range tests {
+		w := performRequest(engine, tt.method, tt.target, nil)
+		if w.Code != tt.code || w.Body.String() != tt.body {
+			t.Errorf("%s %s: got %d %q, want %d %q", tt.method, tt.target, w.Code, w.Body.String(), tt.code, tt.body)
+		}
+	}
+
+	w := performRequest(engine, http.MethodGet, "/api/admin/users/7", nil)
+	if w.Header().Get("X-Api") != "1" || w.Header().Get("X-Admin") != "1" {
+		t.Fatalf("middlewares of both engines should run, headers %v", w.Header())
+	}
+	if routes := engine.Routes(); len(routes) != 2*2*9+9+2 { //每个挂载点注册prefix和prefix/*mountpath两组
+		t.Fatalf("routes = %d", len(routes))
+	}
+}
+
+func TestMountOutsidePrefix(t *testing.T) {
+	engine := New()
+	engine.Mount("/files", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, r.URL.Path)
+	}))
+	//清理之后跑出了挂载点的路径不会交给被挂载的handler
+	w := performRequest(engine, http.MethodGet, "/files/../secret", nil)
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("got %d %q, want 404", w.Code, w.Body.String())
+	}
+}
+
+func TestWrapH(t *testing.T) {
+	var size, status int
+	engine := New()
+	engine.Use(func(c *Context) {
+		c.Next()
+		status, size = c.ResponseStatus(), c.ResponseSize()
+	})
+	engine.GET("/legacy/:id", WrapH(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Legacy", "1")
+		w.WriteHeader(http.StatusCreated)
+		_, _ = io.WriteString(w, "legacy "+r.URL.Path)
+	})))
+
+	w := performRequest(engine, http.MethodGet, "/legacy/7", nil)
+	if w.Code != http.StatusCreated || w.Body.String() != "legacy /legacy/7" || w.Header().Get("X-Legacy") != "1" {
+		t.Fatalf("got %d %q %v", w.Code, w.Body.String(), w.Header())
+	}
+	//响应经过c.Writer写出，中间件可以看到状态码和大小
+	if status != http.StatusCreated || size != len("legacy /legacy/7") {
+		t.Fatalf("middleware saw status %d size %d", status, size)
+	}
+}
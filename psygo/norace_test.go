@@ -0,0 +1,5 @@
+//go:build !race
+
+package psygo
+
+const raceEnabled = false
@@ -0,0 +1,183 @@
+package psygo
+
+import (
+	"github.com/Psychopath-H/psyweb-master/psygo/binding"
+	"github.com/Psychopath-H/psyweb-master/psygo/openapi"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// OpenAPI 根据已注册的路由和它们的RouteDoc生成 OpenAPI 3.1 文档：
+// 路由规则中的:param和*param转换成路径参数，Query的结构体转换成查询参数，
+// Request和Response的结构体连同json、binding、validate标签转换成JSON Schema。
+// 文档中只有没有主机规则的路由，等同于OpenAPIForHost("")
+func (engine *Engine) OpenAPI() *openapi.Document {
+	return engine.OpenAPIForHost("")
+}
+
+// OpenAPIForHost 生成请求的主机为host时能够访问到的接口的文档。不同主机上可以注册相同的请求方法和路径，
+// 一份文档中的路径只能对应一个接口，所以文档按照主机生成：和路由匹配的顺序一样，
+// 精确的主机规则优先，其次是带通配符的主机规则，最后是没有主机规则的路由
+func (engine *Engine) OpenAPIForHost(host string) *openapi.Document {
+	info := engine.OpenAPIInfo
+	if info.Title == "" {
+		info.Title = "psygo"
+	}
+	if info.Version == "" {
+		info.Version = "0.0.0"
+	}
+	doc := openapi.NewDocument(info)
+	gen := openapi.NewGenerator()
+	host = hostname(host)
+	type candidate struct {
+		route *RouteInfo
+		rank  int
+	}
+	var keys []string
+	chosen := make(map[string]candidate)
+	for _, route := range engine.Routes() {
+		rank := hostRank(route.Host, host)
+		if route.Doc.Hidden || rank < 0 {
+			continue
+		}
+		path, _ := openAPIPath(route.Path)
+		key := route.Method + " " + path
+		if c, ok := chosen[key]; ok {
+			if c.rank >= rank {
+				continue
+			}
+		} else {
+			keys = append(keys, key)
+		}
+		chosen[key] = candidate{route: route, rank: rank}
+	}
+	for _, key := range keys {
+		route := chosen[key].route
+		path, params := openAPIPath(route.Path)
+		doc.AddOperation(path, strings.ToLower(route.Method), route.operation(gen, params))
+	}
+	doc.Components = gen.Components()
+	return doc
+}
+
+// hostRank 返回主机规则为pattern的路由在请求主机为host时的优先级，不会匹配到时返回-1
+func hostRank(pattern, host string) int {
+	switch {
+	case pattern == "":
+		return 0
+	case host == "" || !matchHostParts(strings.Split(pattern, "."), host, nil):
+		return -1
+	case strings.ContainsAny(pattern, "*:"):
+		return 1
+	default:
+		return 2
+	}
+}
+
+// operation 把一条路由转换成文档中的一个接口
+func (r *RouteInfo) operation(gen *openapi.Generator, params []*openapi.Parameter) *openapi.Operation {
+	op := &openapi.Operation{
+		Tags:        r.Doc.Tags,
+		Summary:     r.Doc.Summary,
+		Description: r.Doc.Description,
+		OperationID: r.Doc.OperationID,
+		Parameters:  params,
+		Responses:   make(map[string]*openapi.Response),
+		Deprecated:  r.Doc.Deprecated,
+	}
+	if op.OperationID == "" {
+		op.OperationID = operationID(r.Method, r.Path)
+	}
+	if r.Doc.Query != nil {
+		for _, f := range openapi.Fields(reflect.TypeOf(r.Doc.Query), "form") {
+			schema := gen.Schema(f.Type)
+			openapi.ApplyValidation(schema, f.Validate)
+			op.Parameters = append(op.Parameters, &openapi.Parameter{
+				Name:        f.Name,
+				In:          "query",
+				Description: f.Description,
+				Required:    f.Required,
+				Schema:      schema,
+			})
+		}
+	}
+	if r.Doc.Request != nil {
+		op.RequestBody = &openapi.RequestBody{
+			Required: true,
+			Content:  map[string]*openapi.MediaType{binding.MIMEJSON: {Schema: gen.SchemaOf(r.Doc.Request)}},
+		}
+	}
+	for code, resp := range r.Doc.Responses {
+		response := &openapi.Response{Description: resp.Description}
+		if response.Description == "" {
+			response.Description = http.StatusText(code)
+		}
+		if resp.Body != nil {
+			response.Content = map[string]*openapi.MediaType{binding.MIMEJSON: {Schema: gen.SchemaOf(resp.Body)}}
+		}
+		op.Responses[strconv.Itoa(code)] = response
+	}
+	if len(op.Responses) == 0 { //OpenAPI要求至少有一个响应
+		op.Responses["200"] = &openapi.Response{Description: http.StatusText(http.StatusOK)}
+	}
+	return op
+}
+
+// openAPIPath 把 /goods/:id/*file 转换成 /goods/{id}/{file}，并返回其中的路径参数
+func openAPIPath(pattern string) (string, []*openapi.Parameter) {
+	parts := strings.Split(pattern, "/")
+	var params []*openapi.Parameter
+	for i, part := range parts {
+		if len(part) > 1 && (part[0] == ':' || part[0] == '*') {
+			name := part[1:]
+			parts[i] = "{" + name + "}"
+			params = append(params, &openapi.Parameter{
+				Name:     name,
+				In:       "path",
+				Required: true,
+				Schema:   &openapi.Schema{Type: "string"},
+			})
+		}
+	}
+	return strings.Join(parts, "/"), params
+}
+
+// operationID 由请求方法和路由规则生成接口的标识，例如 GET /goods/:id -> getGoodsById
+func operationID(method, pattern string) string {
+	var sb strings.Builder
+	sb.WriteString(strings.ToLower(method))
+	for _, part := range strings.FieldsFunc(pattern, func(r rune) bool {
+		return r == '/' || r == '-' || r == '_' || r == '.'
+	}) {
+		if part[0] == ':' || part[0] == '*' {
+			sb.WriteString("By")
+			part = part[1:]
+		}
+		if part == "" {
+			continue
+		}
+		sb.WriteString(strings.ToUpper(part[:1]))
+		sb.WriteString(part[1:])
+	}
+	return sb.String()
+}
+
+// MountOpenAPI 在specPath(为空时为/openapi.json)注册返回OpenAPI文档的接口，返回的是请求的主机能够访问到的接口，
+// docsPath不为空时再注册一个展示文档的页面，页面不依赖任何外部资源。
+// 文档在每次请求时重新生成，之后注册的路由也会出现在其中，这两个接口本身不出现在文档里
+func (engine *Engine) MountOpenAPI(specPath, docsPath string) {
+	if specPath == "" {
+		specPath = "/openapi.json"
+	}
+	engine.GET(specPath, func(c *Context) {
+		c.JSON(http.StatusOK, engine.OpenAPIForHost(c.Req.Host))
+	}).Hidden()
+	if docsPath != "" {
+		page := openapi.DocsHTML(specPath)
+		engine.GET(docsPath, func(c *Context) {
+			c.Data(http.StatusOK, "text/html; charset=utf-8", page)
+		}).Hidden()
+	}
+}
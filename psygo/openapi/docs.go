@@ -0,0 +1,16 @@
+package openapi
+
+import (
+	_ "embed"
+	"html/template"
+	"strings"
+)
+
+//go:embed docs.html
+var docsHTML string
+
+// DocsHTML 返回一个不依赖外部资源的文档页面，它在浏览器中加载specURL处的OpenAPI文档并展示所有接口和结构
+func DocsHTML(specURL string) []byte {
+	//specURL放在JS字符串中，需要按照JS字符串转义
+	return []byte(strings.Replace(docsHTML, "{{SPEC_URL}}", template.JSEscapeString(specURL), 1))
+}
@@ -0,0 +1,165 @@
+package openapi
+
+import (
+	"encoding/json"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+type Base struct {
+	ID        int64     `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type Goods struct {
+	Base
+	Name     string            `json:"name" binding:"required" validate:"min=1,max=32" doc:"goods name"`
+	Price    float64           `json:"price" validate:"required,gt=0"`
+	Status   string            `json:"status,omitempty" validate:"oneof=on off"`
+	Level    int               `json:"level" validate:"oneof=1 2 3"`
+	Tags     []string          `json:"tags" validate:"max=5,dive,min=1"`
+	Owner    *User             `json:"owner,omitempty"`
+	Attrs    map[string]string `json:"attrs,omitempty"`
+	Internal string            `json:"-"`
+	private  string
+}
+
+type User struct {
+	Email  string `json:"email" validate:"email"`
+	Parent *User  `json:"parent,omitempty"`
+}
+
+func TestSchema(t *testing.T) {
+	g := NewGenerator()
+	ref := g.SchemaOf(Goods{})
+	if ref.Ref != "#/components/schemas/Goods" {
+		t.Fatalf("ref = %q", ref.Ref)
+	}
+	goods := g.Components().Schemas["Goods"]
+	if !reflect.DeepEqual(goods.Required, []string{"name", "price"}) {
+		t.Fatalf("required = %v", goods.Required)
+	}
+	for _, name := range []string{"id", "created_at", "name", "price", "status", "level", "tags", "owner", "attrs"} {
+		if goods.Properties[name] == nil {
+			t.Fatalf("missing property %s", name)
+		}
+	}
+	if len(goods.Properties) != 9 {
+		t.Fatalf("properties = %d, want 9", len(goods.Properties))
+	}
+
+	name := goods.Properties["name"]
+	if *name.MinLength != 1 || *name.MaxLength != 32 || name.Description != "goods name" {
+		t.Fatalf("name = %+v", name)
+	}
+	if *goods.Properties["price"].ExclusiveMinimum != 0 {
+		t.Fatal("price should be exclusive minimum 0")
+	}
+	if !reflect.DeepEqual(goods.Properties["status"].Enum, []any{"on", "off"}) ||
+		!reflect.DeepEqual(goods.Properties["level"].Enum, []any{int64(1), int64(2), int64(3)}) {
+		t.Fatal("unexpected enum")
+	}
+	tags := goods.Properties["tags"]
+	if *tags.MaxItems != 5 || tags.Items.MinLength != nil {
+		t.Fatal("rules after dive should not apply to the array")
+	}
+	if goods.Properties["created_at"].Format != "date-time" {
+		t.Fatal("time.Time should be a date-time string")
+	}
+	if goods.Properties["attrs"].AdditionalProperties.Type != "string" {
+		t.Fatal("map should use additionalProperties")
+	}
+
+	user := g.Components().Schemas["User"]
+	if user.Properties["email"].Format != "email" || user.Properties["parent"].Ref != "#/components/schemas/User" {
+		t.Fatalf("user = %+v", user)
+	}
+	if _, err := json.Marshal(g.Components()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+type Page[T any] struct {
+	Items []T `json:"items"`
+	Total int `json:"total"`
+}
+
+func TestGenericSchemaName(t *testing.T) {
+	g := NewGenerator()
+	goodsPage := g.SchemaOf(Page[Goods]{})
+	userPage := g.SchemaOf(Page[map[string]*User]{})
+	if goodsPage.Ref != "#/components/schemas/Page_openapi.Goods" {
+		t.Fatalf("ref = %q", goodsPage.Ref)
+	}
+	if userPage.Ref != "#/components/schemas/Page_map_string_openapi.User" {
+		t.Fatalf("ref = %q", userPage.Ref)
+	}
+	if g.SchemaOf(&Page[Goods]{}).Ref != goodsPage.Ref {
+		t.Fatal("the same instantiation should reuse its component")
+	}
+	valid := regexp.MustCompile(`^[a-zA-Z0-9._-]+$`)
+	for name := range g.Components().Schemas {
+		if !valid.MatchString(name) {
+			t.Fatalf("invalid component name %q", name)
+		}
+	}
+	items := g.Components().Schemas["Page_openapi.Goods"].Properties["items"]
+	if items.Items.Ref != "#/components/schemas/Goods" {
+		t.Fatalf("items = %+v", items.Items)
+	}
+
+	for name, want := range map[string]string{
+		"Goods":                         "Goods",
+		"Page[example.com/a/b.Goods]":   "Page_b.Goods",
+		"Pair[int,example.com/m.User]":  "Pair_int_m.User",
+		"example.com/shop/model.Goods":  "example.com.shop.model.Goods",
+		"Page[[]*example.com/m.Goods]":  "Page_m.Goods",
+		"Tree[example.com/m.Node[int]]": "Tree_m.Node_int",
+	} {
+		if got := schemaName(name); got != want {
+			t.Errorf("schemaName(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
+
+// Node 嵌入了指向自己的指针
+type Node struct {
+	*Node
+	Name string `json:"name"`
+}
+
+// Outer 和Inner互相嵌入
+type Outer struct {
+	*Inner
+	ID int `json:"id"`
+}
+
+type Inner struct {
+	*Outer
+	Label string `json:"label"`
+}
+
+func TestFieldsRecursiveEmbedding(t *testing.T) {
+	fields := Fields(reflect.TypeOf(Node{}), "json")
+	if len(fields) != 1 || fields[0].Name != "name" {
+		t.Fatalf("fields = %+v", fields)
+	}
+	fields = Fields(reflect.TypeOf(Outer{}), "json")
+	if len(fields) != 2 || fields[0].Name != "label" || fields[1].Name != "id" {
+		t.Fatalf("fields = %+v", fields)
+	}
+	s := NewGenerator().Schema(reflect.TypeOf(Outer{}))
+	if s.Ref != "#/components/schemas/Outer" {
+		t.Fatalf("ref = %q", s.Ref)
+	}
+}
+
+func TestDocsHTML(t *testing.T) {
+	page := string(DocsHTML(`/api/openapi.json"</script>`))
+	if !strings.Contains(page, `const specURL = "/api/openapi.json\"\u003C/script\u003E";`) {
+		t.Fatal("spec url should be escaped as a JS string")
+	}
+}
@@ -0,0 +1,310 @@
+package openapi
+
+import (
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var timeType = reflect.TypeOf(time.Time{})
+
+var (
+	typeArgPkgPath  = regexp.MustCompile(`[^\[\],*\s]*/`) //泛型参数中的包路径，只保留最后一段包名
+	invalidNameChar = regexp.MustCompile(`[^a-zA-Z0-9._-]+`)
+)
+
+// Generator 把Go类型转换成Schema，有名字的结构体放进Components并以$ref引用，同一个Generator生成的文档共享这些结构
+type Generator struct {
+	schemas map[string]*Schema      //结构名 -> Schema
+	names   map[reflect.Type]string //类型 -> 结构名
+}
+
+// NewGenerator 新建一个Generator
+func NewGenerator() *Generator {
+	return &Generator{
+		schemas: make(map[string]*Schema),
+		names:   make(map[reflect.Type]string),
+	}
+}
+
+// Components 返回生成过程中收集到的全部结构，没有时返回nil
+func (g *Generator) Components() *Components {
+	if len(g.schemas) == 0 {
+		return nil
+	}
+	return &Components{Schemas: g.schemas}
+}
+
+// SchemaOf 返回v的类型对应的Schema，v为nil时返回nil
+func (g *Generator) SchemaOf(v any) *Schema {
+	if v == nil {
+		return nil
+	}
+	return g.Schema(reflect.TypeOf(v))
+}
+
+// Schema 返回t对应的Schema
+func (g *Generator) Schema(t reflect.Type) *Schema {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t == timeType {
+		return &Schema{Type: "string", Format: "date-time"}
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int8, reflect.Int16, reflect.Int32:
+		return &Schema{Type: "integer", Format: "int32"}
+	case reflect.Int, reflect.Int64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return &Schema{Type: "integer", Minimum: float64Ptr(0)}
+	case reflect.Float32:
+		return &Schema{Type: "number", Format: "float"}
+	case reflect.Float64:
+		return &Schema{Type: "number", Format: "double"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 { //encoding/json把[]byte编码成base64字符串
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		return &Schema{Type: "array", Items: g.Schema(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: g.Schema(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return g.structSchema(t)
+		}
+		return &Schema{Ref: "#/components/schemas/" + g.register(t)}
+	default: //interface等无法确定的类型，任何值都可以
+		return &Schema{}
+	}
+}
+
+// register 把有名字的结构体放进Components，先占位再生成属性，这样自引用的结构体也不会无限递归
+func (g *Generator) register(t reflect.Type) string {
+	if name, ok := g.names[t]; ok {
+		return name
+	}
+	name := schemaName(t.Name())
+	if _, taken := g.schemas[name]; taken { //不同包的同名结构体加上包名区分
+		name = schemaName(t.PkgPath() + "." + t.Name())
+	}
+	for i := 2; ; i++ { //泛型参数来自不同包但包名相同时仍然可能重名
+		if _, taken := g.schemas[name]; !taken {
+			break
+		}
+		name = schemaName(t.PkgPath()+"."+t.Name()) + "_" + strconv.Itoa(i)
+	}
+	g.names[t] = name
+	g.schemas[name] = &Schema{}
+	*g.schemas[name] = *g.structSchema(t)
+	return name
+}
+
+// schemaName 把类型名转换成OpenAPI要求的组件名(^[a-zA-Z0-9._-]+$)。
+// 泛型结构体的名字形如Page[github.com/x/model.Goods]，参数中的包路径只保留包名，其余不允许的字符替换成_，
+// 例如Page[github.com/x/model.Goods]变成Page_model.Goods，包路径中的/变成.
+func schemaName(name string) string {
+	if i := strings.IndexByte(name, '['); i >= 0 {
+		name = name[:i] + typeArgPkgPath.ReplaceAllString(name[i:], "")
+	}
+	name = strings.ReplaceAll(name, "/", ".")
+	return strings.Trim(invalidNameChar.ReplaceAllString(name, "_"), "_")
+}
+
+// structSchema 生成结构体的属性，字段名取json标签，匿名嵌入的结构体展开到同一层
+func (g *Generator) structSchema(t reflect.Type) *Schema {
+	s := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+	for _, f := range Fields(t, "json") {
+		prop := g.Schema(f.Type)
+		prop.Description = f.Description //3.1中$ref旁边可以有其它关键字
+		ApplyValidation(prop, f.Validate)
+		s.Properties[f.Name] = prop
+		if f.Required {
+			s.Required = append(s.Required, f.Name)
+		}
+	}
+	return s
+}
+
+// Field 是结构体中会出现在文档里的一个字段
+type Field struct {
+	Name        string       //序列化后的名字
+	Type        reflect.Type //字段类型
+	Required    bool         //binding:"required"或者validate中含有required
+	Validate    string       //validate标签
+	Description string       //doc标签
+}
+
+// Fields 返回结构体t中会被序列化的字段，名字取tagName标签(例如json、form)，没有时取字段名
+func Fields(t reflect.Type, tagName string) []Field {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return nil
+	}
+	return structFields(t, tagName, map[reflect.Type]bool{t: true})
+}
+
+// structFields 展开t的字段，visited是正在展开的结构体，
+// 例如 type A struct{ *A } 这样嵌入了自身的结构体不会无限展开下去
+func structFields(t reflect.Type, tagName string, visited map[reflect.Type]bool) []Field {
+	var fields []Field
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		tag := sf.Tag.Get(tagName)
+		if tag == "-" {
+			continue
+		}
+		name, _, _ := strings.Cut(tag, ",")
+		ft := sf.Type
+		if sf.Anonymous && name == "" {
+			et := ft
+			if et.Kind() == reflect.Pointer {
+				et = et.Elem()
+			}
+			if et.Kind() == reflect.Struct {
+				if !visited[et] {
+					visited[et] = true
+					fields = append(fields, structFields(et, tagName, visited)...)
+					delete(visited, et)
+				}
+				continue
+			}
+		}
+		if !sf.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = sf.Name
+		}
+		validate := sf.Tag.Get("validate")
+		required := sf.Tag.Get("binding") == "required" || hasRule(validate, "required")
+		fields = append(fields, Field{
+			Name:        name,
+			Type:        ft,
+			Required:    required,
+			Validate:    validate,
+			Description: sf.Tag.Get("doc"),
+		})
+	}
+	return fields
+}
+
+// hasRule 判断validate标签中是否有rule这条规则
+func hasRule(validate, rule string) bool {
+	for _, r := range strings.Split(validate, ",") {
+		if r == rule {
+			return true
+		}
+	}
+	return false
+}
+
+// ApplyValidation 把validator的规则转换成Schema中的约束，只处理dive之前(作用于字段本身)的规则：
+// min、max、len、gt、gte、lt、lte对字符串是长度，对数组是元素个数，对数字是取值范围；
+// oneof转换成enum；email、url、uri、uuid、ip、ipv4、ipv6、datetime转换成format
+func ApplyValidation(s *Schema, validate string) {
+	if validate == "" || s.Ref != "" {
+		return
+	}
+	for _, rule := range strings.Split(validate, ",") {
+		if rule == "dive" {
+			return
+		}
+		key, param, _ := strings.Cut(rule, "=")
+		switch key {
+		case "min", "gte":
+			setBound(s, param, false, false)
+		case "max", "lte":
+			setBound(s, param, true, false)
+		case "gt":
+			setBound(s, param, false, true)
+		case "lt":
+			setBound(s, param, true, true)
+		case "len":
+			setBound(s, param, false, false)
+			setBound(s, param, true, false)
+		case "oneof":
+			for _, v := range strings.Fields(param) {
+				s.Enum = append(s.Enum, enumValue(s.Type, v))
+			}
+		case "email":
+			s.Format = "email"
+		case "url", "uri":
+			s.Format = "uri"
+		case "uuid", "uuid3", "uuid4", "uuid5":
+			s.Format = "uuid"
+		case "ip", "ipv4", "ipv6":
+			s.Format = key
+		case "datetime":
+			s.Format = "date-time"
+		}
+	}
+}
+
+// setBound 设置上界或下界，exclusive表示不包含边界本身
+func setBound(s *Schema, param string, upper, exclusive bool) {
+	n, err := strconv.ParseFloat(param, 64)
+	if err != nil {
+		return
+	}
+	switch s.Type {
+	case "integer", "number":
+		switch {
+		case upper && exclusive:
+			s.ExclusiveMaximum = &n
+		case upper:
+			s.Maximum = &n
+		case exclusive:
+			s.ExclusiveMinimum = &n
+		default:
+			s.Minimum = &n
+		}
+		return
+	}
+	//长度和个数都是整数，不包含边界时加减1
+	size := int(n)
+	if exclusive {
+		if upper {
+			size--
+		} else {
+			size++
+		}
+	}
+	switch {
+	case s.Type == "string" && upper:
+		s.MaxLength = &size
+	case s.Type == "string":
+		s.MinLength = &size
+	case s.Type == "array" && upper:
+		s.MaxItems = &size
+	case s.Type == "array":
+		s.MinItems = &size
+	}
+}
+
+// enumValue 把oneof中的值转换成字段类型对应的JSON值
+func enumValue(schemaType, v string) any {
+	switch schemaType {
+	case "integer":
+		if n, err := strconv.ParseInt(v, 10, 64); err == nil {
+			return n
+		}
+	case "number":
+		if n, err := strconv.ParseFloat(v, 64); err == nil {
+			return n
+		}
+	}
+	return v
+}
+
+func float64Ptr(v float64) *float64 {
+	return &v
+}
@@ -0,0 +1,131 @@
+package psygo
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestOpenAPI(t *testing.T) {
+	type goods struct {
+		Name string `json:"name" binding:"required"`
+	}
+	type listQuery struct {
+		Page int `form:"page" validate:"min=1"`
+	}
+	engine := New()
+	engine.GET("/goods", func(c *Context) {}).Query(listQuery{}).Tags("goods")
+	engine.PUT("/goods/:id", func(c *Context) {}).
+		Request(goods{}).
+		Response(http.StatusOK, goods{}, "")
+	engine.GET("/internal", func(c *Context) {}).Hidden()
+	engine.MountOpenAPI("", "/docs")
+
+	w := performRequest(engine, http.MethodGet, "/openapi.json", nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d", w.Code)
+	}
+	type operation struct {
+		OperationID string `json:"operationId"`
+		Parameters  []struct {
+			Name   string `json:"name"`
+			In     string `json:"in"`
+			Schema struct {
+				Minimum *float64 `json:"minimum"`
+			} `json:"schema"`
+		} `json:"parameters"`
+		RequestBody struct {
+			Content map[string]struct {
+				Schema struct {
+					Ref string `json:"$ref"`
+				} `json:"schema"`
+			} `json:"content"`
+		} `json:"requestBody"`
+		Responses map[string]struct {
+			Description string `json:"description"`
+		} `json:"responses"`
+	}
+	var doc struct {
+		OpenAPI    string                          `json:"openapi"`
+		Paths      map[string]map[string]operation `json:"paths"`
+		Components struct {
+			Schemas map[string]struct {
+				Required []string `json:"required"`
+			} `json:"schemas"`
+		} `json:"components"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &doc); err != nil {
+		t.Fatal(err)
+	}
+	if doc.OpenAPI != "3.1.0" {
+		t.Fatalf("openapi = %q", doc.OpenAPI)
+	}
+	list := doc.Paths["/goods"]["get"]
+	if list.OperationID != "getGoods" || len(list.Parameters) != 1 || list.Parameters[0].Name != "page" ||
+		list.Parameters[0].Schema.Minimum == nil || *list.Parameters[0].Schema.Minimum != 1 {
+		t.Fatalf("GET /goods = %+v", list)
+	}
+	put := doc.Paths["/goods/{id}"]["put"]
+	if put.OperationID != "putGoodsById" || len(put.Parameters) != 1 || put.Parameters[0].In != "path" {
+		t.Fatalf("PUT /goods/{id} = %+v", put)
+	}
+	if ref := put.RequestBody.Content["application/json"].Schema.Ref; ref != "#/components/schemas/goods" {
+		t.Fatalf("request body ref = %q", ref)
+	}
+	if desc := put.Responses["200"].Description; desc != "OK" {
+		t.Fatalf("response description = %q", desc)
+	}
+	if required := doc.Components.Schemas["goods"].Required; len(required) != 1 || required[0] != "name" {
+		t.Fatalf("required = %v", required)
+	}
+	for _, hidden := range []string{"/internal", "/openapi.json", "/docs"} {
+		if _, ok := doc.Paths[hidden]; ok {
+			t.Fatalf("%s should not be documented", hidden)
+		}
+	}
+
+	w = performRequest(engine, http.MethodGet, "/docs", nil)
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "/openapi.json") {
+		t.Fatalf("docs page %d %q", w.Code, w.Body.String())
+	}
+}
+
+func TestOpenAPIForHost(t *testing.T) {
+	engine := New()
+	engine.GET("/goods", func(c *Context) {}).Summary("default goods")
+	engine.GET("/about", func(c *Context) {}).Summary("about")
+	engine.Host("api.example.com").GET("/goods", func(c *Context) {}).Summary("api goods")
+	engine.Host(":tenant.example.com").GET("/goods", func(c *Context) {}).Summary("tenant goods")
+	engine.Host(":tenant.example.com").GET("/tenant", func(c *Context) {}).Summary("tenant")
+	engine.MountOpenAPI("", "")
+
+	tests := []struct {
+		host    string
+		summary string
+		tenant  bool
+	}{
+		{"", "default goods", false},
+		{"other.org", "default goods", false},
+		{"api.example.com", "api goods", true},
+		{"shop.example.com:8080", "tenant goods", true},
+	}
+	for _, tt := range tests {
+		doc := engine.OpenAPIForHost(tt.host)
+		if got := (*doc.Paths["/goods"])["get"].Summary; got != tt.summary {
+			t.Fatalf("%s: /goods summary = %q, want %q", tt.host, got, tt.summary)
+		}
+		if _, ok := doc.Paths["/about"]; !ok {
+			t.Fatalf("%s: routes without a host rule should be documented", tt.host)
+		}
+		if _, ok := doc.Paths["/tenant"]; ok != tt.tenant {
+			t.Fatalf("%s: /tenant documented = %v, want %v", tt.host, ok, tt.tenant)
+		}
+	}
+
+	//文档接口按照请求的主机返回
+	w := performRequest(engine, http.MethodGet, "/openapi.json", nil, "Host", "api.example.com")
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"summary":"api goods"`) || strings.Contains(w.Body.String(), "default goods") {
+		t.Fatalf("status = %d, body = %s", w.Code, w.Body.String())
+	}
+}
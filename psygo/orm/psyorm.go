@@ -1,6 +1,7 @@
 package orm
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"fmt"
@@ -13,6 +14,7 @@ import (
 type Engine struct {
 	db      *sql.DB
 	dialect dialect.Dialect
+	onQuery session.QueryHook
 }
 
 // NewEngine create a instance of Engine
@@ -48,9 +50,24 @@ func (engine *Engine) Close() {
 	log.Info("Close database success")
 }
 
+// PingContext verifies the database connection is still alive,
+// it can be used as a readiness check, e.g. psygo.PingCheck("db", engine)
+func (engine *Engine) PingContext(ctx context.Context) error {
+	return engine.db.PingContext(ctx)
+}
+
+// SetQueryHook sets the hook called after every sql statement executed by sessions of this engine,
+// e.g. engine.SetQueryHook(metrics.ORMQueryHook(metrics.DefaultRegistry))
+// it should be set before the engine is used
+func (engine *Engine) SetQueryHook(hook session.QueryHook) {
+	engine.onQuery = hook
+}
+
 // NewSession creates a new session for next operations
 func (engine *Engine) NewSession() *session.Session {
-	return session.New(engine.db, engine.dialect)
+	s := session.New(engine.db, engine.dialect)
+	s.SetQueryHook(engine.onQuery)
+	return s
 }
 
 // TxFunc will be called between tx.Begin() and tx.Commit()
@@ -7,6 +7,7 @@ import (
 	"orm/log"
 	"orm/schema"
 	"strings"
+	"time"
 )
 
 // Session keep a pointer to sql.DB and provides all execution of all
@@ -19,6 +20,23 @@ type Session struct {
 	clause   clause.Clause
 	sql      strings.Builder
 	sqlVars  []any
+	onQuery  QueryHook
+}
+
+// QueryHook is called after every sql statement executed by a session,
+// it can be used to collect query metrics
+type QueryHook func(sql string, duration time.Duration, err error)
+
+// SetQueryHook sets the hook called after every sql statement
+func (s *Session) SetQueryHook(hook QueryHook) {
+	s.onQuery = hook
+}
+
+// observe reports a finished sql statement to the query hook
+func (s *Session) observe(sql string, start time.Time, err error) {
+	if s.onQuery != nil {
+		s.onQuery(sql, time.Since(start), err)
+	}
 }
 
 // New creates a instance of Session
@@ -58,10 +76,11 @@ func (s *Session) DB() CommonDB {
 func (s *Session) Exec() (result sql.Result, err error) {
 	defer s.Clear()
 	log.Info(s.sql.String(), s.sqlVars)
+	start := time.Now()
 	if result, err = s.DB().Exec(s.sql.String(), s.sqlVars...); err != nil {
 		log.Error(err)
 	}
-
+	s.observe(s.sql.String(), start, err)
 	return
 }
 
@@ -69,16 +88,21 @@ func (s *Session) Exec() (result sql.Result, err error) {
 func (s *Session) QueryRow() *sql.Row {
 	defer s.Clear()
 	log.Info(s.sql.String(), s.sqlVars)
-	return s.DB().QueryRow(s.sql.String(), s.sqlVars...)
+	start := time.Now()
+	row := s.DB().QueryRow(s.sql.String(), s.sqlVars...)
+	s.observe(s.sql.String(), start, row.Err())
+	return row
 }
 
 // QueryRows gets a list of records from db
 func (s *Session) QueryRows() (rows *sql.Rows, err error) {
 	defer s.Clear()
 	log.Info(s.sql.String(), s.sqlVars)
+	start := time.Now()
 	if rows, err = s.DB().Query(s.sql.String(), s.sqlVars...); err != nil {
 		log.Error(err)
 	}
+	s.observe(s.sql.String(), start, err)
 	return
 }
 
@@ -0,0 +1,105 @@
+package psygo
+
+import (
+	"errors"
+	"github.com/Psychopath-H/psyweb-master/psygo/psyerror"
+	"github.com/Psychopath-H/psyweb-master/psygo/render"
+	"net/http"
+	"reflect"
+)
+
+// ProblemFromError 把err转换成 RFC 7807 的问题详情文档：
+// *psyerror.PsyError 使用它自己的状态码、错误码和各个字段，其余错误使用status和err.Error()作为说明
+func ProblemFromError(err error, status int) *render.Problem {
+	var psyError *psyerror.PsyError
+	if errors.As(err, &psyError) {
+		problem := &render.Problem{
+			Type:       psyError.Type,
+			Title:      psyError.Title,
+			Status:     psyError.HTTPStatus(),
+			Detail:     psyError.Detail,
+			Instance:   psyError.Instance,
+			Extensions: make(map[string]any, len(psyError.Extensions)+1),
+		}
+		for k, v := range psyError.Extensions {
+			problem.Extensions[k] = v
+		}
+		if psyError.Code != "" {
+			problem.Extensions["code"] = psyError.Code
+		}
+		if problem.Title == "" {
+			problem.Title = http.StatusText(problem.Status)
+		}
+		return problem
+	}
+	if status == 0 {
+		status = http.StatusInternalServerError
+	}
+	problem := &render.Problem{
+		Title:  http.StatusText(status),
+		Status: status,
+	}
+	if err != nil {
+		problem.Detail = err.Error()
+	}
+	var msg *Error
+	if errors.As(err, &msg) && msg.Meta != nil {
+		problem.Extensions = metaExtensions(msg.Meta)
+	}
+	return problem
+}
+
+// metaExtensions 把Error.Meta转换成问题详情的扩展字段
+func metaExtensions(meta any) map[string]any {
+	extensions := make(map[string]any)
+	value := reflect.ValueOf(meta)
+	if value.Kind() == reflect.Map && value.Type().Key().Kind() == reflect.String {
+		for _, key := range value.MapKeys() {
+			extensions[key.String()] = value.MapIndex(key).Interface()
+		}
+		return extensions
+	}
+	extensions["meta"] = meta
+	return extensions
+}
+
+// Problem 以 application/problem+json 的形式返回err，状态码取自*psyerror.PsyError，其余错误返回500，
+// 没有设置Instance时使用请求路径
+func (c *Context) Problem(err error) {
+	problem := ProblemFromError(err, http.StatusInternalServerError)
+	c.ProblemJSON(problem)
+}
+
+// ProblemJSON 返回一个问题详情文档，状态码为problem.Status
+func (c *Context) ProblemJSON(problem *render.Problem) {
+	if problem.Status == 0 {
+		problem.Status = http.StatusInternalServerError
+	}
+	if problem.Instance == "" && c.Req != nil {
+		problem.Instance = c.Req.URL.Path
+	}
+	c.Render(problem.Status, problem)
+}
+
+// problemFromErrors 把ErrorHandler收集到的多个错误合并成一个问题详情文档，
+// 最后一个错误决定文档的主体，所有错误的信息放在errors扩展字段中
+func problemFromErrors(code int, errs errorMsgs) *render.Problem {
+	last := errs.Last()
+	problem := ProblemFromError(last, code)
+	if len(errs) > 1 {
+		if problem.Extensions == nil {
+			problem.Extensions = make(map[string]any)
+		}
+		problem.Extensions["errors"] = errs.Errors()
+	}
+	return problem
+}
+
+// isProblemError 判断err是否是带有状态码或错误码的应用错误，只用于收集错误的PsyError不算
+func isProblemError(err error) bool {
+	var psyError *psyerror.PsyError
+	if !errors.As(err, &psyError) {
+		return false
+	}
+	return psyError.Status != 0 || psyError.Code != ""
+}
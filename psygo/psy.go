@@ -1,15 +1,19 @@
 package psygo
 
 import (
+	"crypto/cipher"
 	"errors"
 	"github.com/Psychopath-H/psyweb-master/psygo/config"
 	psyLog "github.com/Psychopath-H/psyweb-master/psygo/logger"
+	"github.com/Psychopath-H/psyweb-master/psygo/openapi"
 	"html/template"
 	"log"
+	"net"
 	"net/http"
 	"path"
 	"strings"
 	"sync"
+	"sync/atomic"
 )
 
 // HandlerFunc 定义了访问路由(网络请求)时的处理函数
@@ -18,12 +22,31 @@ type HandlerFunc func(c *Context)
 // Engine 实现了ServeHTTP方法，所有打到特定端口的请求都会被路由到这里
 type Engine struct {
 	*RouterGroup
-	router        *router
-	groups        []*RouterGroup     //存储所有的路由分组
-	htmlTemplates *template.Template //用于提前将html模板加载进内存
-	funcMap       template.FuncMap   //自定义模板渲染函数。
-	pool          sync.Pool          //用于存储Context上下文的池子,避免频繁创建context影响效率
+	mu            sync.Mutex              //保护table，修改路由的一方持有，处理请求时不需要
+	table         routeTable              //所有注册过的路由和分组
+	routing       atomic.Pointer[routing] //当前生效的路由快照，处理请求时只读取它
+	htmlTemplates *template.Template      //用于提前将html模板加载进内存
+	funcMap       template.FuncMap        //自定义模板渲染函数。
+	pool          sync.Pool               //用于存储Context上下文的池子,避免频繁创建context影响效率
 	Logger        *psyLog.Logger
+	// MaxMultipartMemory 解析multipart表单时最多放在内存中的字节数，超出的部分会写入临时文件，默认32M
+	MaxMultipartMemory int64
+	// MaxBodySize 请求体允许的最大字节数，超出时读取请求体会返回*http.MaxBytesError，<=0表示不限制
+	MaxBodySize int64
+
+	// TrustedPlatform 部署平台提供客户端IP的头部，例如PlatformCloudflare，设置后ClientIP优先读取它
+	TrustedPlatform string
+	// RemoteIPHeaders 对端是可信代理时，ClientIP依次读取的头部，默认为X-Forwarded-For、X-Real-IP和Forwarded
+	RemoteIPHeaders []string
+
+	trustedCIDRs      []*net.IPNet  //可信代理的网段，通过SetTrustedProxies设置
+	cookieSigningKeys [][]byte      //签名cookie使用的密钥
+	cookieAEADs       []cipher.AEAD //加密cookie使用的AES-GCM实例
+
+	// OpenAPIInfo 是Engine.OpenAPI生成的文档的标题、版本和说明
+	OpenAPIInfo openapi.Info
+
+	renderObserver atomic.Pointer[renderObserver] //只在测试中通过psygotest设置
 }
 
 // RouterGroup 某个具体路由分组
@@ -32,13 +55,20 @@ type RouterGroup struct {
 	middlewares []HandlerFunc //中间件是应用在分组上的，还需要存储应用在分组上的中间件
 	parent      *RouterGroup  //需要知道当前分组的父亲是谁
 	engine      *Engine       //所有的分组共享一个engine实例
+	host        string        //该分组的主机规则，为空时是默认的路由树
+	staged      *routeTable   //ReplaceRoutes正在构建的路由集合，为nil时直接修改引擎当前的路由
+
+	maxBodySize        atomic.Int64 //该分组的请求体大小限制，0表示沿用上层的设置
+	maxMultipartMemory atomic.Int64 //该分组解析multipart表单时的内存阈值，0表示沿用上层的设置
 }
 
 // New 新建一个引擎
 func New() *Engine {
-	engine := &Engine{router: newRouter()}
+	engine := &Engine{
+		MaxMultipartMemory: MaxMultipartMemory,
+	}
 	engine.RouterGroup = &RouterGroup{engine: engine}
-	engine.groups = []*RouterGroup{engine.RouterGroup}
+	engine.table.groups = []*RouterGroup{engine.RouterGroup}
 	engine.pool.New = func() any {
 		return engine.allocateContext()
 	}
@@ -87,56 +117,96 @@ func (engine *Engine) LoadHTMLGlobByConf() {
 
 // Group 在该组基础上定义一个新的 RouterGroup(实现了分组嵌套),所有的 groups 共享同一个 engine 实例
 func (group *RouterGroup) Group(prefix string) *RouterGroup {
-	engine := group.engine
 	newGroup := &RouterGroup{
 		prefix: group.prefix + prefix,
 		parent: group,
-		engine: engine,
+		engine: group.engine,
+		host:   group.host,
+		staged: group.staged,
 	}
-	engine.groups = append(engine.groups, newGroup)
+	group.update(func(t *routeTable) {
+		t.groups = append(t.groups, newGroup)
+	})
 	return newGroup
 }
 
-// addRoute 添加路由和方法
-func (group *RouterGroup) addRoute(method string, comp string, handler HandlerFunc) {
+// Host 返回一个只匹配主机host的子分组，它的路由组成一棵独立的路由树，例如
+//
+//	admin := engine.Host("admin.example.com")
+//	tenant := engine.Host(":tenant.example.com") // c.Param("tenant")取得第一段
+//	wildcard := engine.Host("*.tenant.example.com") // *匹配一段或多段
+//
+// 主机不区分大小写并且忽略端口。请求先在匹配主机的路由树中查找，精确的主机优先于带通配符的主机，
+// 都没有找到时回退到没有主机的路由。引擎自身的中间件作用于所有请求，其它分组的中间件只作用于它所在的路由树
+func (group *RouterGroup) Host(host string) *RouterGroup {
+	newGroup := &RouterGroup{
+		prefix: group.prefix,
+		parent: group,
+		engine: group.engine,
+		host:   strings.ToLower(host),
+		staged: group.staged,
+	}
+	group.update(func(t *routeTable) {
+		t.groups = append(t.groups, newGroup)
+	})
+	return newGroup
+}
+
+// SetMaxBodySize 设置该分组(包括子分组)的请求体大小限制，覆盖Engine.MaxBodySize，n<0表示不限制，
+// 和运行时增删路由一样可以在服务启动之后调用，对之后到达的请求生效
+func (group *RouterGroup) SetMaxBodySize(n int64) {
+	group.maxBodySize.Store(n)
+}
+
+// SetMaxMultipartMemory 设置该分组(包括子分组)解析multipart表单时的内存阈值，覆盖Engine.MaxMultipartMemory
+func (group *RouterGroup) SetMaxMultipartMemory(n int64) {
+	group.maxMultipartMemory.Store(n)
+}
+
+// addRoute 添加路由和方法，返回的RouteInfo可以用来补充接口文档
+func (group *RouterGroup) addRoute(method string, comp string, handler HandlerFunc) *RouteInfo {
 	pattern := group.prefix + comp
-	log.Printf("Route %4s - %s", method, pattern)
-	group.engine.router.addRoute(method, pattern, handler)
+	log.Printf("Route %4s - %s%s", method, group.host, pattern)
+	route := &RouteInfo{Method: method, Host: group.host, Path: pattern, Handler: handler}
+	group.update(func(t *routeTable) {
+		t.add(route)
+	})
+	return route
 }
 
 // GET defines the method to add GET request
-func (group *RouterGroup) GET(pattern string, handler HandlerFunc) {
-	group.addRoute("GET", pattern, handler)
+func (group *RouterGroup) GET(pattern string, handler HandlerFunc) *RouteInfo {
+	return group.addRoute("GET", pattern, handler)
 }
 
 // POST defines the method to add POST request
-func (group *RouterGroup) POST(pattern string, handler HandlerFunc) {
-	group.addRoute("POST", pattern, handler)
+func (group *RouterGroup) POST(pattern string, handler HandlerFunc) *RouteInfo {
+	return group.addRoute("POST", pattern, handler)
 }
 
 // DELETE defines the method to add DELETE request
-func (group *RouterGroup) DELETE(pattern string, handler HandlerFunc) {
-	group.addRoute("DELETE", pattern, handler)
+func (group *RouterGroup) DELETE(pattern string, handler HandlerFunc) *RouteInfo {
+	return group.addRoute("DELETE", pattern, handler)
 }
 
 // PUT defines the method to add PUT request
-func (group *RouterGroup) PUT(pattern string, handler HandlerFunc) {
-	group.addRoute("PUT", pattern, handler)
+func (group *RouterGroup) PUT(pattern string, handler HandlerFunc) *RouteInfo {
+	return group.addRoute("PUT", pattern, handler)
 }
 
 // PATCH defines the method to add PATCH request
-func (group *RouterGroup) PATCH(pattern string, handler HandlerFunc) {
-	group.addRoute("PATCH", pattern, handler)
+func (group *RouterGroup) PATCH(pattern string, handler HandlerFunc) *RouteInfo {
+	return group.addRoute("PATCH", pattern, handler)
 }
 
 // OPTIONS defines the method to add OPTIONS request
-func (group *RouterGroup) OPTIONS(pattern string, handler HandlerFunc) {
-	group.addRoute("OPTIONS", pattern, handler)
+func (group *RouterGroup) OPTIONS(pattern string, handler HandlerFunc) *RouteInfo {
+	return group.addRoute("OPTIONS", pattern, handler)
 }
 
 // HEAD defines the method to add HEAD request
-func (group *RouterGroup) HEAD(pattern string, handler HandlerFunc) {
-	group.addRoute("HEAD", pattern, handler)
+func (group *RouterGroup) HEAD(pattern string, handler HandlerFunc) *RouteInfo {
+	return group.addRoute("HEAD", pattern, handler)
 }
 
 // Run defines the method to start a http server
@@ -161,16 +231,37 @@ func (engine *Engine) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 	c := engine.pool.Get().(*Context)
 	//当我们接收到一个具体请求时，要判断该请求适用于哪些中间件，
 	//在这里我们简单通过 URL 的前缀来判断。得到中间件列表后，赋值给 c.handlers。
-	var middlewares []HandlerFunc
-	for _, group := range engine.groups {
+	rt := engine.routing.Load()
+	if rt == nil {
+		rt = engine.loadRouting()
+	}
+	c.reset(w, req)
+	tree, n := rt.router.matchHost(c)
+	maxBodySize, maxMultipartMemory := engine.MaxBodySize, engine.MaxMultipartMemory
+	for _, group := range rt.groups {
+		//引擎自身的中间件作用于所有请求，其它分组只作用于它所在的路由树
+		if group != engine.RouterGroup && group.host != tree.host {
+			continue
+		}
 		if strings.HasPrefix(req.URL.Path, group.prefix) {
-			middlewares = append(middlewares, group.middlewares...)
+			c.handlers = append(c.handlers, group.middlewares...)
+			//子分组总是排在父分组之后，所以最后一个匹配上的设置就是最具体的设置
+			if n := group.maxBodySize.Load(); n != 0 {
+				maxBodySize = n
+			}
+			if n := group.maxMultipartMemory.Load(); n != 0 {
+				maxMultipartMemory = n
+			}
 		}
 	}
-	c.reset(w, req)
-	c.handlers = middlewares
+	if maxBodySize > 0 && req.Body != nil {
+		req.Body = http.MaxBytesReader(w, req.Body, maxBodySize)
+	}
+	c.maxBodySize = maxBodySize
+	c.maxMultipartMemory = maxMultipartMemory
 	c.Engine = engine
-	engine.router.handle(c) //注意这里的调用顺序，先将中间件的handler放到context中，再把本请求路由匹配的handler放进去。
+	tree.handle(c, n) //注意这里的调用顺序，先将中间件的handler放到context中，再把本请求路由匹配的handler放进去。
+	c.writermem.finish()
 	engine.pool.Put(c)
 }
 
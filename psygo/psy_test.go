@@ -0,0 +1,176 @@
+package psygo
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// performRequest 在进程内执行一个请求，headers按照 名字, 值 成对传入，名字为Host时设置请求的主机
+func performRequest(h http.Handler, method, target string, body io.Reader, headers ...string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, body)
+	for i := 0; i+1 < len(headers); i += 2 {
+		if headers[i] == "Host" {
+			req.Host = headers[i+1]
+			continue
+		}
+		req.Header.Set(headers[i], headers[i+1])
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	return w
+}
+
+func TestMaxBodySize(t *testing.T) {
+	engine := New()
+	engine.MaxBodySize = 64
+	engine.POST("/bind", func(c *Context) {
+		var obj struct {
+			Name string `json:"name"`
+		}
+		if err := c.BindJson(&obj); err != nil {
+			return
+		}
+		c.String(http.StatusOK, "%s", obj.Name)
+	})
+	engine.POST("/form", func(c *Context) {
+		name := c.PostFormArray("name")
+		if len(c.Errors) > 0 { //超出限制的错误已经记录，状态码为413
+			return
+		}
+		c.String(http.StatusOK, "%s", name)
+	})
+	engine.POST("/file", func(c *Context) {
+		if _, err := c.FormFile("file"); err != nil {
+			return
+		}
+		c.Status(http.StatusOK)
+	})
+	engine.POST("/multipart", func(c *Context) {
+		if _, err := c.MultipartForm(); err != nil {
+			return
+		}
+		c.Status(http.StatusOK)
+	})
+	api := engine.Group("/api")
+	api.SetMaxBodySize(1 << 10) //分组的设置覆盖Engine.MaxBodySize
+	api.POST("/bind", func(c *Context) {
+		var obj struct {
+			Name string `json:"name"`
+		}
+		if err := c.BindJson(&obj); err != nil {
+			return
+		}
+		c.String(http.StatusOK, "%d", len(obj.Name))
+	})
+	unlimited := api.Group("/unlimited")
+	unlimited.SetMaxBodySize(-1)
+	unlimited.POST("/form", func(c *Context) {
+		c.String(http.StatusOK, "%d", len(c.PostFormArray("name")[0]))
+	})
+
+	small := `{"name":"psygo"}`
+	large := `{"name":"` + strings.Repeat("a", 512) + `"}`
+	if w := performRequest(engine, http.MethodPost, "/bind", strings.NewReader(small), "Content-Type", "application/json"); w.Code != http.StatusOK {
+		t.Fatalf("small body: status = %d", w.Code)
+	}
+	if w := performRequest(engine, http.MethodPost, "/bind", strings.NewReader(large), "Content-Type", "application/json"); w.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("large body: status = %d, want 413", w.Code)
+	}
+	if w := performRequest(engine, http.MethodPost, "/api/bind", strings.NewReader(large), "Content-Type", "application/json"); w.Code != http.StatusOK || w.Body.String() != "512" {
+		t.Fatalf("group limit: status = %d, body = %q", w.Code, w.Body.String())
+	}
+	form := "name=" + strings.Repeat("a", 4<<10)
+	if w := performRequest(engine, http.MethodPost, "/api/unlimited/form", strings.NewReader(form), "Content-Type", "application/x-www-form-urlencoded"); w.Code != http.StatusOK || w.Body.String() != "4096" {
+		t.Fatalf("unlimited group: status = %d, body = %q", w.Code, w.Body.String())
+	}
+
+	//表单和文件的读取和绑定一样返回413
+	if w := performRequest(engine, http.MethodPost, "/form", strings.NewReader(form), "Content-Type", "application/x-www-form-urlencoded"); w.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("PostForm: status = %d, want 413", w.Code)
+	}
+	file := []multipartFile{{"file", "a.txt", bytes.Repeat([]byte("a"), 1<<10)}}
+	if w := multipartRequest(t, engine, "/file", file); w.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("FormFile: status = %d, want 413", w.Code)
+	}
+	if w := multipartRequest(t, engine, "/multipart", file); w.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("MultipartForm: status = %d, want 413", w.Code)
+	}
+	if w := multipartRequest(t, engine, "/form", nil, "name", strings.Repeat("a", 1<<10)); w.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("multipart PostForm: status = %d, want 413", w.Code)
+	}
+}
+
+func TestMaxBodySizeErrorRecordedOnce(t *testing.T) {
+	engine := New()
+	engine.MaxBodySize = 16
+	var errs int
+	engine.POST("/form", func(c *Context) {
+		_ = c.PostFormArray("a")
+		_, _ = c.GetPostForm("b")
+		_, _ = c.FormFile("file")
+		errs = len(c.Errors)
+	})
+	w := multipartRequest(t, engine, "/form", nil, "a", strings.Repeat("a", 64))
+	if w.Code != http.StatusRequestEntityTooLarge || errs != 1 {
+		t.Fatalf("status = %d, errors = %d, want one 413 error", w.Code, errs)
+	}
+}
+
+func TestSetMaxMultipartMemory(t *testing.T) {
+	engine := New()
+	onDisk := func(c *Context) {
+		fh, err := c.FormFile("file")
+		if err != nil {
+			c.AbortWithStatus(http.StatusBadRequest)
+			return
+		}
+		f, err := fh.Open()
+		if err != nil {
+			c.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+		defer f.Close()
+		_, isFile := f.(*os.File) //超过内存阈值的文件会写入临时文件
+		c.String(http.StatusOK, "%t", isFile)
+	}
+	engine.POST("/memory", onDisk)
+	small := engine.Group("/small")
+	small.SetMaxMultipartMemory(16)
+	small.POST("/disk", onDisk)
+
+	file := []multipartFile{{"file", "a.txt", bytes.Repeat([]byte("a"), 1<<10)}}
+	if w := multipartRequest(t, engine, "/memory", file); w.Body.String() != "false" {
+		t.Fatalf("default threshold: status = %d, on disk = %q", w.Code, w.Body.String())
+	}
+	if w := multipartRequest(t, engine, "/small/disk", file); w.Body.String() != "true" {
+		t.Fatalf("group threshold: status = %d, on disk = %q", w.Code, w.Body.String())
+	}
+}
+
+func TestSetMaxBodySizeWhileServing(t *testing.T) {
+	engine := New()
+	api := engine.Group("/api")
+	api.POST("/echo", func(c *Context) {
+		body, _ := io.ReadAll(c.Req.Body)
+		c.String(http.StatusOK, "%d", len(body))
+	})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			api.SetMaxBodySize(int64(i + 1))
+			api.SetMaxMultipartMemory(int64(i + 1))
+		}
+	}()
+	for i := 0; i < 100; i++ {
+		performRequest(engine, http.MethodPost, "/api/echo", strings.NewReader("body"))
+	}
+	wg.Wait()
+}
@@ -1,8 +1,20 @@
 package psyerror
 
+import "net/http"
+
+// PsyError 既可以作为错误收集工具使用(Default、Put、Result)，
+// 也可以作为带有业务错误码和HTTP状态码的应用错误使用(New)，后者会被psygo渲染成 RFC 7807 的问题详情文档
 type PsyError struct {
 	err     error
 	ErrFunc ErrorFunc
+
+	Code       string         //业务错误码，例如 goods.not_found，errors.Is 按照它判断两个错误是否相同
+	Status     int            //HTTP状态码，默认500
+	Type       string         //问题类型的URI，默认为 about:blank
+	Title      string         //问题类型的简短描述，同一个Code的错误Title应当相同
+	Detail     string         //本次错误的具体说明
+	Instance   string         //发生错误的资源URI，默认为请求路径
+	Extensions map[string]any //扩展字段
 }
 
 type ErrorFunc func(psyError *PsyError)
@@ -11,8 +23,99 @@ func Default() *PsyError {
 	return &PsyError{}
 }
 
+// New 新建一个应用错误，一般定义为包级变量，使用时通过WithDetail、Wrap等方法派生出具体的错误，例如
+//
+//	var ErrGoodsNotFound = psyerror.New(http.StatusNotFound, "goods.not_found", "goods not found")
+//	return ErrGoodsNotFound.WithDetail("goods 42 does not exist").Wrap(sql.ErrNoRows)
+func New(status int, code, title string) *PsyError {
+	return &PsyError{Status: status, Code: code, Title: title}
+}
+
 func (e *PsyError) Error() string {
-	return e.err.Error()
+	msg := e.Detail
+	if msg == "" {
+		msg = e.Title
+	}
+	if e.err != nil {
+		if msg == "" {
+			return e.err.Error()
+		}
+		return msg + ": " + e.err.Error()
+	}
+	if msg == "" {
+		msg = e.Code
+	}
+	if msg == "" {
+		msg = http.StatusText(e.HTTPStatus())
+	}
+	return msg
+}
+
+// Unwrap 返回被包装的错误
+func (e *PsyError) Unwrap() error {
+	return e.err
+}
+
+// Is 两个错误的Code相同时认为是同一个错误，使errors.Is可以和包级定义的错误比较
+func (e *PsyError) Is(target error) bool {
+	t, ok := target.(*PsyError)
+	if !ok || e.Code == "" {
+		return false
+	}
+	return e.Code == t.Code
+}
+
+// HTTPStatus 返回错误对应的HTTP状态码，没有设置时为500
+func (e *PsyError) HTTPStatus() int {
+	if e.Status == 0 {
+		return http.StatusInternalServerError
+	}
+	return e.Status
+}
+
+// Wrap 返回一个包装了err的副本
+func (e *PsyError) Wrap(err error) *PsyError {
+	cp := e.clone()
+	cp.err = err
+	return cp
+}
+
+// WithDetail 返回一个设置了Detail的副本
+func (e *PsyError) WithDetail(detail string) *PsyError {
+	cp := e.clone()
+	cp.Detail = detail
+	return cp
+}
+
+// WithInstance 返回一个设置了Instance的副本
+func (e *PsyError) WithInstance(instance string) *PsyError {
+	cp := e.clone()
+	cp.Instance = instance
+	return cp
+}
+
+// WithType 返回一个设置了问题类型URI的副本
+func (e *PsyError) WithType(typeURI string) *PsyError {
+	cp := e.clone()
+	cp.Type = typeURI
+	return cp
+}
+
+// WithExtension 返回一个增加了扩展字段的副本
+func (e *PsyError) WithExtension(key string, value any) *PsyError {
+	cp := e.clone()
+	cp.Extensions = make(map[string]any, len(e.Extensions)+1)
+	for k, v := range e.Extensions {
+		cp.Extensions[k] = v
+	}
+	cp.Extensions[key] = value
+	return cp
+}
+
+// clone 拷贝一份错误，包级定义的错误派生时不会被修改
+func (e *PsyError) clone() *PsyError {
+	cp := *e
+	return &cp
 }
 
 func (e *PsyError) Put(err error) {
@@ -31,7 +134,10 @@ func (e *PsyError) Result(errFunc ErrorFunc) {
 	e.ErrFunc = errFunc
 }
 
-// ExecResult 执行错误处理方法
+// ExecResult 执行错误处理方法，没有设置错误处理方法时什么也不做
 func (e *PsyError) ExecResult() {
+	if e.ErrFunc == nil {
+		return
+	}
 	e.ErrFunc(e)
 }
@@ -0,0 +1,51 @@
+// Package psygotest 提供了在进程内测试psygo处理函数和中间件的工具：
+// 用NewRequest构造请求并交给Engine处理，再对响应的状态码、头部、JSON和渲染的模板进行断言，例如
+//
+//	psygotest.NewRequest(engine).POST("/goods").JSON(goods).Do(t).
+//		AssertStatus(http.StatusCreated).
+//		AssertJSONPath("data.id", 42)
+//
+// 也可以用Context得到一个还没有经过路由的Context，设置好Params和Keys后单独执行某个中间件
+package psygotest
+
+import (
+	"github.com/Psychopath-H/psyweb-master/psygo"
+	"github.com/Psychopath-H/psyweb-master/psygo/internal/testhooks"
+	"net/http"
+)
+
+// hooks 返回psygo通过internal/testhooks提供的测试操作
+func hooks() testhooks.Hooks[*psygo.Context, *psygo.Engine, psygo.HandlerFunc] {
+	return testhooks.Get[*psygo.Context, *psygo.Engine, psygo.HandlerFunc]()
+}
+
+// CreateTestContext 新建一个Engine，并返回一个写入w的Context，它的请求是 GET /
+func CreateTestContext(w http.ResponseWriter) (*psygo.Context, *psygo.Engine) {
+	engine := psygo.New()
+	return hooks().NewContext(w, engine), engine
+}
+
+// SetParams 设置c的路由参数，相当于路由匹配到了 /goods/:id 这样的规则
+func SetParams(c *psygo.Context, params map[string]string) {
+	c.Params = c.Params[:0]
+	for k, v := range params {
+		c.Params = append(c.Params, psygo.Param{Key: k, Value: v})
+	}
+}
+
+// SetKeys 把keys依次写入c，相当于前面的中间件已经调用过c.Set
+func SetKeys(c *psygo.Context, keys map[string]any) {
+	for k, v := range keys {
+		c.Set(k, v)
+	}
+}
+
+// SetRoute 设置c匹配到的路由规则，c.FullPath()会返回它
+func SetRoute(c *psygo.Context, pattern string) {
+	hooks().SetFullPath(c, pattern)
+}
+
+// Run 不经过路由，直接在c上依次执行handlers，通常是被测试的中间件加上一个模拟的业务处理函数
+func Run(c *psygo.Context, handlers ...psygo.HandlerFunc) {
+	hooks().Run(c, handlers...)
+}
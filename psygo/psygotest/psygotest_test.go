@@ -0,0 +1,120 @@
+package psygotest
+
+import (
+	"github.com/Psychopath-H/psyweb-master/psygo"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestJSONRequest(t *testing.T) {
+	engine := psygo.New()
+	engine.POST("/goods/:id", func(c *psygo.Context) {
+		var body struct {
+			Name string `json:"name"`
+		}
+		if err := c.ShouldBindJSON(&body); err != nil {
+			c.AbortWithStatus(http.StatusBadRequest)
+			return
+		}
+		c.Header("X-Goods", c.Param("id"))
+		c.JSON(http.StatusCreated, psygo.H{
+			"data": psygo.H{"id": c.Param("id"), "name": body.Name, "tags": []string{c.GetQuery("tag")}},
+		})
+	})
+
+	NewRequest(engine).POST("/goods/42").Query("tag", "new").JSON(psygo.H{"name": "book"}).Do(t).
+		AssertStatus(http.StatusCreated).
+		AssertHeader("X-Goods", "42").
+		AssertJSONPath("data.name", "book").
+		AssertJSONPath("data.tags.0", "new").
+		AssertJSON(psygo.H{"data": psygo.H{"id": "42", "name": "book", "tags": []string{"new"}}})
+}
+
+func TestFormAndMultipart(t *testing.T) {
+	engine := psygo.New()
+	engine.POST("/form", func(c *psygo.Context) {
+		name, _ := c.GetPostForm("name")
+		c.String(http.StatusOK, "%s", name)
+	})
+	engine.POST("/upload", func(c *psygo.Context) {
+		file, err := c.FormFile("file")
+		if err != nil {
+			c.AbortWithStatus(http.StatusBadRequest)
+			return
+		}
+		f, _ := file.Open()
+		defer f.Close()
+		content, _ := io.ReadAll(f)
+		desc, _ := c.GetPostForm("desc")
+		c.String(http.StatusOK, "%s:%s:%s", file.Filename, desc, content)
+	})
+
+	NewRequest(engine).POST("/form").FormValue("name", "psy").Do(t).
+		AssertStatus(http.StatusOK).
+		AssertBody("psy")
+	NewRequest(engine).POST("/upload").
+		MultipartField("desc", "cover").
+		MultipartFile("file", "a.txt", []byte("hello")).
+		Do(t).
+		AssertStatus(http.StatusOK).
+		AssertBody("a.txt:cover:hello")
+}
+
+func TestTemplate(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "index.tmpl"), []byte(`{{define "index.tmpl"}}hello {{.}}{{end}}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	engine := psygo.New()
+	engine.LoadHTMLGlob(filepath.Join(dir, "*.tmpl"))
+	engine.GET("/", func(c *psygo.Context) {
+		c.TemplateLoaded(http.StatusOK, "index.tmpl", "psy")
+	})
+
+	resp := NewRequest(engine).Do(t).
+		AssertStatus(http.StatusOK).
+		AssertTemplate("index.tmpl").
+		AssertBody("hello psy")
+	if data, ok := resp.TemplateData("index.tmpl"); !ok || data != "psy" {
+		t.Fatalf("template data = %v, %v", data, ok)
+	}
+}
+
+func TestMiddlewareAlone(t *testing.T) {
+	requireOwner := func(c *psygo.Context) {
+		if user, _ := c.Get(psygo.AuthUserKey); user != c.Param("user") {
+			c.AbortWithStatus(http.StatusForbidden)
+			return
+		}
+		c.Next()
+	}
+	final := func(c *psygo.Context) {
+		c.String(http.StatusOK, "%s", c.FullPath())
+	}
+
+	c, resp := NewRequest(psygo.New()).GET("/users/alice").Context(t)
+	SetParams(c, map[string]string{"user": "alice"})
+	SetKeys(c, map[string]any{psygo.AuthUserKey: "bob"})
+	Run(c, requireOwner, final)
+	resp.AssertStatus(http.StatusForbidden)
+
+	c, resp = NewRequest(psygo.New()).GET("/users/alice").Context(t)
+	SetParams(c, map[string]string{"user": "alice"})
+	SetKeys(c, map[string]any{psygo.AuthUserKey: "alice"})
+	SetRoute(c, "/users/:user")
+	Run(c, requireOwner, final)
+	resp.AssertStatus(http.StatusOK).AssertBody("/users/:user")
+}
+
+func TestCreateTestContext(t *testing.T) {
+	resp := newResponse(t)
+	c, engine := CreateTestContext(resp)
+	if c.Engine != engine || c.Req == nil || c.Req.URL.Path != "/" {
+		t.Fatal("unexpected test context")
+	}
+	c.JSON(http.StatusOK, psygo.H{"ok": true})
+	resp.AssertStatus(http.StatusOK).AssertJSONPath("ok", true)
+}
@@ -0,0 +1,249 @@
+package psygotest
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/Psychopath-H/psyweb-master/psygo"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// RequestBuilder 以链式调用的方式构造一个测试请求，默认是 GET /
+type RequestBuilder struct {
+	engine      *psygo.Engine
+	method      string
+	path        string
+	host        string
+	query       url.Values
+	header      http.Header
+	cookies     []*http.Cookie
+	body        []byte
+	contentType string
+	form        url.Values
+	fields      url.Values
+	files       []multipartFile
+	err         error
+}
+
+type multipartFile struct {
+	field    string
+	filename string
+	content  []byte
+}
+
+// NewRequest 返回一个发给engine的请求构造器
+func NewRequest(engine *psygo.Engine) *RequestBuilder {
+	return &RequestBuilder{
+		engine: engine,
+		method: http.MethodGet,
+		path:   "/",
+		query:  url.Values{},
+		header: http.Header{},
+	}
+}
+
+// Method 设置请求方法和路径，路径中可以带有查询参数
+func (b *RequestBuilder) Method(method, path string) *RequestBuilder {
+	b.method = method
+	b.path = path
+	return b
+}
+
+func (b *RequestBuilder) GET(path string) *RequestBuilder {
+	return b.Method(http.MethodGet, path)
+}
+
+func (b *RequestBuilder) POST(path string) *RequestBuilder {
+	return b.Method(http.MethodPost, path)
+}
+
+func (b *RequestBuilder) PUT(path string) *RequestBuilder {
+	return b.Method(http.MethodPut, path)
+}
+
+func (b *RequestBuilder) PATCH(path string) *RequestBuilder {
+	return b.Method(http.MethodPatch, path)
+}
+
+func (b *RequestBuilder) DELETE(path string) *RequestBuilder {
+	return b.Method(http.MethodDelete, path)
+}
+
+// Host 设置请求的主机，默认是example.com
+func (b *RequestBuilder) Host(host string) *RequestBuilder {
+	b.host = host
+	return b
+}
+
+// Header 设置一个请求头
+func (b *RequestBuilder) Header(key, value string) *RequestBuilder {
+	b.header.Set(key, value)
+	return b
+}
+
+// Query 增加一个查询参数
+func (b *RequestBuilder) Query(key, value string) *RequestBuilder {
+	b.query.Add(key, value)
+	return b
+}
+
+// Cookie 增加一个cookie
+func (b *RequestBuilder) Cookie(cookie *http.Cookie) *RequestBuilder {
+	b.cookies = append(b.cookies, cookie)
+	return b
+}
+
+// BasicAuth 设置BasicAuth认证的用户名和密码
+func (b *RequestBuilder) BasicAuth(username, password string) *RequestBuilder {
+	req := http.Request{Header: http.Header{}}
+	req.SetBasicAuth(username, password)
+	return b.Header("Authorization", req.Header.Get("Authorization"))
+}
+
+// Body 以原样的contentType和body作为请求体
+func (b *RequestBuilder) Body(contentType string, body []byte) *RequestBuilder {
+	b.contentType = contentType
+	b.body = body
+	return b
+}
+
+// JSON 把obj编码成JSON作为请求体
+func (b *RequestBuilder) JSON(obj any) *RequestBuilder {
+	data, err := json.Marshal(obj)
+	if err != nil {
+		b.err = err
+		return b
+	}
+	return b.Body("application/json", data)
+}
+
+// Form 以 application/x-www-form-urlencoded 的形式发送values
+func (b *RequestBuilder) Form(values url.Values) *RequestBuilder {
+	if b.form == nil {
+		b.form = url.Values{}
+	}
+	for k, vs := range values {
+		b.form[k] = append(b.form[k], vs...)
+	}
+	return b
+}
+
+// FormValue 增加一个表单字段
+func (b *RequestBuilder) FormValue(key, value string) *RequestBuilder {
+	return b.Form(url.Values{key: {value}})
+}
+
+// MultipartField 增加一个 multipart/form-data 的普通字段
+func (b *RequestBuilder) MultipartField(key, value string) *RequestBuilder {
+	if b.fields == nil {
+		b.fields = url.Values{}
+	}
+	b.fields.Add(key, value)
+	return b
+}
+
+// MultipartFile 增加一个 multipart/form-data 的文件
+func (b *RequestBuilder) MultipartFile(field, filename string, content []byte) *RequestBuilder {
+	b.files = append(b.files, multipartFile{field: field, filename: filename, content: content})
+	return b
+}
+
+// Build 生成*http.Request，同时设置了多种请求体时multipart优先，其次是表单，最后是Body和JSON
+func (b *RequestBuilder) Build() (*http.Request, error) {
+	if b.err != nil {
+		return nil, b.err
+	}
+	body, contentType, err := b.encodeBody()
+	if err != nil {
+		return nil, err
+	}
+	target := b.path
+	if len(b.query) > 0 {
+		sep := "?"
+		if strings.Contains(target, "?") {
+			sep = "&"
+		}
+		target += sep + b.query.Encode()
+	}
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	req := httptest.NewRequest(b.method, target, reader)
+	if b.host != "" {
+		req.Host = b.host
+	}
+	for k, vs := range b.header {
+		req.Header[k] = append([]string(nil), vs...)
+	}
+	if contentType != "" && req.Header.Get("Content-Type") == "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	for _, cookie := range b.cookies {
+		req.AddCookie(cookie)
+	}
+	return req, nil
+}
+
+// encodeBody 按照设置的内容生成请求体和对应的Content-Type
+func (b *RequestBuilder) encodeBody() ([]byte, string, error) {
+	if len(b.fields) > 0 || len(b.files) > 0 {
+		var buf bytes.Buffer
+		mw := multipart.NewWriter(&buf)
+		for k, vs := range b.fields {
+			for _, v := range vs {
+				if err := mw.WriteField(k, v); err != nil {
+					return nil, "", err
+				}
+			}
+		}
+		for _, f := range b.files {
+			fw, err := mw.CreateFormFile(f.field, f.filename)
+			if err != nil {
+				return nil, "", err
+			}
+			if _, err = fw.Write(f.content); err != nil {
+				return nil, "", err
+			}
+		}
+		if err := mw.Close(); err != nil {
+			return nil, "", err
+		}
+		return buf.Bytes(), mw.FormDataContentType(), nil
+	}
+	if len(b.form) > 0 {
+		return []byte(b.form.Encode()), "application/x-www-form-urlencoded", nil
+	}
+	return b.body, b.contentType, nil
+}
+
+// Do 把请求交给engine处理并返回响应，构造请求失败时终止测试
+func (b *RequestBuilder) Do(t testing.TB) *Response {
+	t.Helper()
+	req, err := b.Build()
+	if err != nil {
+		t.Fatalf("psygotest: build request: %v", err)
+	}
+	resp := newResponse(t)
+	b.engine.ServeHTTP(resp.ResponseRecorder, resp.observe(b.engine, req))
+	return resp
+}
+
+// Context 不经过路由，返回一个处理这个请求的Context和记录它输出的Response，
+// 配合SetParams、SetKeys和Run可以单独测试某个中间件
+func (b *RequestBuilder) Context(t testing.TB) (*psygo.Context, *Response) {
+	t.Helper()
+	req, err := b.Build()
+	if err != nil {
+		t.Fatalf("psygotest: build request: %v", err)
+	}
+	resp := newResponse(t)
+	c := hooks().NewContext(resp.ResponseRecorder, b.engine)
+	hooks().Reset(c, resp.ResponseRecorder, resp.observe(b.engine, req))
+	return c, resp
+}
@@ -0,0 +1,193 @@
+package psygotest
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/Psychopath-H/psyweb-master/psygo"
+	"github.com/Psychopath-H/psyweb-master/psygo/render"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// Response 记录了一次测试请求的响应，断言方法失败时调用t.Errorf并返回自身，可以继续链式调用
+type Response struct {
+	*httptest.ResponseRecorder
+	t testing.TB
+
+	mu      sync.Mutex
+	renders []render.Render //处理请求时通过c.Render渲染的内容
+}
+
+// newResponse 返回一个空的Response
+func newResponse(t testing.TB) *Response {
+	return &Response{ResponseRecorder: httptest.NewRecorder(), t: t}
+}
+
+type responseKey struct{}
+
+// observe 让resp记录engine处理req时渲染的内容，观察者只在测试用的engine上设置，正常的请求不受影响
+func (resp *Response) observe(engine *psygo.Engine, req *http.Request) *http.Request {
+	hooks().SetRenderObserver(engine, recordRender)
+	return req.WithContext(context.WithValue(req.Context(), responseKey{}, resp))
+}
+
+// recordRender 把渲染的内容记录到请求对应的Response中
+func recordRender(c *psygo.Context, r render.Render) {
+	resp, ok := c.Req.Context().Value(responseKey{}).(*Response)
+	if !ok {
+		return
+	}
+	resp.mu.Lock()
+	resp.renders = append(resp.renders, r)
+	resp.mu.Unlock()
+}
+
+// Renders 返回处理请求时渲染的全部内容
+func (resp *Response) Renders() []render.Render {
+	resp.mu.Lock()
+	defer resp.mu.Unlock()
+	return append([]render.Render(nil), resp.renders...)
+}
+
+// AssertStatus 断言状态码
+func (resp *Response) AssertStatus(code int) *Response {
+	resp.t.Helper()
+	if resp.Code != code {
+		resp.t.Errorf("psygotest: status = %d, want %d, body: %s", resp.Code, code, resp.Body.String())
+	}
+	return resp
+}
+
+// AssertHeader 断言响应头key的值
+func (resp *Response) AssertHeader(key, want string) *Response {
+	resp.t.Helper()
+	if got := resp.Header().Get(key); got != want {
+		resp.t.Errorf("psygotest: header %s = %q, want %q", key, got, want)
+	}
+	return resp
+}
+
+// AssertBody 断言整个响应体
+func (resp *Response) AssertBody(want string) *Response {
+	resp.t.Helper()
+	if got := resp.Body.String(); got != want {
+		resp.t.Errorf("psygotest: body = %q, want %q", got, want)
+	}
+	return resp
+}
+
+// AssertBodyContains 断言响应体中含有substr
+func (resp *Response) AssertBodyContains(substr string) *Response {
+	resp.t.Helper()
+	if got := resp.Body.String(); !strings.Contains(got, substr) {
+		resp.t.Errorf("psygotest: body %q does not contain %q", got, substr)
+	}
+	return resp
+}
+
+// DecodeJSON 把响应体解码到v中，失败时终止测试
+func (resp *Response) DecodeJSON(v any) {
+	resp.t.Helper()
+	if err := json.Unmarshal(resp.Body.Bytes(), v); err != nil {
+		resp.t.Fatalf("psygotest: decode json body %q: %v", resp.Body.String(), err)
+	}
+}
+
+// JSONPath 在JSON响应体中按照以.分隔的路径取值，数组用下标表示，例如 data.items.0.name，
+// 空路径表示整个响应体，取不到时返回false
+func (resp *Response) JSONPath(path string) (any, bool) {
+	var value any
+	if err := json.Unmarshal(resp.Body.Bytes(), &value); err != nil {
+		return nil, false
+	}
+	if path == "" {
+		return value, true
+	}
+	for _, part := range strings.Split(path, ".") {
+		switch node := value.(type) {
+		case map[string]any:
+			v, ok := node[part]
+			if !ok {
+				return nil, false
+			}
+			value = v
+		case []any:
+			i, err := strconv.Atoi(part)
+			if err != nil || i < 0 || i >= len(node) {
+				return nil, false
+			}
+			value = node[i]
+		default:
+			return nil, false
+		}
+	}
+	return value, true
+}
+
+// AssertJSONPath 断言JSON响应体中path处的值等于want，want会先经过一次JSON编解码，因此可以直接写 42 或者 H{...}
+func (resp *Response) AssertJSONPath(path string, want any) *Response {
+	resp.t.Helper()
+	got, ok := resp.JSONPath(path)
+	if !ok {
+		resp.t.Errorf("psygotest: json path %q not found in body %s", path, resp.Body.String())
+		return resp
+	}
+	normalized, err := normalizeJSON(want)
+	if err != nil {
+		resp.t.Errorf("psygotest: encode want value: %v", err)
+		return resp
+	}
+	if !reflect.DeepEqual(got, normalized) {
+		resp.t.Errorf("psygotest: json path %q = %#v, want %#v", path, got, normalized)
+	}
+	return resp
+}
+
+// AssertJSON 断言整个JSON响应体等于want
+func (resp *Response) AssertJSON(want any) *Response {
+	resp.t.Helper()
+	return resp.AssertJSONPath("", want)
+}
+
+// AssertTemplate 断言处理请求时使用名为name的模板渲染过响应
+func (resp *Response) AssertTemplate(name string) *Response {
+	resp.t.Helper()
+	var rendered []string
+	for _, r := range resp.Renders() {
+		if html, ok := r.(*render.HTML); ok && html.IsTemplate {
+			if html.Name == name {
+				return resp
+			}
+			rendered = append(rendered, html.Name)
+		}
+	}
+	resp.t.Errorf("psygotest: template %q was not rendered, rendered templates: %v", name, rendered)
+	return resp
+}
+
+// TemplateData 返回最后一次使用名为name的模板渲染时的数据
+func (resp *Response) TemplateData(name string) (any, bool) {
+	renders := resp.Renders()
+	for i := len(renders) - 1; i >= 0; i-- {
+		if html, ok := renders[i].(*render.HTML); ok && html.IsTemplate && html.Name == name {
+			return html.Data, true
+		}
+	}
+	return nil, false
+}
+
+// normalizeJSON 把v编码再解码，使它和json.Unmarshal得到的值可以直接比较
+func normalizeJSON(v any) (any, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var normalized any
+	err = json.Unmarshal(data, &normalized)
+	return normalized, err
+}
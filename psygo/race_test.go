@@ -0,0 +1,6 @@
+//go:build race
+
+package psygo
+
+// raceEnabled 竞态检测会让sync.Pool随机丢弃对象，统计内存分配的测试需要跳过
+const raceEnabled = true
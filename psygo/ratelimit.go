@@ -0,0 +1,317 @@
+package psygo
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// RateLimitAlgorithm 限流所使用的算法
+type RateLimitAlgorithm int
+
+const (
+	// TokenBucket 令牌桶算法，允许一定程度的突发流量
+	TokenBucket RateLimitAlgorithm = iota
+	// SlidingWindow 滑动窗口计数算法，按前后两个窗口加权估算当前窗口内的请求数
+	SlidingWindow
+)
+
+// RateLimitKeyFunc 决定请求归属于哪个限流桶，返回空字符串表示该请求不参与限流
+type RateLimitKeyFunc func(c *Context) string
+
+// RateLimitState 某个key在存储中的限流状态，令牌桶和滑动窗口共用这一个结构
+type RateLimitState struct {
+	Tokens    float64   //令牌桶：桶中剩余的令牌数
+	Last      time.Time //令牌桶：上次补充令牌的时间；滑动窗口：当前窗口的起始时间
+	PrevCount int       //滑动窗口：上一个窗口内的请求数
+	CurrCount int       //滑动窗口：当前窗口内的请求数
+}
+
+// RateLimitStore 限流状态的存储，实现了这个接口就可以把状态放到redis等外部存储中
+type RateLimitStore interface {
+	// Take 原子地取出key对应的状态交给fn修改，key不存在时fn拿到的是零值状态，
+	// ttl 是该key闲置多久之后可以被清理掉
+	Take(key string, ttl time.Duration, fn func(state *RateLimitState))
+}
+
+// RateLimitOptions 限流中间件的配置
+type RateLimitOptions struct {
+	Algorithm RateLimitAlgorithm
+	Limit     int           //每个Period内允许通过的请求数
+	Period    time.Duration //限流的时间窗口，默认1秒
+	Burst     int           //令牌桶的容量，默认和Limit相同
+	Name      string        //多个分组共享同一个Store时用来区分key的前缀
+	KeyFunc   RateLimitKeyFunc
+	Store     RateLimitStore
+	Handler   HandlerFunc //请求被限流时的处理函数，默认返回429
+}
+
+// RateLimitResult 一次限流判断的结果
+type RateLimitResult struct {
+	Allowed    bool
+	Limit      int
+	Remaining  int
+	Reset      time.Duration //距离配额完全恢复(令牌桶)或窗口结束(滑动窗口)的时间
+	RetryAfter time.Duration //被拒绝时，距离下一次可以请求的时间
+}
+
+// RateLimitResultKey 限流结果在Context.Keys中存储的key，自定义的Handler可以通过c.Get取出
+const RateLimitResultKey = "psygo/ratelimit"
+
+// RateLimit 返回一个限流中间件，不同的RouterGroup可以Use不同配置的RateLimit实现不同的限制
+func RateLimit(opts RateLimitOptions) HandlerFunc {
+	assert1(opts.Limit > 0, "RateLimit: Limit must be greater than 0")
+	if opts.Period <= 0 {
+		opts.Period = time.Second
+	}
+	if opts.Burst <= 0 {
+		opts.Burst = opts.Limit
+	}
+	if opts.KeyFunc == nil {
+		opts.KeyFunc = KeyByClientIP
+	}
+	if opts.Store == nil {
+		opts.Store = NewMemoryRateLimitStore(time.Minute)
+	}
+	if opts.Handler == nil {
+		opts.Handler = defaultRateLimitHandler
+	}
+	limiter := &rateLimiter{opts: opts}
+	return func(c *Context) {
+		key := opts.KeyFunc(c)
+		if key == "" {
+			c.Next()
+			return
+		}
+		if opts.Name != "" {
+			key = opts.Name + ":" + key
+		}
+		result := limiter.take(key, time.Now())
+		c.Set(RateLimitResultKey, result)
+		writeRateLimitHeaders(c, result)
+		if !result.Allowed {
+			c.Header("Retry-After", strconv.Itoa(ceilSeconds(result.RetryAfter)))
+			opts.Handler(c)
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// KeyByClientIP 按照客户端IP进行限流，在代理之后时需要先通过Engine.SetTrustedProxies设置可信代理
+func KeyByClientIP(c *Context) string {
+	return c.ClientIP()
+}
+
+// KeyByUser 按照BasicAuth认证后存放在AuthUserKey中的用户进行限流，未认证的请求按照客户端IP限流
+func KeyByUser(c *Context) string {
+	if user, ok := c.Get(AuthUserKey); ok {
+		if s, ok := user.(string); ok && s != "" {
+			return "user:" + s
+		}
+	}
+	return "ip:" + c.ClientIP()
+}
+
+// KeyByRoute 按照路由规则(而不是实际请求路径)进行限流，所有访问同一路由的请求共享一个配额
+func KeyByRoute(c *Context) string {
+	return c.Method + "-" + c.FullPath()
+}
+
+// rateLimiter 根据配置的算法计算每个key的配额
+type rateLimiter struct {
+	opts RateLimitOptions
+}
+
+func (l *rateLimiter) take(key string, now time.Time) (result RateLimitResult) {
+	switch l.opts.Algorithm {
+	case SlidingWindow:
+		l.opts.Store.Take(key, 2*l.opts.Period, func(state *RateLimitState) {
+			result = l.slidingWindow(state, now)
+		})
+	default:
+		//桶从空到满所需的时间就是key闲置后可以被清理的时间
+		ttl := time.Duration(float64(l.opts.Period) * float64(l.opts.Burst) / float64(l.opts.Limit))
+		l.opts.Store.Take(key, ttl, func(state *RateLimitState) {
+			result = l.tokenBucket(state, now)
+		})
+	}
+	return
+}
+
+// tokenBucket 令牌桶：以 Limit/Period 的速率往容量为Burst的桶里补充令牌，每个请求消耗一个令牌
+func (l *rateLimiter) tokenBucket(state *RateLimitState, now time.Time) RateLimitResult {
+	capacity := float64(l.opts.Burst)
+	perSecond := float64(l.opts.Limit) / l.opts.Period.Seconds()
+	if state.Last.IsZero() {
+		state.Tokens = capacity
+	} else if elapsed := now.Sub(state.Last).Seconds(); elapsed > 0 {
+		state.Tokens = math.Min(capacity, state.Tokens+elapsed*perSecond)
+	}
+	state.Last = now
+
+	result := RateLimitResult{Limit: l.opts.Burst}
+	if state.Tokens >= 1 {
+		state.Tokens--
+		result.Allowed = true
+	} else {
+		result.RetryAfter = secondsToDuration((1 - state.Tokens) / perSecond)
+	}
+	result.Remaining = int(state.Tokens)
+	result.Reset = secondsToDuration((capacity - state.Tokens) / perSecond)
+	return result
+}
+
+// slidingWindow 滑动窗口：用上一个窗口的请求数按剩余比例加权，再加上当前窗口的请求数估算最近一个Period内的请求量
+func (l *rateLimiter) slidingWindow(state *RateLimitState, now time.Time) RateLimitResult {
+	period := l.opts.Period
+	windowStart := now.Truncate(period)
+	if !state.Last.Equal(windowStart) {
+		if state.Last.Equal(windowStart.Add(-period)) {
+			state.PrevCount = state.CurrCount
+		} else {
+			state.PrevCount = 0
+		}
+		state.CurrCount = 0
+		state.Last = windowStart
+	}
+	elapsed := now.Sub(windowStart)
+	weight := 1 - float64(elapsed)/float64(period)
+	estimated := float64(state.PrevCount)*weight + float64(state.CurrCount)
+
+	result := RateLimitResult{Limit: l.opts.Limit, Reset: period - elapsed}
+	if estimated+1 <= float64(l.opts.Limit) {
+		state.CurrCount++
+		estimated++
+		result.Allowed = true
+	} else if state.PrevCount > 0 && float64(state.CurrCount) < float64(l.opts.Limit) {
+		//上一个窗口的权重随时间线性下降，算出权重降到可以再放行一个请求的时刻
+		need := (float64(l.opts.Limit) - 1 - float64(state.CurrCount)) / float64(state.PrevCount)
+		result.RetryAfter = time.Duration((1-need)*float64(period)) - elapsed
+	} else {
+		result.RetryAfter = period - elapsed
+	}
+	if result.RetryAfter < 0 {
+		result.RetryAfter = 0
+	}
+	result.Remaining = l.opts.Limit - int(math.Ceil(estimated))
+	if result.Remaining < 0 {
+		result.Remaining = 0
+	}
+	return result
+}
+
+// writeRateLimitHeaders 按照 draft-ietf-httpapi-ratelimit-headers 写入 RateLimit-* 头部
+func writeRateLimitHeaders(c *Context, result RateLimitResult) {
+	c.Header("RateLimit-Limit", strconv.Itoa(result.Limit))
+	c.Header("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+	c.Header("RateLimit-Reset", strconv.Itoa(ceilSeconds(result.Reset)))
+}
+
+func defaultRateLimitHandler(c *Context) {
+	c.Fail(http.StatusTooManyRequests, "too many requests")
+}
+
+func secondsToDuration(seconds float64) time.Duration {
+	return time.Duration(seconds * float64(time.Second))
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
+
+// MemoryRateLimitStore 是基于内存的限流状态存储，有key的时候后台协程会定期清理闲置的key
+type MemoryRateLimitStore struct {
+	mu       sync.Mutex
+	entries  map[string]*rateLimitEntry
+	interval time.Duration
+	running  bool //清理协程是否在运行
+	closed   bool
+	stop     chan struct{}
+	once     sync.Once
+}
+
+type rateLimitEntry struct {
+	state    RateLimitState
+	expireAt time.Time
+}
+
+// NewMemoryRateLimitStore 新建一个内存存储，cleanupInterval 是清理闲置key的间隔，<=0表示不清理。
+// 清理协程在第一次Take时才启动，所有key都被清理掉之后自动退出，不再使用的存储不需要Close也不会留下协程
+func NewMemoryRateLimitStore(cleanupInterval time.Duration) *MemoryRateLimitStore {
+	return &MemoryRateLimitStore{
+		entries:  make(map[string]*rateLimitEntry),
+		interval: cleanupInterval,
+		stop:     make(chan struct{}),
+	}
+}
+
+// Take 实现了RateLimitStore接口
+func (s *MemoryRateLimitStore) Take(key string, ttl time.Duration, fn func(state *RateLimitState)) {
+	now := time.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entry, ok := s.entries[key]
+	if !ok || now.After(entry.expireAt) {
+		entry = &rateLimitEntry{}
+		s.entries[key] = entry
+	}
+	fn(&entry.state)
+	entry.expireAt = now.Add(ttl)
+	if s.interval > 0 && !s.running && !s.closed {
+		s.running = true
+		go s.janitor()
+	}
+}
+
+// Len 返回当前存储的key的数量
+func (s *MemoryRateLimitStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.entries)
+}
+
+// Close 停止后台的清理协程，之后也不会再启动
+func (s *MemoryRateLimitStore) Close() {
+	s.once.Do(func() {
+		s.mu.Lock()
+		s.closed = true
+		s.mu.Unlock()
+		close(s.stop)
+	})
+}
+
+// janitor 定期删除过期的key，避免大量一次性的客户端把内存撑爆，没有key之后退出
+func (s *MemoryRateLimitStore) janitor() {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case now := <-ticker.C:
+			if s.cleanup(now) {
+				return
+			}
+		case <-s.stop:
+			return
+		}
+	}
+}
+
+// cleanup 删除now时已经过期的key，存储清空时标记清理协程退出并返回true
+func (s *MemoryRateLimitStore) cleanup(now time.Time) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for key, entry := range s.entries {
+		if now.After(entry.expireAt) {
+			delete(s.entries, key)
+		}
+	}
+	if len(s.entries) == 0 {
+		s.running = false
+		return true
+	}
+	return false
+}
@@ -0,0 +1,154 @@
+package psygo
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func newTestLimiter(opts RateLimitOptions) *rateLimiter {
+	if opts.Period == 0 {
+		opts.Period = time.Second
+	}
+	if opts.Burst == 0 {
+		opts.Burst = opts.Limit
+	}
+	opts.Store = NewMemoryRateLimitStore(0)
+	return &rateLimiter{opts: opts}
+}
+
+func TestTokenBucket(t *testing.T) {
+	l := newTestLimiter(RateLimitOptions{Limit: 2, Burst: 4})
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	//满桶时可以突发Burst个请求
+	for i := 3; i >= 0; i-- {
+		result := l.take("k", start)
+		if !result.Allowed || result.Remaining != i || result.Limit != 4 {
+			t.Fatalf("request %d: %+v", 4-i, result)
+		}
+	}
+	result := l.take("k", start)
+	if result.Allowed || result.RetryAfter != 500*time.Millisecond || result.Reset != 2*time.Second {
+		t.Fatalf("empty bucket: %+v", result)
+	}
+	//每秒补充2个令牌
+	if result = l.take("k", start.Add(500*time.Millisecond)); !result.Allowed || result.Remaining != 0 {
+		t.Fatalf("after refill: %+v", result)
+	}
+	if result = l.take("k", start.Add(500*time.Millisecond)); result.Allowed {
+		t.Fatalf("bucket should be empty again: %+v", result)
+	}
+	//补充的令牌不会超过容量
+	if result = l.take("k", start.Add(time.Hour)); !result.Allowed || result.Remaining != 3 {
+		t.Fatalf("after idle: %+v", result)
+	}
+	//不同的key互不影响
+	if result = l.take("other", start); !result.Allowed || result.Remaining != 3 {
+		t.Fatalf("other key: %+v", result)
+	}
+}
+
+func TestSlidingWindow(t *testing.T) {
+	l := newTestLimiter(RateLimitOptions{Algorithm: SlidingWindow, Limit: 4, Period: time.Minute})
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	for i := 3; i >= 0; i-- {
+		if result := l.take("k", start.Add(10*time.Second)); !result.Allowed || result.Remaining != i {
+			t.Fatalf("request %d: %+v", 4-i, result)
+		}
+	}
+	result := l.take("k", start.Add(20*time.Second))
+	if result.Allowed || result.RetryAfter != 40*time.Second || result.Reset != 40*time.Second {
+		t.Fatalf("full window: %+v", result)
+	}
+
+	//下一个窗口过了一半时，上一个窗口的4个请求按一半计算，还可以再放行2个
+	half := start.Add(90 * time.Second)
+	for i := 1; i >= 0; i-- {
+		if result := l.take("k", half); !result.Allowed || result.Remaining != i {
+			t.Fatalf("weighted request: %+v", result)
+		}
+	}
+	result = l.take("k", half)
+	//上一个窗口的权重降到1/4时才能再放行一个请求
+	if result.Allowed || result.RetryAfter != 15*time.Second {
+		t.Fatalf("weighted window: %+v", result)
+	}
+
+	//隔了一个以上的窗口，之前的请求都不再计算
+	if result := l.take("k", start.Add(5*time.Minute)); !result.Allowed || result.Remaining != 3 {
+		t.Fatalf("after idle: %+v", result)
+	}
+}
+
+func TestRateLimitMiddleware(t *testing.T) {
+	engine := New()
+	api := engine.Group("/api")
+	api.Use(RateLimit(RateLimitOptions{Limit: 1, Period: time.Hour, KeyFunc: KeyByRoute}))
+	api.GET("/goods/:id", func(c *Context) {
+		c.String(http.StatusOK, "ok")
+	})
+
+	w := performRequest(engine, http.MethodGet, "/api/goods/1", nil)
+	if w.Code != http.StatusOK || w.Header().Get("RateLimit-Limit") != "1" || w.Header().Get("RateLimit-Remaining") != "0" {
+		t.Fatalf("status = %d, header = %v", w.Code, w.Header())
+	}
+	//按路由规则限流，/api/goods/2和/api/goods/1共享配额
+	w = performRequest(engine, http.MethodGet, "/api/goods/2", nil)
+	if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") != "3600" {
+		t.Fatalf("status = %d, header = %v", w.Code, w.Header())
+	}
+}
+
+func TestKeyByUserFallsBackToClientIP(t *testing.T) {
+	engine := New()
+	engine.Use(func(c *Context) {
+		if user := c.Req.Header.Get("X-User"); user != "" {
+			c.Set(AuthUserKey, user)
+		}
+	}, RateLimit(RateLimitOptions{Limit: 1, Period: time.Hour, KeyFunc: KeyByUser}))
+	engine.GET("/goods", func(c *Context) {
+		c.String(http.StatusOK, "ok")
+	})
+
+	//未认证的请求按照客户端IP限流，而不是不做限制
+	if w := performRequest(engine, http.MethodGet, "/goods", nil); w.Code != http.StatusOK {
+		t.Fatalf("first anonymous request: status = %d", w.Code)
+	}
+	if w := performRequest(engine, http.MethodGet, "/goods", nil); w.Code != http.StatusTooManyRequests {
+		t.Fatalf("second anonymous request: status = %d, want 429", w.Code)
+	}
+	//认证的用户有自己的配额
+	if w := performRequest(engine, http.MethodGet, "/goods", nil, "X-User", "alice"); w.Code != http.StatusOK {
+		t.Fatalf("authenticated request: status = %d", w.Code)
+	}
+}
+
+func TestMemoryRateLimitStoreJanitor(t *testing.T) {
+	s := NewMemoryRateLimitStore(time.Hour)
+	defer s.Close()
+	if s.running {
+		t.Fatalf("janitor started before the store was used")
+	}
+	s.Take("k", time.Second, func(state *RateLimitState) {})
+	s.mu.Lock()
+	running := s.running
+	s.mu.Unlock()
+	if !running {
+		t.Fatalf("janitor was not started by Take")
+	}
+	if s.cleanup(time.Now()) || s.Len() != 1 {
+		t.Fatalf("live key was removed")
+	}
+	//所有key都过期之后清理协程退出
+	if !s.cleanup(time.Now().Add(time.Minute)) || s.Len() != 0 {
+		t.Fatalf("expired key was kept")
+	}
+	s.mu.Lock()
+	running = s.running
+	s.mu.Unlock()
+	if running {
+		t.Fatalf("janitor should stop when the store is empty")
+	}
+}
@@ -5,9 +5,13 @@ import (
 	"fmt"
 	"github.com/Psychopath-H/psyweb-master/psygo/psyerror"
 	"log"
+	"net"
 	"net/http"
+	"net/http/httputil"
+	"os"
 	"runtime"
 	"strings"
+	"syscall"
 )
 
 // 在 trace() 中，调用了 runtime.Callers(3, pcs[:])，Callers 用来返回调用栈的程序计数器,
@@ -27,29 +31,141 @@ func trace(message string) string {
 	return str.String()
 }
 
+// RecoveryFunc 处理panic的函数，err是recover()拿到的任意值
+type RecoveryFunc func(c *Context, err any)
+
+// RecoveryConfig Recovery中间件的配置
+type RecoveryConfig struct {
+	// Handler 向客户端返回响应的函数，默认返回500
+	Handler RecoveryFunc
+	// StackOnlyInDebug 为true时只在DebugMode下打印调用栈，其余模式只打印panic的值和请求
+	StackOnlyInDebug bool
+}
+
+// redactedHeaders 打印请求时需要隐藏的头部
+var redactedHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie"}
+
 // Recovery 的实现非常简单，使用 defer 挂载上错误恢复的函数，在这个函数中调用 *recover()*，
 // 捕获 panic，并且将堆栈信息打印在日志中，向用户返回 Internal Server Error。
 func Recovery() HandlerFunc {
+	return RecoveryWithConfig(RecoveryConfig{})
+}
+
+// CustomRecovery 和Recovery相同，但是由handle决定返回给客户端的响应
+func CustomRecovery(handle RecoveryFunc) HandlerFunc {
+	return RecoveryWithConfig(RecoveryConfig{Handler: handle})
+}
+
+// RecoveryWithConfig 根据配置返回一个Recovery中间件，可以处理任意类型的panic值：
+// 设置了ErrFunc的PsyError交给ErrFunc处理；带有状态码或错误码的PsyError渲染成 application/problem+json；客户端断开连接(broken pipe、connection reset)时只记录日志不再写响应；
+// 其余情况打印隐藏了敏感头部的请求和调用栈，再交给Handler处理
+func RecoveryWithConfig(config RecoveryConfig) HandlerFunc {
+	handle := config.Handler
+	if handle == nil {
+		handle = defaultHandleRecovery
+	}
 	return func(c *Context) {
 		defer func() {
-			if err := recover(); err != nil {
-				errAssert := err.(error)
-				if errAssert != nil {
-					var psyError *psyerror.PsyError
-					if errors.As(errAssert, &psyError) {
-						psyError.ExecResult()
-						c.Abort()
-						return
-					}
+			err := recover()
+			if err == nil {
+				return
+			}
+			//http.ErrAbortHandler是主动中断连接的信号，交还给net/http处理
+			if e, ok := err.(error); ok && errors.Is(e, http.ErrAbortHandler) {
+				panic(err)
+			}
+			var psyError *psyerror.PsyError
+			if e, ok := err.(error); ok && errors.As(e, &psyError) && psyError.ErrFunc != nil {
+				psyError.ExecResult()
+				c.Abort()
+				return
+			}
+			if e, ok := err.(error); ok && isProblemError(e) {
+				//带有状态码或错误码的应用错误是预期之内的，直接渲染成问题详情文档，不打印调用栈
+				_ = c.Error(e)
+				if !c.Written() {
+					c.Problem(e)
 				}
-				message := fmt.Sprintf("%s", err)
-				c.Engine.Logger.Error(trace(message))
-				log.Printf("%s\n\n", trace(message))
-				c.Fail(http.StatusInternalServerError, "internal Server Error")
 				c.Abort()
+				return
 			}
+
+			brokenPipe := isBrokenPipe(err)
+			message := fmt.Sprintf("[Recovery] panic recovered: %v\n%s", err, dumpRequest(c.Req))
+			if !brokenPipe && (!config.StackOnlyInDebug || IsDebugging()) {
+				message = trace(message)
+			}
+			if c.Engine.Logger != nil {
+				_ = c.Engine.Logger.Error(message)
+			}
+			log.Printf("%s\n\n", message)
+
+			if e, ok := err.(error); ok {
+				_ = c.Error(e)
+			} else {
+				_ = c.Error(fmt.Errorf("%v", err))
+			}
+			if brokenPipe {
+				//连接已经断开，再写响应也没有意义
+				c.Abort()
+				return
+			}
+			handle(c, err)
+			c.Abort()
 		}()
 		c.Next()
 	}
+}
+
+// defaultHandleRecovery 默认返回500，响应已经写出时不再重复写
+func defaultHandleRecovery(c *Context, _ any) {
+	if c.Written() {
+		return
+	}
+	c.Fail(http.StatusInternalServerError, "internal Server Error")
+}
+
+// isBrokenPipe 判断panic是否是客户端断开连接导致的写失败
+func isBrokenPipe(err any) bool {
+	e, ok := err.(error)
+	if !ok {
+		return false
+	}
+	if errors.Is(e, syscall.EPIPE) || errors.Is(e, syscall.ECONNRESET) {
+		return true
+	}
+	var opErr *net.OpError
+	if errors.As(e, &opErr) {
+		var syscallErr *os.SyscallError
+		if errors.As(opErr, &syscallErr) {
+			msg := strings.ToLower(syscallErr.Error())
+			return strings.Contains(msg, "broken pipe") || strings.Contains(msg, "connection reset by peer")
+		}
+	}
+	return false
+}
 
+// dumpRequest 打印请求的头部，Authorization和Cookie等敏感头部的值会被隐藏
+func dumpRequest(req *http.Request) string {
+	if req == nil {
+		return ""
+	}
+	raw, err := httputil.DumpRequest(req, false)
+	if err != nil {
+		return ""
+	}
+	lines := strings.Split(strings.TrimSpace(string(raw)), "\r\n")
+	for i, line := range lines {
+		name, _, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		for _, header := range redactedHeaders {
+			if strings.EqualFold(strings.TrimSpace(name), header) {
+				lines[i] = name + ": *"
+				break
+			}
+		}
+	}
+	return strings.Join(lines, "\r\n")
 }
@@ -0,0 +1,203 @@
+package psygo
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"syscall"
+	"testing"
+
+	"github.com/Psychopath-H/psyweb-master/psygo/psyerror"
+)
+
+// captureLog 把标准库log的输出重定向到缓冲区，测试结束后恢复
+func captureLog(t *testing.T) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+	return &buf
+}
+
+func TestRecovery(t *testing.T) {
+	errGoodsNotFound := psyerror.New(http.StatusNotFound, "goods.not_found", "goods not found")
+	brokenPipe := &net.OpError{Op: "write", Net: "tcp", Err: os.NewSyscallError("write", syscall.EPIPE)}
+
+	tests := []struct {
+		name        string
+		value       any
+		status      int
+		contentType string
+		body        string
+		logged      bool
+	}{
+		{"string", "boom", http.StatusInternalServerError, "application/json", "internal Server Error", true},
+		{"int", 42, http.StatusInternalServerError, "application/json", "internal Server Error", true},
+		{"error", errors.New("db down"), http.StatusInternalServerError, "application/json", "internal Server Error", true},
+		{"problem error", errGoodsNotFound.WithDetail("goods 42 does not exist"), http.StatusNotFound, "application/problem+json", "goods 42 does not exist", false},
+		{"plain psyerror", psyerror.Default().Wrap(errors.New("collected")), http.StatusInternalServerError, "application/json", "internal Server Error", true},
+		{"broken pipe", brokenPipe, http.StatusOK, "", "", true},
+		{"connection reset", fmt.Errorf("write response: %w", syscall.ECONNRESET), http.StatusOK, "", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logs := captureLog(t)
+			engine := New()
+			engine.Logger = nil
+			engine.Use(Recovery())
+			var after bool
+			engine.Use(func(c *Context) {
+				c.Next()
+				after = true
+			})
+			engine.GET("/panic", func(c *Context) {
+				panic(tt.value)
+			})
+
+			w := performRequest(engine, http.MethodGet, "/panic", nil)
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d", w.Code, tt.status)
+			}
+			if got := w.Header().Get("Content-Type"); !strings.HasPrefix(got, tt.contentType) || (tt.contentType == "") != (got == "") {
+				t.Fatalf("content type = %q, want %q", got, tt.contentType)
+			}
+			if tt.body == "" && w.Body.Len() != 0 {
+				t.Fatalf("body = %q, want nothing written", w.Body.String())
+			}
+			if !strings.Contains(w.Body.String(), tt.body) {
+				t.Fatalf("body = %q, want %q", w.Body.String(), tt.body)
+			}
+			if after {
+				t.Fatal("middleware after the panic should not resume")
+			}
+			if got := strings.Contains(logs.String(), "[Recovery] panic recovered"); got != tt.logged {
+				t.Fatalf("logged = %v, want %v: %s", got, tt.logged, logs.String())
+			}
+		})
+	}
+}
+
+func TestRecoveryAfterWrite(t *testing.T) {
+	captureLog(t)
+	engine := New()
+	engine.Logger = nil
+	engine.Use(Recovery())
+	engine.GET("/partial", func(c *Context) {
+		c.String(http.StatusAccepted, "partial")
+		panic("boom")
+	})
+
+	w := performRequest(engine, http.MethodGet, "/partial", nil)
+	if w.Code != http.StatusAccepted || w.Body.String() != "partial" {
+		t.Fatalf("got %d %q, want the response written before the panic", w.Code, w.Body.String())
+	}
+}
+
+func TestRecoveryErrFunc(t *testing.T) {
+	captureLog(t)
+	engine := New()
+	engine.Logger = nil
+	engine.Use(Recovery())
+	engine.GET("/collect", func(c *Context) {
+		psyError := psyerror.Default()
+		psyError.Result(func(e *psyerror.PsyError) {
+			c.String(http.StatusConflict, "handled: "+e.Error())
+		})
+		psyError.Put(errors.New("stock changed"))
+	})
+
+	w := performRequest(engine, http.MethodGet, "/collect", nil)
+	if w.Code != http.StatusConflict || w.Body.String() != "handled: stock changed" {
+		t.Fatalf("got %d %q, want the ErrFunc response", w.Code, w.Body.String())
+	}
+}
+
+func TestCustomRecovery(t *testing.T) {
+	captureLog(t)
+	engine := New()
+	engine.Logger = nil
+	var recovered any
+	engine.Use(CustomRecovery(func(c *Context, err any) {
+		recovered = err
+		c.String(http.StatusServiceUnavailable, "try again later")
+	}))
+	engine.GET("/panic", func(c *Context) {
+		panic(42)
+	})
+
+	w := performRequest(engine, http.MethodGet, "/panic", nil)
+	if w.Code != http.StatusServiceUnavailable || w.Body.String() != "try again later" {
+		t.Fatalf("got %d %q, want the custom response", w.Code, w.Body.String())
+	}
+	if recovered != 42 {
+		t.Fatalf("handler got %v, want the panic value 42", recovered)
+	}
+}
+
+func TestRecoveryAbortHandler(t *testing.T) {
+	engine := New()
+	engine.Logger = nil
+	engine.Use(Recovery())
+	engine.GET("/abort", func(c *Context) {
+		panic(http.ErrAbortHandler)
+	})
+
+	defer func() {
+		if err := recover(); err != http.ErrAbortHandler {
+			t.Fatalf("recovered %v, want http.ErrAbortHandler to be re-panicked", err)
+		}
+	}()
+	performRequest(engine, http.MethodGet, "/abort", nil)
+	t.Fatal("expected http.ErrAbortHandler to reach the server")
+}
+
+func TestRecoveryRedactsHeaders(t *testing.T) {
+	logs := captureLog(t)
+	engine := New()
+	engine.Logger = nil
+	engine.Use(Recovery())
+	engine.GET("/panic", func(c *Context) {
+		panic("boom")
+	})
+
+	performRequest(engine, http.MethodGet, "/panic", nil,
+		"Authorization", "Bearer secret-token",
+		"Cookie", "session=secret-session",
+		"X-Request-Id", "req-1")
+	out := logs.String()
+	if strings.Contains(out, "secret-token") || strings.Contains(out, "secret-session") {
+		t.Fatalf("log leaks credentials: %s", out)
+	}
+	if !strings.Contains(out, "X-Request-Id: req-1") {
+		t.Fatalf("log lost the other headers: %s", out)
+	}
+}
+
+func TestDumpRequest(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "/orders?id=1", nil)
+	req.Header.Set("Authorization", "Bearer secret-token")
+	req.Header.Set("Proxy-Authorization", "Basic secret-proxy")
+	req.Header.Add("Cookie", "session=secret-session")
+	req.Header.Set("Accept", "application/json")
+
+	dump := dumpRequest(req)
+	for _, secret := range []string{"secret-token", "secret-proxy", "secret-session"} {
+		if strings.Contains(dump, secret) {
+			t.Fatalf("dump leaks %q: %s", secret, dump)
+		}
+	}
+	for _, want := range []string{"POST /orders?id=1", "Authorization: *", "Proxy-Authorization: *", "Cookie: *", "Accept: application/json"} {
+		if !strings.Contains(dump, want) {
+			t.Fatalf("dump = %q, want %q", dump, want)
+		}
+	}
+	if dumpRequest(nil) != "" {
+		t.Fatal("dumpRequest(nil) should be empty")
+	}
+}
@@ -0,0 +1,57 @@
+package render
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// Problem 是 RFC 7807 定义的问题详情(problem details)文档，以 application/problem+json 返回
+type Problem struct {
+	Type       string         //问题类型的URI，默认为 about:blank
+	Title      string         //问题类型的简短描述
+	Status     int            //HTTP状态码
+	Detail     string         //本次问题的具体说明
+	Instance   string         //发生问题的资源URI
+	Extensions map[string]any //扩展字段，和标准字段平级输出，不能覆盖标准字段
+}
+
+func (p *Problem) RenderData(w http.ResponseWriter, code int) error {
+	p.WriteContentType(w)
+	w.WriteHeader(code)
+	jsonData, err := json.Marshal(p)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(jsonData)
+	return err
+}
+
+func (p *Problem) WriteContentType(w http.ResponseWriter) {
+	writeContentType(w, "application/problem+json; charset=utf-8")
+}
+
+// MarshalJSON 把扩展字段和标准字段放在同一层
+func (p *Problem) MarshalJSON() ([]byte, error) {
+	data := make(map[string]any, len(p.Extensions)+5)
+	for k, v := range p.Extensions {
+		data[k] = v
+	}
+	problemType := p.Type
+	if problemType == "" {
+		problemType = "about:blank"
+	}
+	data["type"] = problemType
+	if p.Title != "" {
+		data["title"] = p.Title
+	}
+	if p.Status != 0 {
+		data["status"] = p.Status
+	}
+	if p.Detail != "" {
+		data["detail"] = p.Detail
+	}
+	if p.Instance != "" {
+		data["instance"] = p.Instance
+	}
+	return json.Marshal(data)
+}
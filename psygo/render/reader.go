@@ -0,0 +1,39 @@
+package render
+
+import (
+	"io"
+	"net/http"
+	"strconv"
+)
+
+// Reader 把Reader中的内容流式写入响应，不需要先读到内存中
+type Reader struct {
+	ContentType   string
+	ContentLength int64 //内容的字节数，<0表示未知，这时使用分块传输，否则最多写出这么多字节
+	Reader        io.Reader
+	Headers       map[string]string //额外的响应头部，例如Content-Disposition
+}
+
+// RenderData (Reader) writes headers and copies the reader into the response.
+func (r Reader) RenderData(w http.ResponseWriter, code int) (err error) {
+	r.WriteContentType(w)
+	header := w.Header()
+	if r.ContentLength >= 0 {
+		header.Set("Content-Length", strconv.FormatInt(r.ContentLength, 10))
+	}
+	for k, v := range r.Headers {
+		header.Set(k, v)
+	}
+	w.WriteHeader(code)
+	if r.ContentLength >= 0 {
+		_, err = io.CopyN(w, r.Reader, r.ContentLength)
+		return
+	}
+	_, err = io.Copy(w, r.Reader)
+	return
+}
+
+// WriteContentType (Reader) writes custom ContentType.
+func (r Reader) WriteContentType(w http.ResponseWriter) {
+	writeContentType(w, r.ContentType)
+}
@@ -0,0 +1,55 @@
+package psygo
+
+import (
+	psyLog "github.com/Psychopath-H/psyweb-master/psygo/logger"
+	"sync"
+)
+
+var (
+	fallbackLoggerOnce sync.Once
+	fallbackLogger     *psyLog.Logger
+)
+
+// Logger 返回一个属于本次请求的子Logger，它预先带有request_id、method、route、client_ip和user字段，
+// 例如 c.Logger().Info("order created") 输出的每一行日志都可以和访问日志关联起来。
+// 子Logger由Engine.Logger派生，不会修改Engine.Logger，多个请求并发使用也不会互相覆盖字段；
+// Engine.Logger为空时使用psyLog.Default()。每次调用都会按照当前的请求状态重新生成，
+// 因此在设置了请求ID或者完成认证之后调用可以拿到最新的字段
+func (c *Context) Logger() *psyLog.Logger {
+	base := c.Engine.Logger
+	if base == nil {
+		fallbackLoggerOnce.Do(func() {
+			fallbackLogger = psyLog.Default()
+		})
+		base = fallbackLogger
+	}
+	fields := psyLog.Fields{
+		"method":    c.Method,
+		"route":     c.FullPath(),
+		"client_ip": c.ClientIP(),
+	}
+	if requestID := c.requestID(); requestID != "" {
+		fields["request_id"] = requestID
+	}
+	if user := c.authUser(); user != "" {
+		fields["user"] = user
+	}
+	return base.WithFields(fields)
+}
+
+// requestID 返回本次请求的ID，优先从请求头中读取，其次是中间件写入的响应头
+func (c *Context) requestID() string {
+	if requestID := c.requestHeader(RequestIDHeader); requestID != "" {
+		return requestID
+	}
+	return c.Writer.Header().Get(RequestIDHeader)
+}
+
+// authUser 返回BasicAuth认证的用户，没有认证时返回空字符串
+func (c *Context) authUser() string {
+	if user, ok := c.Get(AuthUserKey); ok {
+		name, _ := user.(string)
+		return name
+	}
+	return ""
+}
@@ -0,0 +1,125 @@
+package psygo
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+
+	psyLog "github.com/Psychopath-H/psyweb-master/psygo/logger"
+)
+
+// newJSONLogger 返回一个把JSON格式的日志写入buf的Logger
+func newJSONLogger(buf *bytes.Buffer) *psyLog.Logger {
+	l := psyLog.New()
+	l.Formatter = &psyLog.JsonFormatter{}
+	_ = l.SetLogWriter(-1, buf)
+	return l
+}
+
+// logLines 把buf中的每一行日志解析成map
+func logLines(t *testing.T, buf *bytes.Buffer) []map[string]any {
+	t.Helper()
+	var lines []map[string]any
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var entry map[string]any
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatalf("log line %q: %v", line, err)
+		}
+		lines = append(lines, entry)
+	}
+	return lines
+}
+
+func TestContextLoggerFields(t *testing.T) {
+	var buf bytes.Buffer
+	engine := New()
+	engine.Logger = newJSONLogger(&buf)
+	engine.Use(BasicAuth(Accounts{"alice": "secret"}))
+	engine.GET("/goods/:id", func(c *Context) {
+		_ = c.Logger().Info("loading goods")
+		c.Header(RequestIDHeader, "generated-id") //请求头中没有时使用中间件写入的响应头
+		_ = c.Logger().Info("loaded goods")
+	})
+
+	performRequest(engine, http.MethodGet, "/goods/7", nil, "Authorization", "Basic YWxpY2U6c2VjcmV0")
+	performRequest(engine, http.MethodGet, "/goods/8", nil, "Authorization", "Basic YWxpY2U6c2VjcmV0", RequestIDHeader, "req-1")
+	lines := logLines(t, &buf)
+	if len(lines) != 4 {
+		t.Fatalf("lines = %v", lines)
+	}
+	for _, entry := range lines {
+		if entry["method"] != http.MethodGet || entry["route"] != "/goods/:id" || entry["client_ip"] != "192.0.2.1" || entry["user"] != "alice" {
+			t.Fatalf("entry = %v", entry)
+		}
+	}
+	if _, ok := lines[0]["request_id"]; ok {
+		t.Fatalf("request id before it was set: %v", lines[0])
+	}
+	if lines[1]["request_id"] != "generated-id" || lines[2]["request_id"] != "req-1" || lines[3]["request_id"] != "req-1" {
+		t.Fatalf("request ids = %v, %v, %v", lines[1]["request_id"], lines[2]["request_id"], lines[3]["request_id"])
+	}
+}
+
+func TestContextLoggerChildrenAreIndependent(t *testing.T) {
+	var buf bytes.Buffer
+	engine := New()
+	engine.Logger = newJSONLogger(&buf)
+	engine.Logger.LoggerFields = psyLog.Fields{"service": "goods"}
+	engine.GET("/goods", func(c *Context) {
+		l := c.Logger()
+		order := l.WithField("order", 1)
+		stock := l.WithFields(psyLog.Fields{"stock": 2})
+		_ = order.Info("order")
+		_ = stock.Info("stock")
+		_ = l.Info("request")
+	})
+
+	performRequest(engine, http.MethodGet, "/goods", nil)
+	lines := logLines(t, &buf)
+	if len(lines) != 3 {
+		t.Fatalf("lines = %v", lines)
+	}
+	order, stock, request := lines[0], lines[1], lines[2]
+	if order["order"] != float64(1) || order["stock"] != nil {
+		t.Fatalf("order logger = %v", order)
+	}
+	if stock["stock"] != float64(2) || stock["order"] != nil {
+		t.Fatalf("stock logger = %v", stock)
+	}
+	if request["order"] != nil || request["stock"] != nil || request["service"] != "goods" {
+		t.Fatalf("request logger = %v", request)
+	}
+	//请求的字段不会写回Engine.Logger
+	if len(engine.Logger.LoggerFields) != 1 {
+		t.Fatalf("engine logger fields = %v", engine.Logger.LoggerFields)
+	}
+}
+
+func TestContextLoggerConcurrentRequests(t *testing.T) {
+	var buf bytes.Buffer
+	engine := New()
+	engine.Logger = newJSONLogger(&buf)
+	engine.GET("/goods/:id", func(c *Context) {
+		_ = c.Logger().WithField("id", c.Param("id")).Info("goods")
+	})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			id := string(rune('a' + i))
+			performRequest(engine, http.MethodGet, "/goods/"+id, nil, RequestIDHeader, "req-"+id)
+		}(i)
+	}
+	wg.Wait()
+	//每一行日志的请求ID都和它自己的请求对应，字段不会串到别的请求里
+	for _, entry := range logLines(t, &buf) {
+		if entry["request_id"] != "req-"+entry["id"].(string) {
+			t.Fatalf("entry = %v", entry)
+		}
+	}
+}
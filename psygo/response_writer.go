@@ -0,0 +1,138 @@
+package psygo
+
+import (
+	"bufio"
+	"errors"
+	"net"
+	"net/http"
+)
+
+// responseWriter 包装了http.ResponseWriter，记录实际写出的状态码和响应体大小，
+// 它作为Context的一部分随Context一起放回池子，不会额外分配内存。
+// WriteHeader只记录状态码，直到第一次Write、Flush或者处理链结束时才真正发出头部，
+// 这样之后的中间件(例如ErrorHandler)仍然可以修改头部和状态码
+type responseWriter struct {
+	http.ResponseWriter
+	status  int
+	size    int
+	written bool //头部是否已经发出
+}
+
+func (w *responseWriter) reset(writer http.ResponseWriter) {
+	w.ResponseWriter = writer
+	w.status = 0
+	w.size = 0
+	w.written = false
+}
+
+// WriteHeader 记录状态码，头部发出之前可以被之后的调用覆盖，发出之后的调用直接忽略，
+// 避免net/http打印superfluous WriteHeader的警告
+func (w *responseWriter) WriteHeader(code int) {
+	if w.written {
+		return
+	}
+	w.status = code
+}
+
+// WriteHeaderNow 立即发出头部，还没有设置状态码时使用200
+func (w *responseWriter) WriteHeaderNow() {
+	if w.written {
+		return
+	}
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	w.written = true
+	w.ResponseWriter.WriteHeader(w.status)
+}
+
+// finish 处理链结束后调用，只设置了状态码、没有写响应体时在这里发出头部
+func (w *responseWriter) finish() {
+	if w.status != 0 {
+		w.WriteHeaderNow()
+	}
+}
+
+func (w *responseWriter) Write(b []byte) (int, error) {
+	w.WriteHeaderNow()
+	n, err := w.ResponseWriter.Write(b)
+	w.size += n
+	return n, err
+}
+
+// Flush 实现了http.Flusher
+func (w *responseWriter) Flush() {
+	w.WriteHeaderNow()
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack 实现了http.Hijacker，websocket等协议升级时需要，接管连接之后不再发出头部
+func (w *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("psygo: the ResponseWriter doesn't support hijacking")
+	}
+	w.written = true
+	return h.Hijack()
+}
+
+// Unwrap 供http.ResponseController找到原始的ResponseWriter
+func (w *responseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+func (w *responseWriter) Status() int {
+	return w.status
+}
+
+func (w *responseWriter) Size() int {
+	return w.size
+}
+
+func (w *responseWriter) Written() bool {
+	return w.written
+}
+
+// responseState 由记录响应状态的Writer实现。中间件(例如Timeout和ETag)把c.Writer换成自己的Writer时，
+// 处理函数写出的数据不会马上到达c.writermem，Written等方法需要向当前的c.Writer查询
+type responseState interface {
+	Status() int   //已经设置的状态码，还没有设置时为0
+	Size() int     //已经写出的响应体字节数
+	Written() bool //是否已经写出了头部或者响应体
+}
+
+// responseState 沿着Unwrap找到最外层记录了响应状态的Writer
+func (c *Context) responseState() responseState {
+	w := c.Writer
+	for w != nil {
+		if state, ok := w.(responseState); ok {
+			return state
+		}
+		u, ok := w.(interface{ Unwrap() http.ResponseWriter })
+		if !ok {
+			break
+		}
+		w = u.Unwrap()
+	}
+	return &c.writermem
+}
+
+// Written 判断响应头部或者响应体是否已经写出，只设置了状态码还没有写出时为false
+func (c *Context) Written() bool {
+	return c.responseState().Written()
+}
+
+// ResponseStatus 返回设置的状态码，还没有设置时返回c.StatusCode
+func (c *Context) ResponseStatus() int {
+	if status := c.responseState().Status(); status != 0 {
+		return status
+	}
+	return c.StatusCode
+}
+
+// ResponseSize 返回已经写出的响应体字节数
+func (c *Context) ResponseSize() int {
+	return c.responseState().Size()
+}
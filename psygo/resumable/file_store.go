@@ -0,0 +1,145 @@
+package resumable
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// FileStore 把每个上传保存为dir下的两个文件：<id>.bin是已经上传的内容，<id>.info是上传的信息，适合单机部署
+type FileStore struct {
+	dir string
+}
+
+// NewFileStore 新建一个本地磁盘存储，dir不存在时会自动创建
+func NewFileStore(dir string) (*FileStore, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+	return &FileStore{dir: dir}, nil
+}
+
+// Path 返回上传内容所在的文件，完成回调中可以把它移动到最终的位置
+func (s *FileStore) Path(id string) string {
+	return filepath.Join(s.dir, id+".bin")
+}
+
+func (s *FileStore) infoPath(id string) string {
+	return filepath.Join(s.dir, id+".info")
+}
+
+// Create 实现了Store接口
+func (s *FileStore) Create(info *Info) error {
+	if !validID(info.ID) {
+		return ErrNotFound
+	}
+	data, err := json.Marshal(info)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(s.Path(info.ID), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	_ = f.Close()
+	//先写临时文件再重命名，Info读到的总是完整的信息
+	tmp := s.infoPath(info.ID) + ".tmp"
+	if err = os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.infoPath(info.ID))
+}
+
+// Info 实现了Store接口，Offset和UpdatedAt取自内容文件的大小和修改时间
+func (s *FileStore) Info(id string) (*Info, error) {
+	if !validID(id) {
+		return nil, ErrNotFound
+	}
+	data, err := os.ReadFile(s.infoPath(id))
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	info := &Info{}
+	if err = json.Unmarshal(data, info); err != nil {
+		return nil, err
+	}
+	fi, err := os.Stat(s.Path(id))
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	info.Offset = fi.Size()
+	info.UpdatedAt = fi.ModTime()
+	return info, nil
+}
+
+// WriteChunk 实现了Store接口
+func (s *FileStore) WriteChunk(id string, offset int64, r io.Reader) (int64, error) {
+	if !validID(id) {
+		return 0, ErrNotFound
+	}
+	f, err := os.OpenFile(s.Path(id), os.O_WRONLY, 0)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return 0, ErrNotFound
+		}
+		return 0, err
+	}
+	defer f.Close()
+	size, err := f.Seek(0, io.SeekEnd)
+	if err != nil {
+		return 0, err
+	}
+	if size != offset {
+		return size, ErrOffsetMismatch
+	}
+	n, err := io.Copy(f, r)
+	return offset + n, err
+}
+
+// Truncate 实现了Store接口
+func (s *FileStore) Truncate(id string, offset int64) error {
+	if !validID(id) {
+		return ErrNotFound
+	}
+	return os.Truncate(s.Path(id), offset)
+}
+
+// Delete 实现了Store接口
+func (s *FileStore) Delete(id string) error {
+	if !validID(id) {
+		return ErrNotFound
+	}
+	err := os.Remove(s.infoPath(id))
+	if errors.Is(err, fs.ErrNotExist) {
+		err = ErrNotFound
+	}
+	if e := os.Remove(s.Path(id)); e != nil && !errors.Is(e, fs.ErrNotExist) {
+		return e
+	}
+	return err
+}
+
+// List 实现了Store接口
+func (s *FileStore) List() ([]string, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, err
+	}
+	var ids []string
+	for _, entry := range entries {
+		if id, ok := strings.CutSuffix(entry.Name(), ".info"); ok && validID(id) {
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
@@ -0,0 +1,66 @@
+package resumable
+
+import (
+	"github.com/Psychopath-H/psyweb-master/psygo"
+	"time"
+)
+
+// Option 用于设置断点续传的可选项
+type Option func(opts *Options)
+
+func loadOptions(options ...Option) *Options {
+	opts := &Options{
+		Expiration: 24 * time.Hour,
+	}
+	for _, option := range options {
+		option(opts)
+	}
+	return opts
+}
+
+// Options 断点续传的全部配置
+type Options struct {
+	// MaxSize 单个上传的最大字节数，0表示不限制
+	MaxSize int64
+	// Expiration 未完成的上传超过这个时间没有写入就过期，默认24小时，0表示永不过期
+	Expiration time.Duration
+	// CleanupInterval 后台清理过期上传的间隔，0表示不在后台清理，可以自己定期调用Handler.Cleanup
+	CleanupInterval time.Duration
+	// OnComplete 最后一个分块写入之后调用，返回的错误会通过c.Problem响应给客户端
+	OnComplete func(c *psygo.Context, info *Info) error
+}
+
+// WithOptions 一次性设置全部配置
+func WithOptions(options Options) Option {
+	return func(opts *Options) {
+		*opts = options
+	}
+}
+
+// WithMaxSize 设置单个上传的最大字节数
+func WithMaxSize(size int64) Option {
+	return func(opts *Options) {
+		opts.MaxSize = size
+	}
+}
+
+// WithExpiration 设置未完成的上传多久没有写入就过期
+func WithExpiration(expiration time.Duration) Option {
+	return func(opts *Options) {
+		opts.Expiration = expiration
+	}
+}
+
+// WithCleanupInterval 设置后台清理过期上传的间隔
+func WithCleanupInterval(interval time.Duration) Option {
+	return func(opts *Options) {
+		opts.CleanupInterval = interval
+	}
+}
+
+// WithOnComplete 设置上传完成时的回调，例如把文件移动到最终的位置
+func WithOnComplete(fn func(c *psygo.Context, info *Info) error) Option {
+	return func(opts *Options) {
+		opts.OnComplete = fn
+	}
+}
@@ -0,0 +1,390 @@
+// Package resumable 实现了tus 1.0风格的断点续传协议，支持creation、expiration、checksum和termination扩展：
+//
+//	POST   /upload      Upload-Length和Upload-Metadata新建一个上传，Location返回它的地址
+//	HEAD   /upload/:id  Upload-Offset返回已经保存的字节数
+//	PATCH  /upload/:id  从Upload-Offset开始追加一个分块，可以带上Upload-Checksum校验这个分块
+//	DELETE /upload/:id  放弃这个上传
+//
+// 网络中断时已经收到的部分会保留，客户端HEAD得到偏移量之后从那里继续，例如
+//
+//	store, _ := resumable.NewFileStore("./upload/.partial")
+//	resumable.Mount(engine.Group("/upload"), store, resumable.WithOnComplete(func(c *psygo.Context, info *resumable.Info) error {
+//		if err := os.Rename(store.Path(info.ID), filepath.Join("./upload", info.ID)); err != nil {
+//			return err
+//		}
+//		return store.Delete(info.ID)
+//	}))
+package resumable
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"github.com/Psychopath-H/psyweb-master/psygo"
+	"hash"
+	"io"
+	"net/http"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// Version 是实现的协议版本
+	Version = "1.0.0"
+	// Extensions 是支持的协议扩展
+	Extensions = "creation,expiration,checksum,termination"
+	// ContentType 是PATCH请求体必须使用的类型
+	ContentType = "application/offset+octet-stream"
+	// StatusChecksumMismatch 是分块校验失败时的状态码
+	StatusChecksumMismatch = 460
+)
+
+// checksums 是Upload-Checksum支持的算法
+var checksums = map[string]func() hash.Hash{
+	"md5":    md5.New,
+	"sha1":   sha1.New,
+	"sha256": sha256.New,
+}
+
+// Handler 处理断点续传的请求
+type Handler struct {
+	store Store
+	opts  *Options
+
+	mu     sync.Mutex
+	locked map[string]bool //正在写入的上传，同一个上传同时只能有一个PATCH或DELETE
+	done   chan struct{}
+	once   sync.Once
+}
+
+// New 新建一个Handler，通常直接使用Mount
+func New(store Store, options ...Option) *Handler {
+	h := &Handler{
+		store:  store,
+		opts:   loadOptions(options...),
+		locked: make(map[string]bool),
+		done:   make(chan struct{}),
+	}
+	if h.opts.Expiration > 0 && h.opts.CleanupInterval > 0 {
+		go h.janitor(h.opts.CleanupInterval)
+	}
+	return h
+}
+
+// Mount 在group下注册断点续传的全部路由：group本身接收OPTIONS和POST，group下的/:id接收HEAD、PATCH和DELETE
+func Mount(group *psygo.RouterGroup, store Store, options ...Option) *Handler {
+	h := New(store, options...)
+	group.OPTIONS("", h.wrap(h.Options))
+	group.POST("", h.wrap(h.Create))
+	group.HEAD("/:id", h.wrap(h.Head))
+	group.PATCH("/:id", h.wrap(h.Patch))
+	group.DELETE("/:id", h.wrap(h.Delete))
+	return h
+}
+
+// wrap 给所有响应加上Tus-Resumable，并拒绝协议版本不一致的请求(OPTIONS除外)
+func (h *Handler) wrap(handler psygo.HandlerFunc) psygo.HandlerFunc {
+	return func(c *psygo.Context) {
+		c.Header("Tus-Resumable", Version)
+		if c.Method != http.MethodOptions && c.Req.Header.Get("Tus-Resumable") != Version {
+			c.Header("Tus-Version", Version)
+			c.Status(http.StatusPreconditionFailed)
+			return
+		}
+		handler(c)
+	}
+}
+
+// Options 返回服务端支持的协议版本、扩展和限制
+func (h *Handler) Options(c *psygo.Context) {
+	c.Header("Tus-Version", Version)
+	c.Header("Tus-Extension", Extensions)
+	c.Header("Tus-Checksum-Algorithm", "md5,sha1,sha256")
+	if h.opts.MaxSize > 0 {
+		c.Header("Tus-Max-Size", strconv.FormatInt(h.opts.MaxSize, 10))
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// Create 新建一个上传
+func (h *Handler) Create(c *psygo.Context) {
+	size, err := strconv.ParseInt(c.Req.Header.Get("Upload-Length"), 10, 64)
+	if err != nil || size < 0 {
+		c.Status(http.StatusBadRequest)
+		return
+	}
+	if h.opts.MaxSize > 0 && size > h.opts.MaxSize {
+		c.Status(http.StatusRequestEntityTooLarge)
+		return
+	}
+	metadata, ok := parseMetadata(c.Req.Header.Get("Upload-Metadata"))
+	if !ok {
+		c.Status(http.StatusBadRequest)
+		return
+	}
+	now := time.Now()
+	info := &Info{ID: newID(), Size: size, Metadata: metadata, CreatedAt: now, UpdatedAt: now}
+	if err = h.store.Create(info); err != nil {
+		h.fail(c, err)
+		return
+	}
+	c.Header("Location", path.Join(c.Req.URL.Path, info.ID))
+	h.setExpires(c, info)
+	if info.Complete() && h.opts.OnComplete != nil { //空文件在创建时就已经完成
+		if err = h.opts.OnComplete(c, info); err != nil {
+			c.Problem(err)
+			return
+		}
+	}
+	c.Status(http.StatusCreated)
+}
+
+// Head 返回上传已经保存的字节数
+func (h *Handler) Head(c *psygo.Context) {
+	info, ok := h.load(c)
+	if !ok {
+		return
+	}
+	c.Header("Cache-Control", "no-store")
+	c.Header("Upload-Offset", strconv.FormatInt(info.Offset, 10))
+	c.Header("Upload-Length", strconv.FormatInt(info.Size, 10))
+	c.Header("Upload-Metadata", formatMetadata(info.Metadata))
+	h.setExpires(c, info)
+	c.Status(http.StatusOK)
+}
+
+// Patch 从Upload-Offset开始追加一个分块
+func (h *Handler) Patch(c *psygo.Context) {
+	if c.Req.Header.Get("Content-Type") != ContentType {
+		c.Status(http.StatusUnsupportedMediaType)
+		return
+	}
+	offset, err := strconv.ParseInt(c.Req.Header.Get("Upload-Offset"), 10, 64)
+	if err != nil || offset < 0 {
+		c.Status(http.StatusBadRequest)
+		return
+	}
+	var (
+		sum    hash.Hash
+		expect []byte
+	)
+	if header := c.Req.Header.Get("Upload-Checksum"); header != "" {
+		algorithm, encoded, _ := strings.Cut(header, " ")
+		newHash, ok := checksums[algorithm]
+		if expect, err = base64.StdEncoding.DecodeString(encoded); !ok || err != nil {
+			c.Status(http.StatusBadRequest)
+			return
+		}
+		sum = newHash()
+	}
+
+	id := c.Param("id")
+	if !h.lock(id) {
+		c.Status(http.StatusLocked)
+		return
+	}
+	defer h.unlock(id)
+	info, ok := h.load(c)
+	if !ok {
+		return
+	}
+	if offset != info.Offset {
+		c.Status(http.StatusConflict)
+		return
+	}
+
+	//最多多读一个字节，用来发现超出Upload-Length的请求体
+	var body io.Reader = io.LimitReader(c.Req.Body, info.Size-offset+1)
+	if sum != nil {
+		body = io.TeeReader(body, sum)
+	}
+	newOffset, err := h.store.WriteChunk(id, offset, body)
+	switch {
+	case errors.Is(err, ErrOffsetMismatch):
+		c.Status(http.StatusConflict)
+		return
+	case newOffset > info.Size:
+		_ = h.store.Truncate(id, offset)
+		c.Status(http.StatusRequestEntityTooLarge)
+		return
+	case err != nil:
+		if sum != nil { //没有完整收到的分块无法校验，全部丢弃
+			_ = h.store.Truncate(id, offset)
+		}
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			c.Status(http.StatusRequestEntityTooLarge)
+			return
+		}
+		h.fail(c, err)
+		return
+	case sum != nil && !bytes.Equal(sum.Sum(nil), expect):
+		_ = h.store.Truncate(id, offset)
+		c.Status(StatusChecksumMismatch)
+		return
+	}
+
+	info.Offset = newOffset
+	info.UpdatedAt = time.Now()
+	c.Header("Upload-Offset", strconv.FormatInt(newOffset, 10))
+	h.setExpires(c, info)
+	if info.Complete() && h.opts.OnComplete != nil {
+		if err = h.opts.OnComplete(c, info); err != nil {
+			c.Problem(err)
+			return
+		}
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// Delete 放弃一个上传并删除已经保存的内容
+func (h *Handler) Delete(c *psygo.Context) {
+	id := c.Param("id")
+	if !h.lock(id) {
+		c.Status(http.StatusLocked)
+		return
+	}
+	defer h.unlock(id)
+	if err := h.store.Delete(id); err != nil {
+		h.fail(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// load 载入路由参数id对应的上传，不存在或者已经过期时写入响应并返回false
+func (h *Handler) load(c *psygo.Context) (*Info, bool) {
+	id := c.Param("id")
+	info, err := h.store.Info(id)
+	if err != nil {
+		h.fail(c, err)
+		return nil, false
+	}
+	if h.expired(info, time.Now()) {
+		_ = h.store.Delete(id)
+		c.Status(http.StatusGone)
+		return nil, false
+	}
+	return info, true
+}
+
+// fail 把存储返回的错误转换成响应
+func (h *Handler) fail(c *psygo.Context, err error) {
+	if errors.Is(err, ErrNotFound) {
+		c.Status(http.StatusNotFound)
+		return
+	}
+	_ = c.Error(err)
+	c.Status(http.StatusInternalServerError)
+}
+
+// expired 判断一个未完成的上传在now时刻是否已经过期，已经完成的上传不会过期
+func (h *Handler) expired(info *Info, now time.Time) bool {
+	return h.opts.Expiration > 0 && !info.Complete() && now.After(info.UpdatedAt.Add(h.opts.Expiration))
+}
+
+// setExpires 告诉客户端未完成的上传什么时候过期
+func (h *Handler) setExpires(c *psygo.Context, info *Info) {
+	if h.opts.Expiration > 0 && !info.Complete() {
+		c.Header("Upload-Expires", info.UpdatedAt.Add(h.opts.Expiration).UTC().Format(http.TimeFormat))
+	}
+}
+
+func (h *Handler) lock(id string) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.locked[id] {
+		return false
+	}
+	h.locked[id] = true
+	return true
+}
+
+func (h *Handler) unlock(id string) {
+	h.mu.Lock()
+	delete(h.locked, id)
+	h.mu.Unlock()
+}
+
+// Cleanup 删除所有已经过期的未完成上传，返回删除的个数
+func (h *Handler) Cleanup() (int, error) {
+	ids, err := h.store.List()
+	if err != nil {
+		return 0, err
+	}
+	now := time.Now()
+	removed := 0
+	for _, id := range ids {
+		if !h.lock(id) { //正在写入的上传没有过期
+			continue
+		}
+		info, err := h.store.Info(id)
+		if err == nil && h.expired(info, now) && h.store.Delete(id) == nil {
+			removed++
+		}
+		h.unlock(id)
+	}
+	return removed, nil
+}
+
+// Close 停止后台清理
+func (h *Handler) Close() {
+	h.once.Do(func() {
+		close(h.done)
+	})
+}
+
+// janitor 定期清理过期的上传
+func (h *Handler) janitor(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			_, _ = h.Cleanup()
+		case <-h.done:
+			return
+		}
+	}
+}
+
+// parseMetadata 解析Upload-Metadata：以逗号分隔的键值对，键和base64编码的值之间用空格分隔，值可以省略
+func parseMetadata(header string) (map[string]string, bool) {
+	metadata := make(map[string]string)
+	if strings.TrimSpace(header) == "" {
+		return metadata, true
+	}
+	for _, pair := range strings.Split(header, ",") {
+		key, encoded, _ := strings.Cut(strings.TrimSpace(pair), " ")
+		if key == "" {
+			return nil, false
+		}
+		value, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, false
+		}
+		metadata[key] = string(value)
+	}
+	return metadata, true
+}
+
+// formatMetadata 把metadata编码成Upload-Metadata的格式
+func formatMetadata(metadata map[string]string) string {
+	keys := make([]string, 0, len(metadata))
+	for k := range metadata {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	pairs := make([]string, 0, len(keys))
+	for _, k := range keys {
+		pairs = append(pairs, k+" "+base64.StdEncoding.EncodeToString([]byte(metadata[k])))
+	}
+	return strings.Join(pairs, ",")
+}
@@ -0,0 +1,130 @@
+package resumable
+
+import (
+	"crypto/sha1"
+	"encoding/base64"
+	"github.com/Psychopath-H/psyweb-master/psygo"
+	"github.com/Psychopath-H/psyweb-master/psygo/psygotest"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestEngine(t *testing.T, options ...Option) (*psygo.Engine, *FileStore, *Handler) {
+	store, err := NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	engine := psygo.New()
+	h := Mount(engine.Group("/upload"), store, options...)
+	t.Cleanup(h.Close)
+	return engine, store, h
+}
+
+func create(t *testing.T, engine *psygo.Engine, size string) string {
+	resp := psygotest.NewRequest(engine).POST("/upload").
+		Header("Tus-Resumable", Version).
+		Header("Upload-Length", size).
+		Header("Upload-Metadata", "filename "+base64.StdEncoding.EncodeToString([]byte("video.mp4"))+",private").
+		Do(t).
+		AssertStatus(http.StatusCreated).
+		AssertHeader("Tus-Resumable", Version)
+	location := resp.Header().Get("Location")
+	if !strings.HasPrefix(location, "/upload/") {
+		t.Fatalf("location = %q", location)
+	}
+	return location
+}
+
+func patch(engine *psygo.Engine, location string, offset, chunk string) *psygotest.RequestBuilder {
+	return psygotest.NewRequest(engine).Method(http.MethodPatch, location).
+		Header("Tus-Resumable", Version).
+		Header("Upload-Offset", offset).
+		Body(ContentType, []byte(chunk))
+}
+
+func TestResumableUpload(t *testing.T) {
+	var completed *Info
+	engine, store, _ := newTestEngine(t, WithMaxSize(100), WithOnComplete(func(c *psygo.Context, info *Info) error {
+		completed = info
+		return nil
+	}))
+
+	psygotest.NewRequest(engine).Method(http.MethodOptions, "/upload").Do(t).
+		AssertStatus(http.StatusNoContent).
+		AssertHeader("Tus-Version", Version).
+		AssertHeader("Tus-Max-Size", "100")
+	psygotest.NewRequest(engine).POST("/upload").Header("Upload-Length", "11").Do(t).
+		AssertStatus(http.StatusPreconditionFailed)
+	psygotest.NewRequest(engine).POST("/upload").Header("Tus-Resumable", Version).Header("Upload-Length", "101").Do(t).
+		AssertStatus(http.StatusRequestEntityTooLarge)
+
+	location := create(t, engine, "11")
+	patch(engine, location, "0", "hello ").Do(t).
+		AssertStatus(http.StatusNoContent).
+		AssertHeader("Upload-Offset", "6")
+	//客户端以为上一次没有成功，从旧的偏移量重试
+	patch(engine, location, "0", "hello ").Do(t).AssertStatus(http.StatusConflict)
+	psygotest.NewRequest(engine).Method(http.MethodHead, location).Header("Tus-Resumable", Version).Do(t).
+		AssertStatus(http.StatusOK).
+		AssertHeader("Upload-Offset", "6").
+		AssertHeader("Upload-Length", "11").
+		AssertHeader("Upload-Metadata", "filename dmlkZW8ubXA0,private ").
+		AssertHeader("Cache-Control", "no-store")
+	if completed != nil {
+		t.Fatal("upload should not be complete yet")
+	}
+
+	sum := sha1.Sum([]byte("world"))
+	patch(engine, location, "6", "world").Header("Upload-Checksum", "sha1 "+base64.StdEncoding.EncodeToString([]byte("bad"))).Do(t).
+		AssertStatus(StatusChecksumMismatch)
+	patch(engine, location, "6", "world!").Do(t).AssertStatus(http.StatusRequestEntityTooLarge)
+	patch(engine, location, "6", "world").Header("Upload-Checksum", "sha1 "+base64.StdEncoding.EncodeToString(sum[:])).Do(t).
+		AssertStatus(http.StatusNoContent).
+		AssertHeader("Upload-Offset", "11").
+		AssertHeader("Upload-Expires", "")
+	if completed == nil || completed.Metadata["filename"] != "video.mp4" {
+		t.Fatalf("completed = %+v", completed)
+	}
+	if data, _ := os.ReadFile(store.Path(completed.ID)); string(data) != "hello world" {
+		t.Fatalf("content = %q", data)
+	}
+
+	psygotest.NewRequest(engine).DELETE(location).Header("Tus-Resumable", Version).Do(t).AssertStatus(http.StatusNoContent)
+	psygotest.NewRequest(engine).Method(http.MethodHead, location).Header("Tus-Resumable", Version).Do(t).
+		AssertStatus(http.StatusNotFound)
+	psygotest.NewRequest(engine).Method(http.MethodHead, "/upload/../../etc").Header("Tus-Resumable", Version).Do(t).
+		AssertStatus(http.StatusNotFound)
+}
+
+func TestExpiration(t *testing.T) {
+	engine, store, h := newTestEngine(t, WithExpiration(time.Minute))
+	expired := create(t, engine, "10")
+	fresh := create(t, engine, "10")
+	done := create(t, engine, "0")
+	old := time.Now().Add(-2 * time.Minute)
+	for _, location := range []string{expired, done} {
+		id := strings.TrimPrefix(location, "/upload/")
+		if err := os.Chtimes(store.Path(id), old, old); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if removed, err := h.Cleanup(); err != nil || removed != 1 {
+		t.Fatalf("Cleanup() = %d, %v", removed, err)
+	}
+	patch(engine, expired, "0", "x").Do(t).AssertStatus(http.StatusNotFound)
+	patch(engine, fresh, "0", "x").Do(t).AssertStatus(http.StatusNoContent)
+	psygotest.NewRequest(engine).Method(http.MethodHead, done).Header("Tus-Resumable", Version).Do(t).
+		AssertStatus(http.StatusOK)
+
+	//还没有被清理的过期上传在访问时返回410
+	id := strings.TrimPrefix(fresh, "/upload/")
+	if err := os.Chtimes(store.Path(id), old, old); err != nil {
+		t.Fatal(err)
+	}
+	psygotest.NewRequest(engine).Method(http.MethodHead, fresh).Header("Tus-Resumable", Version).Do(t).
+		AssertStatus(http.StatusGone)
+}
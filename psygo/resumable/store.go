@@ -0,0 +1,65 @@
+package resumable
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"io"
+	"time"
+)
+
+// 存储返回的错误
+var (
+	// ErrNotFound 存储中找不到对应的上传
+	ErrNotFound = errors.New("resumable: upload not found")
+	// ErrOffsetMismatch 写入的偏移量和已经保存的字节数不一致
+	ErrOffsetMismatch = errors.New("resumable: offset mismatch")
+)
+
+// Store 保存上传中的文件，实现了这个接口就可以把分块写到对象存储等外部存储中
+type Store interface {
+	// Create 新建一个上传，info.ID已经由调用方生成
+	Create(info *Info) error
+	// Info 返回上传的信息，其中Offset和UpdatedAt以实际保存的内容为准，找不到时返回ErrNotFound
+	Info(id string) (*Info, error)
+	// WriteChunk 从offset开始写入r的内容，返回写入后的偏移量。offset和已经保存的字节数不一致时返回ErrOffsetMismatch；
+	// r中途返回错误时已经写入的部分仍然保留，客户端可以从新的偏移量继续上传
+	WriteChunk(id string, offset int64, r io.Reader) (int64, error)
+	// Truncate 把上传截断到offset，用于丢弃校验失败的分块
+	Truncate(id string, offset int64) error
+	// Delete 删除上传和它已经保存的内容
+	Delete(id string) error
+	// List 返回所有上传的ID，用于清理过期的上传
+	List() ([]string, error)
+}
+
+// Info 是一个上传的信息
+type Info struct {
+	ID        string            `json:"id"`
+	Size      int64             `json:"size"`     //文件的总字节数
+	Offset    int64             `json:"offset"`   //已经保存的字节数
+	Metadata  map[string]string `json:"metadata"` //客户端通过Upload-Metadata提供的信息，例如filename
+	CreatedAt time.Time         `json:"created_at"`
+	UpdatedAt time.Time         `json:"updated_at"` //最后一次写入的时间，过期时间从这里开始计算
+}
+
+// Complete 判断是否已经上传完成
+func (info *Info) Complete() bool {
+	return info.Offset >= info.Size
+}
+
+// newID 生成一个随机的上传ID
+func newID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// validID 判断id是否是newID生成的格式，避免把请求中的任意字符串拼接进文件路径
+func validID(id string) bool {
+	if len(id) != 32 {
+		return false
+	}
+	_, err := hex.DecodeString(id)
+	return err == nil
+}
@@ -1,24 +1,30 @@
 package psygo
 
 import (
+	"net"
 	"net/http"
 	"strings"
 )
 
-// router 路由器，存储了前缀树根节点和处理方法
+// router 路由器，每个请求方法一棵压缩前缀树，处理函数存放在树的节点上
 type router struct {
-	// roots 存储了每个请求方法(GET,POST)的前缀树根节点
-	roots map[string]*node
-	//handlers 存储了每个特定方法特定路由请求的处理函数 例如:handlers['GET-/p/:lang/doc'], handlers['POST-/p/book']
-	handlers map[string]HandlerFunc
+	// trees 存储了每个请求方法(GET,POST)的前缀树根节点，请求方法不多，顺序查找比map更快
+	trees []methodTree
+
+	host      string    //该路由器对应的主机规则，默认路由器为空
+	hostParts []string  //主机规则按.切分后的各段，例如[:tenant example com]
+	hosts     []*router //各个主机自己的路由器，精确的主机排在带通配符的前面
+}
+
+// methodTree 是一个请求方法的前缀树
+type methodTree struct {
+	method string
+	root   *node
 }
 
 // newRouter 新建一个路由器
 func newRouter() *router {
-	return &router{
-		roots:    make(map[string]*node),
-		handlers: make(map[string]HandlerFunc),
-	}
+	return &router{}
 }
 
 // parsePattern 解析一下路由地址，只允许有一个*存在 例子: "/p/:lang/doc" -> [p :lang doc]
@@ -37,56 +43,157 @@ func parsePattern(pattern string) []string {
 	return parts
 }
 
+// root 返回method的前缀树根节点，create为true时不存在就新建一个
+func (r *router) root(method string, create bool) *node {
+	for _, t := range r.trees {
+		if t.method == method {
+			return t.root
+		}
+	}
+	if !create {
+		return nil
+	}
+	root := &node{}
+	r.trees = append(r.trees, methodTree{method: method, root: root})
+	return root
+}
+
 // addRoute 通过前缀树添加路由 method:GET pattern:/p/:lang/doc
+// 规则中多余的/会被忽略，/p//:lang/和/p/:lang是同一条路由，重复注册时后注册的生效
 func (r *router) addRoute(method string, pattern string, handler HandlerFunc) {
-	parts := parsePattern(pattern)
-	key := method + "-" + pattern // 比如 GET-/p/:lang/doc
-	_, ok := r.roots[method]
-	if !ok { //如果该方法没有前缀树(往往在刚开始会这样)，就新建一个
-		r.roots[method] = &node{}
+	path := "/" + strings.Join(parsePattern(pattern), "/")
+	r.root(method, true).insert(path, pattern, handler)
+}
+
+// getRoute 通过前缀树获得路由，参数的值追加到params中，参数的名字在找到路由之后填上。
+// 和注册时一样忽略路径中多余的/，查找常见的请求路径不需要分配内存
+func (r *router) getRoute(method string, path string, params *Params) *node {
+	root := r.root(method, false)
+	if root == nil {
+		return nil
+	}
+	start := len(*params)
+	n := root.search(cleanSlashes(path), params)
+	if n == nil {
+		return nil
 	}
-	r.roots[method].insert(pattern, parts, 0) //构建前缀树路由
-	r.handlers[key] = handler                 //为路由添加方法
+	for i, key := range n.keys {
+		(*params)[start+i].Key = key
+	}
+	return n
 }
 
-// getRoute 通过前缀树获得路由，并得到模糊参数
-func (r *router) getRoute(method string, path string) (*node, map[string]string) {
-	searchParts := parsePattern(path) //"/p/:lang/doc" -> [p :lang doc]
-	params := make(map[string]string)
-	root, ok := r.roots[method]
-	if !ok {
-		return nil, nil
+// cleanSlashes 去掉路径中重复的/和末尾的/，只有末尾的/时返回path的子串，不需要修改时返回path本身
+func cleanSlashes(path string) string {
+	if !strings.Contains(path, "//") {
+		if trimmed := strings.TrimRight(path, "/"); trimmed != "" {
+			return trimmed
+		}
+		return "/"
+	}
+	return "/" + strings.Join(parsePattern(path), "/")
+}
+
+// hostRouter 返回主机规则host对应的路由器，不存在时新建一个
+func (r *router) hostRouter(host string) *router {
+	host = strings.ToLower(host)
+	for _, h := range r.hosts {
+		if h.host == host {
+			return h
+		}
 	}
-	dstNode := root.search(searchParts, 0)
-	if dstNode != nil { //能够找到匹配的路由
-		parts := parsePattern(dstNode.pattern)
-		for index, part := range parts {
-			//把实际参数取出来，建立一个映射
-			if part[0] == ':' {
-				params[part[1:]] = searchParts[index]
+	h := newRouter()
+	h.host = host
+	h.hostParts = strings.Split(host, ".")
+	if strings.ContainsAny(host, "*:") {
+		r.hosts = append(r.hosts, h)
+		return h
+	}
+	//精确的主机插到所有带通配符的主机前面
+	i := 0
+	for i < len(r.hosts) && !strings.ContainsAny(r.hosts[i].host, "*:") {
+		i++
+	}
+	r.hosts = append(r.hosts, nil)
+	copy(r.hosts[i+1:], r.hosts[i:])
+	r.hosts[i] = h
+	return h
+}
+
+// matchHost 找到请求的主机对应并且注册了该路由的路由器和路由节点，路径参数和主机规则中的参数直接追加到c.Params，
+// 主机参数与路径参数同名时以路径参数为准。没有主机路由匹配时使用默认路由器自己，没有注册主机路由时不做任何额外的工作
+func (r *router) matchHost(c *Context) (*router, *node) {
+	if len(r.hosts) > 0 {
+		host := hostname(c.Req.Host)
+		for _, h := range r.hosts {
+			if !matchHostParts(h.hostParts, host, nil) {
+				continue
+			}
+			if n := h.getRoute(c.Method, c.Path, &c.Params); n != nil {
+				matchHostParts(h.hostParts, host, &c.Params)
+				return h, n
 			}
-			if part[0] == '*' && len(part) > 1 {
-				params[part[1:]] = strings.Join(searchParts[index:], "/")
+		}
+	}
+	return r, r.getRoute(c.Method, c.Path, &c.Params)
+}
+
+// matchHostParts 从右往左用主机规则匹配主机名的各段：:name匹配一段并作为参数，
+// 规则第一段为*时匹配一段或多段，其余各段需要完全相同。
+// params不为nil时把参数追加进去，已经存在的同名参数不会被覆盖
+func matchHostParts(parts []string, host string, params *Params) bool {
+	wildcard := len(parts) > 0 && parts[0] == "*"
+	if wildcard {
+		parts = parts[1:]
+	}
+	rest, exhausted := host, false
+	for i := len(parts) - 1; i >= 0; i-- {
+		if exhausted {
+			return false
+		}
+		label := rest
+		if j := strings.LastIndexByte(rest, '.'); j >= 0 {
+			label, rest = rest[j+1:], rest[:j]
+		} else {
+			exhausted = true
+		}
+		part := parts[i]
+		switch {
+		case len(part) > 1 && part[0] == ':':
+			if params == nil {
 				break
 			}
+			if _, ok := params.Get(part[1:]); !ok {
+				*params = append(*params, Param{Key: part[1:], Value: label})
+			}
+		case part != label:
+			return false
 		}
-		return dstNode, params
 	}
-	return nil, nil
+	return wildcard != exhausted
 }
 
-func (r *router) handle(c *Context) {
-	n, params := r.getRoute(c.Method, c.Path)
+// hostname 去掉请求主机中的端口和末尾的.，并转换成小写
+func hostname(host string) string {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	return strings.ToLower(strings.TrimSuffix(host, "."))
+}
 
+// handle 执行matchHost匹配到的路由节点n的处理函数，n为nil时返回404
+func (r *router) handle(c *Context, n *node) {
 	if n != nil {
-		c.Params = params
-		key := c.Method + "-" + n.pattern
+		c.fullPath = n.pattern
 		//handle 函数中，将从路由匹配得到的 Handler 添加到 c.handlers列表中，执行c.Next()。
-		c.handlers = append(c.handlers, r.handlers[key])
+		c.handlers = append(c.handlers, n.handler)
 	} else {
-		c.handlers = append(c.handlers, func(c *Context) {
-			c.String(http.StatusNotFound, "404 NOT FOUND: %s\n", c.Path)
-		})
+		c.handlers = append(c.handlers, notFound)
 	}
 	c.Next()
 }
+
+// notFound 是没有匹配到路由时的处理函数
+func notFound(c *Context) {
+	c.String(http.StatusNotFound, "404 NOT FOUND: %s\n", c.Path)
+}
@@ -0,0 +1,161 @@
+package psygo
+
+import (
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// benchRoutes 是一组接近真实服务的路由规则
+var benchRoutes = []string{
+	"/",
+	"/goods",
+	"/goods/:id",
+	"/goods/:id/comments",
+	"/goods/:id/comments/:cid",
+	"/goods/hot",
+	"/group",
+	"/users/:user/orders/:order",
+	"/users/:user/profile",
+	"/orders",
+	"/orders/:id/items",
+	"/assets/*filepath",
+	"/api/v1/search",
+	"/api/v1/status",
+	"/api/v2/search",
+}
+
+func newBenchRouter() *router {
+	r := newRouter()
+	for _, pattern := range benchRoutes {
+		r.addRoute(http.MethodGet, pattern, func(c *Context) {})
+	}
+	return r
+}
+
+func TestRouterMatch(t *testing.T) {
+	r := newBenchRouter()
+	r.addRoute(http.MethodGet, "/p/:lang/doc", func(c *Context) {})
+	r.addRoute(http.MethodGet, "/p/:name/intro/", func(c *Context) {})
+	r.addRoute(http.MethodGet, "/*", func(c *Context) {})
+	cases := []struct {
+		path    string
+		pattern string
+		params  Params
+	}{
+		{"/", "/", nil},
+		{"/goods", "/goods", nil},
+		{"/goods/hot", "/goods/hot", nil},
+		{"/goods/42", "/goods/:id", Params{{"id", "42"}}},
+		{"/goods/42/comments/7", "/goods/:id/comments/:cid", Params{{"id", "42"}, {"cid", "7"}}},
+		{"/group", "/group", nil},
+		{"/users/alice/orders/9", "/users/:user/orders/:order", Params{{"user", "alice"}, {"order", "9"}}},
+		{"/assets/js/app.js", "/assets/*filepath", Params{{"filepath", "js/app.js"}}},
+		{"/p/go/doc", "/p/:lang/doc", Params{{"lang", "go"}}},
+		{"/p/go/intro", "/p/:name/intro/", Params{{"name", "go"}}},
+		{"/goods/", "/goods", nil},
+		{"//goods//42/", "/goods/:id", Params{{"id", "42"}}},
+		{"/goods/42/unknown", "/*", Params{{"", "goods/42/unknown"}}},
+	}
+	for _, tc := range cases {
+		var params Params
+		n := r.getRoute(http.MethodGet, tc.path, &params)
+		if n == nil {
+			t.Fatalf("%s: no route", tc.path)
+		}
+		if n.pattern != tc.pattern || len(params) != len(tc.params) {
+			t.Fatalf("%s: matched %s with %v", tc.path, n.pattern, params)
+		}
+		for i := range params {
+			if params[i] != tc.params[i] {
+				t.Fatalf("%s: params %v, want %v", tc.path, params, tc.params)
+			}
+		}
+	}
+	var params Params
+	if n := newBenchRouter().getRoute(http.MethodGet, "/assets/", &params); n != nil || len(params) != 0 {
+		t.Fatal("catch-all should not match an empty path")
+	}
+	if n := r.getRoute(http.MethodPost, "/goods", &params); n != nil {
+		t.Fatal("routes of other methods should not match")
+	}
+}
+
+func TestRouterZeroAlloc(t *testing.T) {
+	r := newBenchRouter()
+	params := make(Params, 0, 8)
+	for _, path := range []string{"/goods/hot", "/api/v2/search", "/goods/42/comments/7", "/assets/js/app.js"} {
+		allocs := testing.AllocsPerRun(100, func() {
+			params = params[:0]
+			if r.getRoute(http.MethodGet, path, &params) == nil {
+				t.Fatalf("%s: no route", path)
+			}
+		})
+		if allocs != 0 {
+			t.Fatalf("%s: %v allocs per lookup", path, allocs)
+		}
+	}
+}
+
+func benchmarkGetRoute(b *testing.B, path string) {
+	r := newBenchRouter()
+	params := make(Params, 0, 8)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		params = params[:0]
+		r.getRoute(http.MethodGet, path, &params)
+	}
+}
+
+func BenchmarkGetRouteStatic(b *testing.B) {
+	benchmarkGetRoute(b, "/api/v2/search")
+}
+
+func BenchmarkGetRouteParam(b *testing.B) {
+	benchmarkGetRoute(b, "/users/alice/orders/9")
+}
+
+func BenchmarkGetRouteCatchAll(b *testing.B) {
+	benchmarkGetRoute(b, "/assets/js/vendor/app.js")
+}
+
+func BenchmarkGetRouteNotFound(b *testing.B) {
+	benchmarkGetRoute(b, "/missing/path")
+}
+
+// discardWriter 是不分配内存的http.ResponseWriter
+type discardWriter struct {
+	header http.Header
+}
+
+func (w *discardWriter) Header() http.Header         { return w.header }
+func (w *discardWriter) Write(b []byte) (int, error) { return len(b), nil }
+func (w *discardWriter) WriteHeader(int)             {}
+
+func benchmarkServeHTTP(b *testing.B, path string) {
+	out := log.Writer()
+	log.SetOutput(io.Discard) //不输出注册路由的日志
+	defer log.SetOutput(out)
+	engine := New()
+	for _, pattern := range benchRoutes {
+		engine.GET(pattern, func(c *Context) {})
+	}
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	w := &discardWriter{header: http.Header{}}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		engine.ServeHTTP(w, req)
+	}
+}
+
+func BenchmarkServeHTTPStatic(b *testing.B) {
+	benchmarkServeHTTP(b, "/api/v2/search")
+}
+
+func BenchmarkServeHTTPParam(b *testing.B) {
+	benchmarkServeHTTP(b, "/users/alice/orders/9")
+}
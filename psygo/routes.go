@@ -0,0 +1,111 @@
+package psygo
+
+import "sort"
+
+// RouteInfo 是一条已注册的路由，注册路由的方法会返回它，可以继续为这条路由补充接口文档，例如
+//
+//	engine.POST("/goods", createGoods).
+//		Summary("create goods").
+//		Request(CreateGoodsReq{}).
+//		Response(http.StatusCreated, Goods{}, "the created goods")
+type RouteInfo struct {
+	Method  string //请求方法
+	Host    string //主机规则，为空时匹配所有主机
+	Path    string //完整的路由规则，例如/goods/:id
+	Handler HandlerFunc
+	Doc     RouteDoc //接口文档
+}
+
+// RouteDoc 描述一个接口，Engine.OpenAPI据此生成文档
+type RouteDoc struct {
+	Summary     string
+	Description string
+	Tags        []string
+	OperationID string
+	Query       any                 //查询参数的结构体，字段名取form标签
+	Request     any                 //请求体的结构体，以application/json描述
+	Responses   map[int]ResponseDoc //状态码 -> 响应
+	Deprecated  bool
+	Hidden      bool //不出现在文档中
+}
+
+// ResponseDoc 描述一种响应
+type ResponseDoc struct {
+	Description string
+	Body        any //响应体的结构体，为nil时没有响应体
+}
+
+// Summary 设置接口的简短说明
+func (r *RouteInfo) Summary(summary string) *RouteInfo {
+	r.Doc.Summary = summary
+	return r
+}
+
+// Description 设置接口的详细说明
+func (r *RouteInfo) Description(description string) *RouteInfo {
+	r.Doc.Description = description
+	return r
+}
+
+// Tags 设置接口的分类
+func (r *RouteInfo) Tags(tags ...string) *RouteInfo {
+	r.Doc.Tags = append(r.Doc.Tags, tags...)
+	return r
+}
+
+// OperationID 设置接口的唯一标识，默认由请求方法和路由规则生成
+func (r *RouteInfo) OperationID(id string) *RouteInfo {
+	r.Doc.OperationID = id
+	return r
+}
+
+// Query 设置查询参数的结构体，例如 Query(ListGoodsQuery{})
+func (r *RouteInfo) Query(obj any) *RouteInfo {
+	r.Doc.Query = obj
+	return r
+}
+
+// Request 设置请求体的结构体，例如 Request(CreateGoodsReq{})
+func (r *RouteInfo) Request(obj any) *RouteInfo {
+	r.Doc.Request = obj
+	return r
+}
+
+// Response 增加一种响应，body为nil时表示没有响应体
+func (r *RouteInfo) Response(code int, body any, description string) *RouteInfo {
+	if r.Doc.Responses == nil {
+		r.Doc.Responses = make(map[int]ResponseDoc)
+	}
+	r.Doc.Responses[code] = ResponseDoc{Description: description, Body: body}
+	return r
+}
+
+// Deprecated 把接口标记为已废弃
+func (r *RouteInfo) Deprecated() *RouteInfo {
+	r.Doc.Deprecated = true
+	return r
+}
+
+// Hidden 让接口不出现在文档中
+func (r *RouteInfo) Hidden() *RouteInfo {
+	r.Doc.Hidden = true
+	return r
+}
+
+// Routes 返回所有已注册的路由，按照路由规则、请求方法和主机排序
+func (engine *Engine) Routes() []*RouteInfo {
+	engine.mu.Lock()
+	routes := make([]*RouteInfo, len(engine.table.routes))
+	copy(routes, engine.table.routes)
+	engine.mu.Unlock()
+	sort.SliceStable(routes, func(i, j int) bool {
+		if routes[i].Path != routes[j].Path {
+			return routes[i].Path < routes[j].Path
+		}
+		if routes[i].Method != routes[j].Method {
+			return routes[i].Method < routes[j].Method
+		}
+		return routes[i].Host < routes[j].Host
+	})
+	return routes
+}
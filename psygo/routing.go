@@ -0,0 +1,133 @@
+package psygo
+
+import "strings"
+
+// routeTable 是路由和分组的集合。引擎当前的集合由Engine.mu保护，ReplaceRoutes先在一个新的集合中注册
+type routeTable struct {
+	routes []*RouteInfo   //按照注册顺序排列的路由
+	groups []*RouterGroup //子分组总是排在父分组之后
+}
+
+// routing 是由routeTable构建出来的路由快照，发布之后不再修改，处理请求时不需要加锁
+type routing struct {
+	router *router
+	groups []*RouterGroup
+}
+
+// add 添加一条路由，方法、主机和路由规则都相同时后注册的生效
+func (t *routeTable) add(route *RouteInfo) {
+	for i, r := range t.routes {
+		if r.Method == route.Method && r.Host == route.Host && r.Path == route.Path {
+			t.routes[i] = route
+			return
+		}
+	}
+	t.routes = append(t.routes, route)
+}
+
+// remove 删除一条路由，返回是否存在
+func (t *routeTable) remove(method, host, pattern string) bool {
+	for i, r := range t.routes {
+		if r.Method == method && r.Host == host && r.Path == pattern {
+			t.routes = append(t.routes[:i:i], t.routes[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// build 按照注册顺序重新构建路由树，得到一份新的快照
+func (t *routeTable) build() *routing {
+	r := newRouter()
+	for _, route := range t.routes {
+		tree := r
+		if route.Host != "" {
+			tree = r.hostRouter(route.Host)
+		}
+		tree.addRoute(route.Method, route.Path, route.Handler)
+	}
+	groups := make([]*RouterGroup, len(t.groups))
+	copy(groups, t.groups)
+	return &routing{router: r, groups: groups}
+}
+
+// update 修改分组所在的路由集合：ReplaceRoutes构建中的分组直接修改新的集合，
+// 否则持有Engine.mu修改引擎的集合并发布新的快照
+func (group *RouterGroup) update(fn func(t *routeTable)) {
+	if group.staged != nil {
+		fn(group.staged)
+		return
+	}
+	engine := group.engine
+	engine.mu.Lock()
+	defer engine.mu.Unlock()
+	fn(&engine.table)
+	engine.publish()
+}
+
+// publish 发布新的路由快照，调用时需要持有Engine.mu。
+// 还没有处理过请求时不构建，启动时注册大量路由不会反复重建路由树
+func (engine *Engine) publish() {
+	if engine.routing.Load() != nil {
+		engine.routing.Store(engine.table.build())
+	}
+}
+
+// loadRouting 在处理第一个请求时构建路由快照
+func (engine *Engine) loadRouting() *routing {
+	engine.mu.Lock()
+	defer engine.mu.Unlock()
+	if rt := engine.routing.Load(); rt != nil {
+		return rt
+	}
+	rt := engine.table.build()
+	engine.routing.Store(rt)
+	return rt
+}
+
+// RemoveRoute 删除一条路由，返回它是否存在。pattern是注册时的完整路由规则，例如/api/goods/:id，
+// 主机分组中的路由在前面加上主机规则，例如admin.example.com/users。可以在处理请求的同时调用，
+// 正在处理的请求不受影响，之后的请求使用新的路由
+func (engine *Engine) RemoveRoute(method, pattern string) bool {
+	host := ""
+	if !strings.HasPrefix(pattern, "/") {
+		if i := strings.IndexByte(pattern, '/'); i > 0 {
+			host, pattern = strings.ToLower(pattern[:i]), pattern[i:]
+		}
+	}
+	engine.mu.Lock()
+	defer engine.mu.Unlock()
+	if !engine.table.remove(strings.ToUpper(method), host, pattern) {
+		return false
+	}
+	engine.publish()
+	return true
+}
+
+// ReplaceRoutes 用fn注册的路由和分组整体替换当前所有的路由和分组(包括直接注册在引擎上的路由)，
+// 引擎自身的中间件保留。fn在一个新的根分组上注册，期间请求仍然使用旧的路由，fn返回后一次性切换，例如
+//
+//	engine.ReplaceRoutes(func(group *psygo.RouterGroup) {
+//		api := group.Group("/api")
+//		api.Use(auth)
+//		for _, p := range enabledPlugins {
+//			p.Register(api)
+//		}
+//	})
+//
+// fn中应当只通过参数group和它的子分组注册路由，直接在engine上注册的路由会被这次替换丢弃。
+// 分组的中间件只能在它生效之前通过Use添加，运行时增删路由请使用RemoveRoute、ReplaceRoutes或者注册新的路由
+func (engine *Engine) ReplaceRoutes(fn func(group *RouterGroup)) {
+	staged := &routeTable{}
+	root := &RouterGroup{parent: engine.RouterGroup, engine: engine, staged: staged}
+	staged.groups = []*RouterGroup{engine.RouterGroup, root}
+	fn(root)
+
+	engine.mu.Lock()
+	defer engine.mu.Unlock()
+	for _, group := range staged.groups[1:] { //staged.groups[0]是引擎自身的分组
+		group.staged = nil //之后在这些分组上注册的路由直接修改引擎的路由
+	}
+	engine.table = *staged
+	engine.publish()
+}
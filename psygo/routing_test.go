@@ -0,0 +1,87 @@
+package psygo
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestRuntimeRoutes(t *testing.T) {
+	engine := New()
+	engine.GET("/static", func(c *Context) {
+		c.String(http.StatusOK, "static")
+	})
+	engine.Host("admin.example.com").GET("/users", func(c *Context) {
+		c.String(http.StatusOK, "admin users")
+	})
+	expect := func(target string, code int, body string, headers ...string) {
+		t.Helper()
+		w := performRequest(engine, http.MethodGet, target, nil, headers...)
+		if w.Code != code || body != "" && w.Body.String() != body {
+			t.Fatalf("GET %s: got %d %q, want %d %q", target, w.Code, w.Body.String(), code, body)
+		}
+	}
+	expect("/static", http.StatusOK, "static")
+
+	engine.GET("/feature", func(c *Context) {
+		c.String(http.StatusOK, "feature")
+	})
+	expect("/feature", http.StatusOK, "feature")
+	if !engine.RemoveRoute("get", "/feature") || engine.RemoveRoute("GET", "/feature") {
+		t.Fatal("RemoveRoute should report whether the route existed")
+	}
+	expect("/feature", http.StatusNotFound, "")
+	expect("/users", http.StatusOK, "admin users", "Host", "admin.example.com")
+	if !engine.RemoveRoute(http.MethodGet, "Admin.example.com/users") {
+		t.Fatal("host route should be removable")
+	}
+	expect("/users", http.StatusNotFound, "", "Host", "admin.example.com")
+
+	engine.ReplaceRoutes(func(group *RouterGroup) {
+		api := group.Group("/api")
+		api.Use(func(c *Context) {
+			c.Header("X-Plugin", "1")
+			c.Next()
+		})
+		api.GET("/plugin", func(c *Context) {
+			c.String(http.StatusOK, "plugin")
+		})
+		//替换完成之前旧的路由仍然生效
+		expect("/static", http.StatusOK, "static")
+		expect("/api/plugin", http.StatusNotFound, "")
+	})
+	expect("/static", http.StatusNotFound, "")
+	w := performRequest(engine, http.MethodGet, "/api/plugin", nil)
+	if w.Body.String() != "plugin" || w.Header().Get("X-Plugin") != "1" {
+		t.Fatalf("GET /api/plugin: %q %v", w.Body.String(), w.Header())
+	}
+	if routes := engine.Routes(); len(routes) != 1 || routes[0].Path != "/api/plugin" {
+		t.Fatalf("routes = %v", routes)
+	}
+}
+
+func TestConcurrentRouteChanges(t *testing.T) {
+	engine := New()
+	ok := func(c *Context) {
+		c.Status(http.StatusOK)
+	}
+	engine.GET("/stable", ok)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 200; i++ {
+			engine.GET("/toggle", ok)
+			engine.RemoveRoute(http.MethodGet, "/toggle")
+			if i%50 == 0 {
+				engine.ReplaceRoutes(func(group *RouterGroup) {
+					group.GET("/stable", ok)
+				})
+			}
+		}
+	}()
+	for i := 0; i < 200; i++ {
+		if w := performRequest(engine, http.MethodGet, "/stable", nil); w.Code != http.StatusOK {
+			t.Fatalf("GET /stable = %d while routes change", w.Code)
+		}
+	}
+	<-done
+}
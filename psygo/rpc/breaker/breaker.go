@@ -15,6 +15,19 @@ const (
 	StateOpen
 )
 
+func (s State) String() string {
+	switch s {
+	case StateClosed:
+		return "closed"
+	case StateHalfOpen:
+		return "half-open"
+	case StateOpen:
+		return "open"
+	default:
+		return "unknown"
+	}
+}
+
 // Counts 计数
 type Counts struct {
 	Requests             uint32 //请求数量
@@ -70,6 +83,7 @@ type CircuitBreaker struct {
 	onStateChange func(name string, from State, to State) //状态变更函数，当熔断器状态发生变更时，触发的函数
 
 	mutex      sync.Mutex
+	changes    []stateChange                //持有锁时发生的状态变更，解锁之后再通知onStateChange
 	state      State                        //状态
 	generation uint64                       //代 状态变更 new一个
 	counts     Counts                       //数量
@@ -77,10 +91,34 @@ type CircuitBreaker struct {
 	fallback   func(err error) (any, error) //降级函数，当熔断器开启的时候，针对业务的降级处理
 }
 
+// stateChange 一次状态变更
+type stateChange struct {
+	from, to State
+}
+
+// unlock 释放cb.mutex，再依次通知持有锁期间发生的状态变更，
+// onStateChange中可以调用State等需要加锁的方法而不会死锁
+func (cb *CircuitBreaker) unlock() {
+	changes := cb.changes
+	cb.changes = nil
+	cb.mutex.Unlock()
+	if cb.onStateChange == nil {
+		return
+	}
+	for _, change := range changes {
+		cb.onStateChange(cb.name, change.from, change.to)
+	}
+}
+
 // NewGeneration 更新熔断器状态
 func (cb *CircuitBreaker) NewGeneration() {
 	cb.mutex.Lock()
 	defer cb.mutex.Unlock()
+	cb.newGeneration(time.Now())
+}
+
+// newGeneration 开始新的一代，清空计数并根据状态重新设置到期时间，调用时需要持有cb.mutex
+func (cb *CircuitBreaker) newGeneration(now time.Time) {
 	cb.generation++
 	cb.counts.Clear()
 	var zero time.Time
@@ -89,10 +127,10 @@ func (cb *CircuitBreaker) NewGeneration() {
 		if cb.interval == 0 {
 			cb.expiry = zero
 		} else {
-			cb.expiry = time.Now().Add(cb.interval)
+			cb.expiry = now.Add(cb.interval)
 		}
 	case StateOpen:
-		cb.expiry = time.Now().Add(cb.timeout)
+		cb.expiry = now.Add(cb.timeout)
 	case StateHalfOpen:
 		cb.expiry = zero
 	}
@@ -151,95 +189,138 @@ func (cb *CircuitBreaker) Execute(req func() (any, error)) (any, error) {
 	}
 	//这个代表一个请求
 	result, err := req()
-	cb.counts.OnRequest()
+	cb.countRequest()
 	//请求之后，做一个判断，当前的状态是否需要变更
 	cb.afterRequest(generation, cb.isSuccess(err))
 	return result, err
 }
 
+// countRequest 记录一次已经完成的请求
+func (cb *CircuitBreaker) countRequest() {
+	cb.mutex.Lock()
+	defer cb.mutex.Unlock()
+	cb.counts.OnRequest()
+}
+
 // beforeRequest 在有请求进来 之前 根据熔断器判断是否允许继续执行业务
 func (cb *CircuitBreaker) beforeRequest() (error, uint64) {
+	cb.mutex.Lock()
+	defer cb.unlock()
 	//判断一下当前的状态 在做处置 断路器如果是打开状态 直接返回err
 	now := time.Now()
 	state, generation := cb.currentState(now)
 	if state == StateOpen {
 		return errors.New("server are melted, Please try it later"), generation
 	}
-	if state == StateHalfOpen {
-		if cb.counts.Requests > cb.maxRequests {
-			return errors.New("too much requests"), generation
-		}
+	if state == StateHalfOpen && cb.counts.Requests > cb.maxRequests {
+		return errors.New("too much requests"), generation
 	}
 	return nil, generation
 }
 
 // afterRequest 请求完成后，判断是否需要做状态变更
 func (cb *CircuitBreaker) afterRequest(before uint64, success bool) {
+	cb.mutex.Lock()
+	defer cb.unlock()
 	now := time.Now()
 	state, generation := cb.currentState(now)
 	if generation != before {
 		return
 	}
 	if success {
-		cb.OnSuccess(state)
+		cb.onSuccess(state, now)
 	} else {
-		cb.OnFail(state)
+		cb.onFail(state, now)
 	}
 }
 
-// currentState 返回了熔断器当前的状态
+// currentState 返回了熔断器当前的状态，调用时需要持有cb.mutex
 func (cb *CircuitBreaker) currentState(now time.Time) (State, uint64) {
-
 	switch cb.state {
 	case StateClosed: //熔断器处于关闭状态
 		if !cb.expiry.IsZero() && cb.expiry.Before(now) { //如果熔断器设置了过期时间且，已经过了过期时间，那么就执行更新熔断器操作
-			cb.NewGeneration()
+			cb.newGeneration(now)
 		}
 	case StateOpen:
 		if cb.expiry.Before(now) { //熔断器开得时间够长了，已经超过了过期时间，由开->半开
-			cb.SetState(StateHalfOpen)
+			cb.setState(StateHalfOpen, now)
 		}
 	}
 	return cb.state, cb.generation
 }
 
+// Name 返回熔断器的名字
+func (cb *CircuitBreaker) Name() string {
+	return cb.name
+}
+
+// State 返回熔断器当前状态的只读快照，可以在监控的采集协程中调用，例如
+//
+//	metrics.RegisterBreaker(reg, cb.Name(), func() float64 { return float64(cb.State()) })
+//
+// 它不会推进状态机，开启状态超过了超时时间时报告为半开，真正的切换留给下一个请求
+func (cb *CircuitBreaker) State() State {
+	cb.mutex.Lock()
+	defer cb.mutex.Unlock()
+	if cb.state == StateOpen && cb.expiry.Before(time.Now()) {
+		return StateHalfOpen
+	}
+	return cb.state
+}
+
 // SetState 设置熔断器当前的状态
 func (cb *CircuitBreaker) SetState(target State) {
+	cb.mutex.Lock()
+	defer cb.unlock()
+	cb.setState(target, time.Now())
+}
+
+// setState 切换状态并开始新的一代，调用时需要持有cb.mutex，并且通过cb.unlock解锁，onStateChange在解锁之后才调用
+func (cb *CircuitBreaker) setState(target State, now time.Time) {
 	if cb.state == target {
 		return
 	}
 	before := cb.state
 	cb.state = target
 	//状态变更之后 应该重新计数
-	cb.NewGeneration()
-
-	if cb.onStateChange != nil {
-		cb.onStateChange(cb.name, before, target)
-	}
+	cb.newGeneration(now)
+	cb.changes = append(cb.changes, stateChange{from: before, to: target})
 }
 
 // OnSuccess 请求被正确执行，根据当前状态判断是否需要切换状态
 func (cb *CircuitBreaker) OnSuccess(state State) {
+	cb.mutex.Lock()
+	defer cb.unlock()
+	cb.onSuccess(state, time.Now())
+}
+
+func (cb *CircuitBreaker) onSuccess(state State, now time.Time) {
 	switch state {
 	case StateClosed:
 		cb.counts.OnSuccess()
 	case StateHalfOpen:
 		cb.counts.OnSuccess()
 		if cb.counts.ConsecutiveSuccesses > cb.maxRequests {
-			cb.SetState(StateClosed)
+			cb.setState(StateClosed, now)
 		}
 	}
 }
 
 // OnFail 请求未被正确执行，根据当前状态判断是否需要切换状态
 func (cb *CircuitBreaker) OnFail(state State) {
+	cb.mutex.Lock()
+	defer cb.unlock()
+	cb.onFail(state, time.Now())
+}
+
+func (cb *CircuitBreaker) onFail(state State, now time.Time) {
 	switch state {
 	case StateClosed: // 熔断器处于关闭状态，有请求没能被正确处理，检查一下是否需要切换到熔断器开启状态
 		cb.counts.OnFail()
 		if cb.readyToTrip(cb.counts) {
-			cb.SetState(StateOpen)
+			cb.setState(StateOpen, now)
 		}
 	case StateHalfOpen: //熔断器半开状态 居然 还是有请求没能被正确处理，那就切换到全开状态
-		cb.SetState(StateOpen)
+		cb.setState(StateOpen, now)
 	}
 }
@@ -0,0 +1,107 @@
+package breaker
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestStateDuringExecute(t *testing.T) {
+	cb := NewCircuitBreaker(Settings{
+		Timeout:     time.Millisecond,
+		ReadyToTrip: func(counts Counts) bool { return counts.ConsecutiveFailures > 1 },
+	})
+	failure := errors.New("failure")
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 200; i++ {
+			_, _ = cb.Execute(func() (any, error) { return nil, failure })
+		}
+	}()
+	for i := 0; i < 200; i++ {
+		_ = cb.State() //监控协程采集状态时不能和Execute产生数据竞争
+	}
+	wg.Wait()
+}
+
+func TestStateIsReadOnly(t *testing.T) {
+	cb := NewCircuitBreaker(Settings{
+		Timeout:     time.Millisecond,
+		ReadyToTrip: func(counts Counts) bool { return counts.ConsecutiveFailures > 0 },
+	})
+	_, _ = cb.Execute(func() (any, error) { return nil, errors.New("failure") })
+	if cb.State() != StateOpen {
+		t.Fatalf("state = %v, want open", cb.State())
+	}
+	time.Sleep(2 * time.Millisecond)
+	generation := cb.generation
+	if cb.State() != StateHalfOpen {
+		t.Fatalf("state = %v, want half-open", cb.State())
+	}
+	if cb.generation != generation || cb.state != StateOpen {
+		t.Fatalf("State advanced the state machine")
+	}
+}
+
+func TestConcurrentExecute(t *testing.T) {
+	cb := NewCircuitBreaker(Settings{
+		Timeout:     time.Millisecond,
+		ReadyToTrip: func(counts Counts) bool { return counts.ConsecutiveFailures > 2 },
+	})
+	failure := errors.New("failure")
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 200; j++ {
+				_, _ = cb.Execute(func() (any, error) {
+					if (i+j)%3 == 0 {
+						return nil, failure
+					}
+					return nil, nil
+				})
+			}
+		}(i)
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 200; i++ {
+			_ = cb.State()
+		}
+	}()
+	wg.Wait()
+}
+
+func TestOnStateChangeCanReadState(t *testing.T) {
+	var cb *CircuitBreaker
+	var changes []string
+	cb = NewCircuitBreaker(Settings{
+		Name:        "goods",
+		ReadyToTrip: func(counts Counts) bool { return counts.ConsecutiveFailures > 0 },
+		OnStateChange: func(name string, from State, to State) {
+			//回调在解锁之后调用，读取熔断器的状态不会死锁
+			changes = append(changes, name+": "+from.String()+" -> "+to.String()+" ("+cb.State().String()+")")
+		},
+	})
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_, _ = cb.Execute(func() (any, error) { return nil, errors.New("failure") })
+		cb.SetState(StateClosed)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("OnStateChange deadlocked reading the breaker state")
+	}
+	want := []string{"goods: closed -> open (open)", "goods: open -> closed (closed)"}
+	if len(changes) != len(want) || changes[0] != want[0] || changes[1] != want[1] {
+		t.Fatalf("changes = %q, want %q", changes, want)
+	}
+}
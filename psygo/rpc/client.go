@@ -179,7 +179,12 @@ func (client *Client) Go(serviceMethod string, args, reply any, done chan *Call)
 
 // Call invokes the named function, waits for it to complete,
 // and returns its error status.
-func (client *Client) Call(ctx context.Context, serviceMethod string, args, reply any) error {
+func (client *Client) Call(ctx context.Context, serviceMethod string, args, reply any) (err error) {
+	if client.opt != nil && client.opt.OnCall != nil {
+		defer func(start time.Time) {
+			client.opt.OnCall(serviceMethod, time.Since(start), err)
+		}(time.Now())
+	}
 	call := client.Go(serviceMethod, args, reply, make(chan *Call, 1))
 	select {
 	case <-ctx.Done():
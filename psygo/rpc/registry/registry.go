@@ -1,6 +1,8 @@
 package registry
 
 import (
+	"context"
+	"fmt"
 	"log"
 	"net/http"
 	"sort"
@@ -96,20 +98,60 @@ func HandleHTTP() {
 // Heartbeat send a heartbeat message every once in a while
 // it's a helper function for a server to register or send heartbeat
 func Heartbeat(registry, addr string, duration time.Duration) { // registryAddr -> "http://localhost:9999/_rpc_/registry"  addr -> "tcp@"+l.Addr().String()
+	StartHeartbeat(registry, addr, duration)
+}
+
+// StartHeartbeat works like Heartbeat and returns a HeartbeatStatus,
+// which can be used by a readiness probe to know whether the server is still registered
+func StartHeartbeat(registry, addr string, duration time.Duration) *HeartbeatStatus {
 	if duration == 0 {
 		// make sure there is enough time to send heart beat
 		// before it's removed from registry
 		duration = defaultTimeout - time.Duration(1)*time.Minute
 	}
-	var err error
-	err = sendHeartbeat(registry, addr)
+	status := &HeartbeatStatus{interval: duration}
+	err := status.record(sendHeartbeat(registry, addr))
 	go func() {
 		t := time.NewTicker(duration)
-		for err == nil {
+		defer t.Stop()
+		for err == nil { // 心跳失败后不再发送，注册中心会在超时后把服务删除
 			<-t.C
-			err = sendHeartbeat(registry, addr)
+			err = status.record(sendHeartbeat(registry, addr))
 		}
 	}()
+	return status
+}
+
+// HeartbeatStatus records the result of the latest heartbeat
+type HeartbeatStatus struct {
+	mu       sync.Mutex
+	interval time.Duration
+	lastSent time.Time // 最近一次成功发送心跳的时间
+	err      error     // 最近一次发送心跳的错误
+}
+
+func (s *HeartbeatStatus) record(err error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.err = err
+	if err == nil {
+		s.lastSent = time.Now()
+	}
+	return err
+}
+
+// Check returns an error if the latest heartbeat failed or heartbeats stopped,
+// its signature matches the health checks of psygo.MountHealth
+func (s *HeartbeatStatus) Check(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		return fmt.Errorf("rpc registry: heartbeat failed: %w", s.err)
+	}
+	if time.Since(s.lastSent) > 2*s.interval {
+		return fmt.Errorf("rpc registry: no heartbeat since %s", s.lastSent.Format(time.RFC3339))
+	}
+	return ctx.Err()
 }
 
 func sendHeartbeat(registry, addr string) error {
@@ -117,7 +159,14 @@ func sendHeartbeat(registry, addr string) error {
 	httpClient := &http.Client{}
 	req, _ := http.NewRequest("POST", registry, nil) // 在这里注册或者发送心跳给registry
 	req.Header.Set("X-rpc-Server", addr)
-	if _, err := httpClient.Do(req); err != nil {
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		log.Println("rpc server: heart beat err:", err)
+		return err
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		err = fmt.Errorf("registry returned %s", resp.Status)
 		log.Println("rpc server: heart beat err:", err)
 		return err
 	}
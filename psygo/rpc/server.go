@@ -29,8 +29,12 @@ type Option struct {
 	CodecType      codec.Type    // client may choose different Codec to encode body
 	ConnectTimeout time.Duration // 0 means no limit
 	HandleTimeout  time.Duration
+	OnCall         CallHook `json:"-"` // called by the client after every Call, not sent to the server
 }
 
+// CallHook is called after an rpc call finished, it can be used to collect call metrics
+type CallHook func(serviceMethod string, duration time.Duration, err error)
+
 var DefaultOption = &Option{
 	MagicNumber:    MagicNumber,
 	CodecType:      codec.GobType,
@@ -42,6 +46,7 @@ type Server struct {
 	serviceMap     sync.Map
 	limiter        Limiter
 	CircuitBreaker *breaker.CircuitBreaker
+	OnCall         CallHook // called after every request handled by the server
 }
 
 // NewServer returns a new Server.
@@ -189,6 +194,7 @@ func (server *Server) handleRequest(cc codec.Codec, req *request, sending *sync.
 	defer wg.Done()
 	called := make(chan struct{})
 	sent := make(chan struct{})
+	start := time.Now()
 
 	var err error
 	if server.CircuitBreaker != nil {
@@ -198,6 +204,7 @@ func (server *Server) handleRequest(cc codec.Codec, req *request, sending *sync.
 				called <- struct{}{}
 				return nil, err
 			})
+			server.observe(req, start, err2)
 			if err2 != nil {
 				req.h.Error = err2.Error()
 				server.sendResponse(cc, req.h, invalidRequest, sending)
@@ -211,6 +218,7 @@ func (server *Server) handleRequest(cc codec.Codec, req *request, sending *sync.
 		go func() {
 			err := req.svc.call(req.mtype, req.argv, req.replyv)
 			called <- struct{}{}
+			server.observe(req, start, err)
 			if err != nil {
 				req.h.Error = err.Error()
 				server.sendResponse(cc, req.h, invalidRequest, sending)
@@ -236,6 +244,13 @@ func (server *Server) handleRequest(cc codec.Codec, req *request, sending *sync.
 	}
 }
 
+// observe reports a handled request to the OnCall hook
+func (server *Server) observe(req *request, start time.Time, err error) {
+	if server.OnCall != nil {
+		server.OnCall(req.h.ServiceMethod, time.Since(start), err)
+	}
+}
+
 // Accept accepts connections on the listener and serves requests
 // for each incoming connection.
 func (server *Server) Accept(lis net.Listener) {
@@ -0,0 +1,33 @@
+package psygo
+
+// SessionKey 会话在Context.Keys中存储的key，由psygo/sessions中的中间件写入
+const SessionKey = "psygo/session"
+
+// Session 服务端会话，具体实现和存储方式见psygo/sessions包
+type Session interface {
+	// ID 返回会话ID
+	ID() string
+	// Get 获得会话中key对应的值
+	Get(key string) any
+	// Set 设置会话中key对应的值
+	Set(key string, value any)
+	// Delete 删除会话中的key
+	Delete(key string)
+	// Clear 清空会话中的所有数据
+	Clear()
+	// Flash 添加一条只会被读取一次的消息
+	Flash(value any)
+	// Flashes 取出并清空所有的Flash消息
+	Flashes() []any
+	// RenewID 保留会话数据但更换会话ID，登录成功后应当调用，防止会话固定攻击
+	RenewID() error
+	// Destroy 销毁会话，同时删除客户端的cookie
+	Destroy()
+}
+
+// Session 返回当前请求的会话，没有使用会话中间件时会panic
+func (c *Context) Session() Session {
+	value, ok := c.Get(SessionKey)
+	assert1(ok, "psygo: session middleware is not installed, use sessions.New first")
+	return value.(Session)
+}
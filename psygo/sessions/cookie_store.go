@@ -0,0 +1,89 @@
+package sessions
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"time"
+)
+
+// maxCookieSize 浏览器对单个cookie的大小限制
+const maxCookieSize = 4096
+
+// ErrCookieTooLarge 会话数据编码后超出了cookie的大小限制
+var ErrCookieTooLarge = errors.New("sessions: encoded session is too large for a cookie")
+
+// CookieStore 把会话数据签名后整个放在cookie里，服务端不保存任何状态
+type CookieStore struct {
+	keys [][]byte
+}
+
+// NewCookieStore 新建一个cookie存储，第一个key用于签名，其余的key只用于验证，方便轮换密钥
+func NewCookieStore(keys ...[]byte) *CookieStore {
+	if len(keys) == 0 {
+		panic("sessions: CookieStore needs at least one key")
+	}
+	return &CookieStore{keys: keys}
+}
+
+// Load 实现了Store接口
+func (s *CookieStore) Load(cookieValue string) (*Record, error) {
+	payload, mac, ok := strings.Cut(cookieValue, ".")
+	if !ok {
+		return nil, ErrNotFound
+	}
+	sum, err := base64.RawURLEncoding.DecodeString(mac)
+	if err != nil {
+		return nil, ErrNotFound
+	}
+	verified := false
+	for _, key := range s.keys {
+		if hmac.Equal(sum, sign(key, payload)) {
+			verified = true
+			break
+		}
+	}
+	if !verified {
+		return nil, ErrNotFound
+	}
+	data, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		return nil, ErrNotFound
+	}
+	rec, err := decodeRecord(data)
+	if err != nil {
+		return nil, err
+	}
+	if rec.Expired(time.Now()) {
+		return nil, ErrNotFound
+	}
+	return rec, nil
+}
+
+// Save 实现了Store接口
+func (s *CookieStore) Save(rec *Record) (string, error) {
+	data, err := encodeRecord(rec)
+	if err != nil {
+		return "", err
+	}
+	payload := base64.RawURLEncoding.EncodeToString(data)
+	value := payload + "." + base64.RawURLEncoding.EncodeToString(sign(s.keys[0], payload))
+	if len(value) > maxCookieSize {
+		return "", ErrCookieTooLarge
+	}
+	return value, nil
+}
+
+// Delete 实现了Store接口，数据都在cookie里，删除cookie即可
+func (s *CookieStore) Delete(rec *Record) error {
+	return nil
+}
+
+// sign 使用HMAC-SHA256对payload签名
+func sign(key []byte, payload string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(payload))
+	return h.Sum(nil)
+}
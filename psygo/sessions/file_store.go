@@ -0,0 +1,139 @@
+package sessions
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// filePrefix 会话文件名的前缀，Cleanup只会处理带这个前缀的文件
+const filePrefix = "psysess_"
+
+// FileStore 把每个会话用gob编码后保存为dir下的一个文件，适合单机部署
+type FileStore struct {
+	dir string
+	mu  sync.RWMutex
+}
+
+// NewFileStore 新建一个文件存储，dir不存在时会自动创建
+func NewFileStore(dir string) (*FileStore, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	return &FileStore{dir: dir}, nil
+}
+
+// Load 实现了Store接口
+func (s *FileStore) Load(cookieValue string) (*Record, error) {
+	if !validID(cookieValue) {
+		return nil, ErrNotFound
+	}
+	s.mu.RLock()
+	data, err := os.ReadFile(s.path(cookieValue))
+	s.mu.RUnlock()
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	rec, err := decodeRecord(data)
+	if err != nil {
+		return nil, err
+	}
+	if rec.Expired(time.Now()) {
+		return nil, ErrNotFound
+	}
+	return rec, nil
+}
+
+// Save 实现了Store接口，先写临时文件再重命名，保证其他请求读到的总是完整的会话
+func (s *FileStore) Save(rec *Record) (string, error) {
+	data, err := encodeRecord(rec)
+	if err != nil {
+		return "", err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	tmp, err := os.CreateTemp(s.dir, "tmp_")
+	if err != nil {
+		return "", err
+	}
+	if _, err = tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+		return "", err
+	}
+	if err = tmp.Close(); err != nil {
+		_ = os.Remove(tmp.Name())
+		return "", err
+	}
+	if err = os.Rename(tmp.Name(), s.path(rec.ID)); err != nil {
+		_ = os.Remove(tmp.Name())
+		return "", err
+	}
+	return rec.ID, nil
+}
+
+// Delete 实现了Store接口
+func (s *FileStore) Delete(rec *Record) error {
+	if !validID(rec.ID) {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	err := os.Remove(s.path(rec.ID))
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// Cleanup 删除所有已经过期或者无法解码的会话文件，可以放在定时任务里调用。
+// 每个文件都在写锁内重新读取和判断，不会删掉刚被Save刷新的会话，读取失败的文件会保留到下一次清理
+func (s *FileStore) Cleanup() error {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return err
+	}
+	var firstErr error
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, filePrefix) {
+			continue
+		}
+		if err := s.cleanupFile(filepath.Join(s.dir, name)); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// cleanupFile 在写锁内检查一个会话文件，过期或者解码失败时删除它
+func (s *FileStore) cleanupFile(path string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	if rec, err := decodeRecord(data); err == nil && !rec.Expired(time.Now()) {
+		return nil
+	}
+	err = os.Remove(path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+func (s *FileStore) path(id string) string {
+	return filepath.Join(s.dir, filePrefix+id)
+}
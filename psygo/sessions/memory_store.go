@@ -0,0 +1,87 @@
+package sessions
+
+import (
+	"sync"
+	"time"
+)
+
+// MemoryStore 把会话保存在进程内存中，后台协程会定期清理过期的会话，进程重启后会话全部丢失
+type MemoryStore struct {
+	mu      sync.RWMutex
+	records map[string]*Record
+	stop    chan struct{}
+	once    sync.Once
+}
+
+// NewMemoryStore 新建一个内存存储，cleanupInterval 是清理过期会话的间隔，<=0表示不清理
+func NewMemoryStore(cleanupInterval time.Duration) *MemoryStore {
+	s := &MemoryStore{
+		records: make(map[string]*Record),
+		stop:    make(chan struct{}),
+	}
+	if cleanupInterval > 0 {
+		go s.janitor(cleanupInterval)
+	}
+	return s
+}
+
+// Load 实现了Store接口
+func (s *MemoryStore) Load(cookieValue string) (*Record, error) {
+	s.mu.RLock()
+	rec, ok := s.records[cookieValue]
+	s.mu.RUnlock()
+	if !ok || rec.Expired(time.Now()) {
+		return nil, ErrNotFound
+	}
+	return rec.clone(), nil
+}
+
+// Save 实现了Store接口
+func (s *MemoryStore) Save(rec *Record) (string, error) {
+	s.mu.Lock()
+	s.records[rec.ID] = rec.clone()
+	s.mu.Unlock()
+	return rec.ID, nil
+}
+
+// Delete 实现了Store接口
+func (s *MemoryStore) Delete(rec *Record) error {
+	s.mu.Lock()
+	delete(s.records, rec.ID)
+	s.mu.Unlock()
+	return nil
+}
+
+// Len 返回当前保存的会话数量
+func (s *MemoryStore) Len() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.records)
+}
+
+// Close 停止后台的清理协程
+func (s *MemoryStore) Close() {
+	s.once.Do(func() {
+		close(s.stop)
+	})
+}
+
+// janitor 定期删除过期的会话
+func (s *MemoryStore) janitor(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case now := <-ticker.C:
+			s.mu.Lock()
+			for id, rec := range s.records {
+				if rec.Expired(now) {
+					delete(s.records, id)
+				}
+			}
+			s.mu.Unlock()
+		case <-s.stop:
+			return
+		}
+	}
+}
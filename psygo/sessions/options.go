@@ -0,0 +1,92 @@
+package sessions
+
+import (
+	"net/http"
+	"time"
+)
+
+// Option 用于设置会话中间件的可选项
+type Option func(opts *Options)
+
+func loadOptions(options ...Option) *Options {
+	opts := &Options{
+		CookieName: "psysession",
+		Path:       "/",
+		HttpOnly:   true,
+		SameSite:   http.SameSiteLaxMode,
+	}
+	for _, option := range options {
+		option(opts)
+	}
+	return opts
+}
+
+// Options 会话中间件的全部配置
+type Options struct {
+	// CookieName 保存会话的cookie名字，默认psysession
+	CookieName string
+	Path       string
+	Domain     string
+	Secure     bool
+	HttpOnly   bool
+	SameSite   http.SameSite
+
+	// IdleTimeout 会话闲置超过这个时间就失效，0表示不限制
+	IdleTimeout time.Duration
+	// AbsoluteTimeout 会话从创建开始超过这个时间就失效，无论是否活跃，0表示不限制
+	AbsoluteTimeout time.Duration
+}
+
+// WithOptions 一次性设置全部配置
+func WithOptions(options Options) Option {
+	return func(opts *Options) {
+		*opts = options
+	}
+}
+
+// WithCookieName 设置保存会话的cookie名字
+func WithCookieName(name string) Option {
+	return func(opts *Options) {
+		opts.CookieName = name
+	}
+}
+
+// WithCookie 设置cookie的作用路径、域名和安全属性
+func WithCookie(path, domain string, secure, httpOnly bool, sameSite http.SameSite) Option {
+	return func(opts *Options) {
+		opts.Path = path
+		opts.Domain = domain
+		opts.Secure = secure
+		opts.HttpOnly = httpOnly
+		opts.SameSite = sameSite
+	}
+}
+
+// WithIdleTimeout 设置会话的闲置过期时间
+func WithIdleTimeout(idleTimeout time.Duration) Option {
+	return func(opts *Options) {
+		opts.IdleTimeout = idleTimeout
+	}
+}
+
+// WithAbsoluteTimeout 设置会话的绝对过期时间
+func WithAbsoluteTimeout(absoluteTimeout time.Duration) Option {
+	return func(opts *Options) {
+		opts.AbsoluteTimeout = absoluteTimeout
+	}
+}
+
+// expiresAt 根据两种过期时间算出会话最终的过期时间，零值表示永不过期
+func (opts *Options) expiresAt(rec *Record) time.Time {
+	var expires time.Time
+	if opts.IdleTimeout > 0 {
+		expires = rec.LastAccess.Add(opts.IdleTimeout)
+	}
+	if opts.AbsoluteTimeout > 0 {
+		absolute := rec.CreatedAt.Add(opts.AbsoluteTimeout)
+		if expires.IsZero() || absolute.Before(expires) {
+			expires = absolute
+		}
+	}
+	return expires
+}
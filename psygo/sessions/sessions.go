@@ -0,0 +1,252 @@
+package sessions
+
+import (
+	"bufio"
+	"errors"
+	"github.com/Psychopath-H/psyweb-master/psygo"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// flashKey Flash消息在会话数据中保存的key
+const flashKey = "_flash"
+
+// New 返回一个会话中间件，在处理函数之前载入会话，在响应头部发出之前保存会话，
+// 处理函数中通过 c.Session() 使用会话
+func New(store Store, options ...Option) psygo.HandlerFunc {
+	opts := loadOptions(options...)
+	return func(c *psygo.Context) {
+		s := &session{store: store, opts: opts}
+		s.load(c)
+		c.Set(psygo.SessionKey, s)
+
+		w := &sessionWriter{ResponseWriter: c.Writer}
+		w.beforeWrite = func() {
+			if err := s.save(w.ResponseWriter); err != nil {
+				_ = c.Error(err)
+			}
+		}
+		c.Writer = w
+		c.Next()
+		//处理函数没有写任何响应时，头部还没有发出，这时候保存也来得及
+		w.fire()
+		c.Writer = w.ResponseWriter
+	}
+}
+
+// session 实现了psygo.Session接口
+type session struct {
+	mu        sync.Mutex
+	store     Store
+	opts      *Options
+	rec       *Record
+	isNew     bool
+	modified  bool
+	destroyed bool
+	stale     []*Record //更换ID或过期之后需要从存储中删除的旧会话
+}
+
+// load 从请求的cookie中载入会话，载入失败或会话过期都会开始一个新的会话
+func (s *session) load(c *psygo.Context) {
+	now := time.Now()
+	if cookie, err := c.Req.Cookie(s.opts.CookieName); err == nil && cookie.Value != "" {
+		rec, err := s.store.Load(cookie.Value)
+		if err != nil && !errors.Is(err, ErrNotFound) {
+			_ = c.Error(err)
+		}
+		if rec != nil {
+			//按照当前的配置重新计算一次过期时间，配置调小之后旧的会话也会立即失效
+			expires := s.opts.expiresAt(rec)
+			if !rec.Expired(now) && (expires.IsZero() || now.Before(expires)) {
+				rec.LastAccess = now
+				s.rec = rec
+				return
+			}
+			s.stale = append(s.stale, rec)
+		}
+	}
+	id, err := newID()
+	if err != nil {
+		_ = c.Error(err)
+	}
+	s.rec = &Record{
+		ID:         id,
+		Values:     make(map[string]any),
+		CreatedAt:  now,
+		LastAccess: now,
+	}
+	s.isNew = true
+}
+
+func (s *session) ID() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.rec.ID
+}
+
+func (s *session) Get(key string) any {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.rec.Values[key]
+}
+
+func (s *session) Set(key string, value any) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rec.Values[key] = value
+	s.modified = true
+}
+
+func (s *session) Delete(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.rec.Values, key)
+	s.modified = true
+}
+
+func (s *session) Clear() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rec.Values = make(map[string]any)
+	s.modified = true
+}
+
+func (s *session) Flash(value any) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	flashes, _ := s.rec.Values[flashKey].([]any)
+	s.rec.Values[flashKey] = append(flashes, value)
+	s.modified = true
+}
+
+func (s *session) Flashes() []any {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	flashes, ok := s.rec.Values[flashKey].([]any)
+	if !ok {
+		return nil
+	}
+	delete(s.rec.Values, flashKey)
+	s.modified = true
+	return flashes
+}
+
+func (s *session) RenewID() error {
+	id, err := newID()
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.isNew {
+		s.stale = append(s.stale, &Record{ID: s.rec.ID})
+	}
+	s.rec.ID = id //CreatedAt保持不变，更换ID不能延长AbsoluteTimeout
+	s.modified = true
+	return nil
+}
+
+func (s *session) Destroy() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.isNew {
+		s.stale = append(s.stale, &Record{ID: s.rec.ID})
+	}
+	s.rec.Values = make(map[string]any)
+	s.destroyed = true
+}
+
+// save 保存会话并写入cookie，只会在响应头部发出之前调用一次
+func (s *session) save(w http.ResponseWriter) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var err error
+	for _, rec := range s.stale {
+		if e := s.store.Delete(rec); e != nil && err == nil {
+			err = e
+		}
+	}
+	if s.destroyed {
+		if !s.isNew || len(s.stale) > 0 {
+			http.SetCookie(w, s.cookie("", -1, time.Time{}))
+		}
+		return err
+	}
+	//从没被写过的新会话不需要保存，避免给每个匿名访问者都创建会话
+	if s.isNew && !s.modified {
+		if len(s.stale) > 0 {
+			http.SetCookie(w, s.cookie("", -1, time.Time{}))
+		}
+		return err
+	}
+	s.rec.ExpiresAt = s.opts.expiresAt(s.rec)
+	value, e := s.store.Save(s.rec)
+	if e != nil {
+		return e
+	}
+	http.SetCookie(w, s.cookie(value, 0, s.rec.ExpiresAt))
+	return err
+}
+
+func (s *session) cookie(value string, maxAge int, expires time.Time) *http.Cookie {
+	return &http.Cookie{
+		Name:     s.opts.CookieName,
+		Value:    value,
+		Path:     s.opts.Path,
+		Domain:   s.opts.Domain,
+		MaxAge:   maxAge,
+		Expires:  expires,
+		Secure:   s.opts.Secure,
+		HttpOnly: s.opts.HttpOnly,
+		SameSite: s.opts.SameSite,
+	}
+}
+
+// sessionWriter 在响应头部第一次发出之前保存会话，因为处理函数返回之后再写cookie就来不及了
+type sessionWriter struct {
+	http.ResponseWriter
+	beforeWrite func()
+	fired       bool
+}
+
+func (w *sessionWriter) fire() {
+	if !w.fired {
+		w.fired = true
+		w.beforeWrite()
+	}
+}
+
+func (w *sessionWriter) WriteHeader(code int) {
+	w.fire()
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *sessionWriter) Write(b []byte) (int, error) {
+	w.fire()
+	return w.ResponseWriter.Write(b)
+}
+
+// Flush 让使用流式响应的处理函数仍然可以调用http.Flusher
+func (w *sessionWriter) Flush() {
+	w.fire()
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack 让websocket等协议升级仍然可以接管连接，接管之前先保存会话，之后的响应不再经过这里
+func (w *sessionWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("sessions: the ResponseWriter doesn't support hijacking")
+	}
+	w.fire()
+	return h.Hijack()
+}
+
+// Unwrap 供http.ResponseController找到原始的ResponseWriter
+func (w *sessionWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
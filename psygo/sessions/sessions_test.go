@@ -0,0 +1,210 @@
+package sessions
+
+import (
+	"bufio"
+	"errors"
+	"github.com/Psychopath-H/psyweb-master/psygo"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// newEngine 返回一个使用store保存会话的引擎，/set 写入会话，/get 读出会话
+func newEngine(store Store, options ...Option) *psygo.Engine {
+	engine := psygo.New()
+	engine.Use(New(store, options...))
+	engine.GET("/set", func(c *psygo.Context) {
+		c.Session().Set("user", c.GetQuery("user"))
+		c.String(http.StatusOK, "ok")
+	})
+	engine.GET("/get", func(c *psygo.Context) {
+		user, _ := c.Session().Get("user").(string)
+		c.String(http.StatusOK, "%s", user)
+	})
+	engine.GET("/login", func(c *psygo.Context) {
+		if err := c.Session().RenewID(); err != nil {
+			c.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+		c.String(http.StatusOK, "%s", c.Session().ID())
+	})
+	return engine
+}
+
+func serve(engine *psygo.Engine, target string, cookie *http.Cookie) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, target, nil)
+	if cookie != nil {
+		req.AddCookie(cookie)
+	}
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, req)
+	return w
+}
+
+func sessionCookie(t *testing.T, w *httptest.ResponseRecorder) *http.Cookie {
+	t.Helper()
+	for _, cookie := range w.Result().Cookies() {
+		if cookie.Name == "psysession" {
+			return cookie
+		}
+	}
+	t.Fatalf("response has no session cookie, header = %v", w.Header())
+	return nil
+}
+
+func TestStoresRoundTrip(t *testing.T) {
+	fileStore, err := NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	memoryStore := NewMemoryStore(0)
+	defer memoryStore.Close()
+	stores := map[string]Store{
+		"cookie": NewCookieStore([]byte("0123456789abcdef0123456789abcdef")),
+		"memory": memoryStore,
+		"file":   fileStore,
+	}
+	for name, store := range stores {
+		engine := newEngine(store)
+		//没有写过的新会话不会下发cookie
+		if w := serve(engine, "/get", nil); len(w.Result().Cookies()) != 0 {
+			t.Fatalf("%s: anonymous request got cookies %v", name, w.Result().Cookies())
+		}
+		//处理函数写出响应之前会话就已经保存，cookie在响应头部中
+		cookie := sessionCookie(t, serve(engine, "/set?user=alice", nil))
+		if !cookie.HttpOnly || cookie.Path != "/" {
+			t.Fatalf("%s: cookie = %v", name, cookie)
+		}
+		if w := serve(engine, "/get", cookie); w.Body.String() != "alice" {
+			t.Fatalf("%s: body = %q, want alice", name, w.Body.String())
+		}
+	}
+}
+
+func TestExpiredSession(t *testing.T) {
+	store := NewMemoryStore(0)
+	defer store.Close()
+	engine := newEngine(store, WithAbsoluteTimeout(time.Hour))
+	id, _ := newID()
+	old := time.Now().Add(-2 * time.Hour)
+	_, _ = store.Save(&Record{ID: id, Values: map[string]any{"user": "alice"}, CreatedAt: old, LastAccess: time.Now()})
+
+	//配置的绝对过期时间已经过了，开始一个新的会话并删除旧的
+	w := serve(engine, "/get", &http.Cookie{Name: "psysession", Value: id})
+	if w.Body.String() != "" {
+		t.Fatalf("expired session was used: %q", w.Body.String())
+	}
+	if cookie := sessionCookie(t, w); cookie.MaxAge >= 0 {
+		t.Fatalf("expired session cookie was not removed: %v", cookie)
+	}
+	if _, err := store.Load(id); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expired session still stored: %v", err)
+	}
+
+	_, _ = store.Save(&Record{ID: id, ExpiresAt: time.Now().Add(-time.Second)})
+	if _, err := store.Load(id); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Load returned an expired record: %v", err)
+	}
+}
+
+func TestRenewID(t *testing.T) {
+	store := NewMemoryStore(0)
+	defer store.Close()
+	engine := newEngine(store, WithAbsoluteTimeout(time.Hour))
+	cookie := sessionCookie(t, serve(engine, "/set?user=alice", nil))
+	before, err := store.Load(cookie.Value)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := serve(engine, "/login", cookie)
+	renewed := sessionCookie(t, w)
+	if renewed.Value == cookie.Value || w.Body.String() != renewed.Value {
+		t.Fatalf("id was not renewed: %q -> %q", cookie.Value, renewed.Value)
+	}
+	if _, err := store.Load(cookie.Value); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("old session still stored: %v", err)
+	}
+	after, err := store.Load(renewed.Value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if after.Values["user"] != "alice" {
+		t.Fatalf("values = %v", after.Values)
+	}
+	//更换ID不能延长绝对过期时间
+	if !after.CreatedAt.Equal(before.CreatedAt) || !after.ExpiresAt.Equal(before.CreatedAt.Add(time.Hour)) {
+		t.Fatalf("created at %v -> %v, expires at %v", before.CreatedAt, after.CreatedAt, after.ExpiresAt)
+	}
+}
+
+func TestFileStoreCleanup(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewFileStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	live, _ := newID()
+	expired, _ := newID()
+	_, _ = store.Save(&Record{ID: live, ExpiresAt: time.Now().Add(time.Hour)})
+	_, _ = store.Save(&Record{ID: expired, ExpiresAt: time.Now().Add(-time.Hour)})
+	corrupt := filepath.Join(dir, filePrefix+"corrupt")
+	if err := os.WriteFile(corrupt, []byte("not gob"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	other := filepath.Join(dir, "other")
+	if err := os.WriteFile(other, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := store.Cleanup(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Load(live); err != nil {
+		t.Fatalf("live session removed: %v", err)
+	}
+	for _, path := range []string{store.path(expired), corrupt} {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Fatalf("%s was not removed", path)
+		}
+	}
+	if _, err := os.Stat(other); err != nil {
+		t.Fatalf("unrelated file removed: %v", err)
+	}
+}
+
+// hijackRecorder 是支持Hijack的ResponseRecorder
+type hijackRecorder struct {
+	*httptest.ResponseRecorder
+	hijacked bool
+}
+
+func (w *hijackRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	w.hijacked = true
+	return nil, nil, nil
+}
+
+func TestSessionWriterHijack(t *testing.T) {
+	store := NewMemoryStore(0)
+	defer store.Close()
+	engine := psygo.New()
+	engine.Use(New(store))
+	engine.GET("/ws", func(c *psygo.Context) {
+		c.Session().Set("user", "alice")
+		if _, _, err := c.Writer.(http.Hijacker).Hijack(); err != nil {
+			t.Errorf("hijack: %v", err)
+		}
+	})
+	w := &hijackRecorder{ResponseRecorder: httptest.NewRecorder()}
+	engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/ws", nil))
+	if !w.hijacked {
+		t.Fatalf("Hijack was not passed through")
+	}
+	if store.Len() != 1 {
+		t.Fatalf("session was not saved before hijacking")
+	}
+}
@@ -0,0 +1,92 @@
+package sessions
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/gob"
+	"encoding/hex"
+	"errors"
+	"time"
+)
+
+// ErrNotFound 存储中找不到对应的会话，或者会话已经过期
+var ErrNotFound = errors.New("sessions: session not found")
+
+// Store 会话的存储，实现了这个接口就可以把会话放到redis等外部存储中
+type Store interface {
+	// Load 根据cookie中的值载入会话，找不到或已过期时返回ErrNotFound
+	Load(cookieValue string) (*Record, error)
+	// Save 保存会话，返回需要写回cookie的值
+	Save(rec *Record) (cookieValue string, err error)
+	// Delete 删除会话
+	Delete(rec *Record) error
+}
+
+// Record 是会话在存储中的形式，Values中的自定义类型需要先调用gob.Register注册
+type Record struct {
+	ID         string
+	Values     map[string]any
+	CreatedAt  time.Time
+	LastAccess time.Time
+	ExpiresAt  time.Time //零值表示永不过期
+}
+
+func init() {
+	//Flash消息以[]any的形式保存，gob默认只注册了基本类型
+	gob.Register([]any{})
+	gob.Register(map[string]any{})
+}
+
+// Expired 判断会话在now时刻是否已经过期
+func (rec *Record) Expired(now time.Time) bool {
+	return !rec.ExpiresAt.IsZero() && now.After(rec.ExpiresAt)
+}
+
+// clone 拷贝一份会话，避免存储和请求之间共享同一个map
+func (rec *Record) clone() *Record {
+	cp := *rec
+	cp.Values = make(map[string]any, len(rec.Values))
+	for k, v := range rec.Values {
+		cp.Values[k] = v
+	}
+	return &cp
+}
+
+// encodeRecord 使用gob把会话编码成字节
+func encodeRecord(rec *Record) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(rec); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// decodeRecord 把gob编码的字节还原成会话
+func decodeRecord(data []byte) (*Record, error) {
+	rec := &Record{}
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(rec); err != nil {
+		return nil, err
+	}
+	if rec.Values == nil {
+		rec.Values = make(map[string]any)
+	}
+	return rec, nil
+}
+
+// newID 生成一个随机的会话ID
+func newID() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// validID 会话ID只能是newID生成的十六进制字符串，防止用cookie构造出文件路径
+func validID(id string) bool {
+	if len(id) != 64 {
+		return false
+	}
+	_, err := hex.DecodeString(id)
+	return err == nil
+}
@@ -0,0 +1,49 @@
+package psygo
+
+import (
+	"github.com/Psychopath-H/psyweb-master/psygo/internal/testhooks"
+	"github.com/Psychopath-H/psyweb-master/psygo/render"
+	"net/http"
+)
+
+// 以下操作只通过internal/testhooks交给psygotest，用于在不启动服务器的情况下单独测试处理函数和中间件，
+// 它们不属于psygo对外的API，使用者的测试代码应当使用psygotest
+func init() {
+	testhooks.Register(testhooks.Hooks[*Context, *Engine, HandlerFunc]{
+		NewContext:        newTestContext,
+		Reset:             (*Context).reset,
+		SetFullPath:       func(c *Context, pattern string) { c.fullPath = pattern },
+		Run:               runTestHandlers,
+		SetRenderObserver: setRenderObserver,
+	})
+}
+
+// newTestContext 返回一个属于engine、写入w的Context，它的请求是 GET /
+func newTestContext(w http.ResponseWriter, engine *Engine) *Context {
+	c := engine.allocateContext().(*Context)
+	req, _ := http.NewRequest(http.MethodGet, "/", nil)
+	c.reset(w, req)
+	return c
+}
+
+// runTestHandlers 不经过路由，直接在c上依次执行handlers，handlers中调用c.Next()和c.Abort()的行为和正常请求一致
+func runTestHandlers(c *Context, handlers ...HandlerFunc) {
+	c.handlers = append(c.handlers[:0], handlers...)
+	c.index = -1
+	c.Next()
+	c.writermem.finish()
+}
+
+// renderObserver 在c.Render真正渲染之前被调用
+type renderObserver func(c *Context, r render.Render)
+
+// setRenderObserver 让engine处理请求时每次通过c.Render渲染响应都先调用observe，测试中可以借此断言使用的模板和数据，
+// observe为nil时取消。没有设置时c.Render只多一次原子读取
+func setRenderObserver(engine *Engine, observe func(c *Context, r render.Render)) {
+	if observe == nil {
+		engine.renderObserver.Store(nil)
+		return
+	}
+	fn := renderObserver(observe)
+	engine.renderObserver.Store(&fn)
+}
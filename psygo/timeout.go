@@ -0,0 +1,211 @@
+package psygo
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// TimeoutOptions 超时中间件的配置
+type TimeoutOptions struct {
+	Timeout    time.Duration
+	StatusCode int         //超时后返回的状态码，默认503，也可以设置为504
+	Handler    HandlerFunc //超时后的处理函数，设置了它StatusCode就不再生效
+}
+
+// Timeout 返回一个超时中间件，后续的处理链必须在d时间内完成，否则返回503
+func Timeout(d time.Duration) HandlerFunc {
+	return TimeoutWithOptions(TimeoutOptions{Timeout: d})
+}
+
+// TimeoutWithOptions 根据配置返回一个超时中间件。
+// 后续的处理链会在一个新的协程里，使用一个不归还给engine.pool的Context副本运行，
+// 它的所有写入都先缓存在timeoutWriter中：按时完成就把缓存一次性写回，超时了就丢弃缓存只返回超时响应，
+// 超时后仍在运行的处理函数只会写到被废弃的副本上，不会和被放回池子复用的Context发生竞争。
+func TimeoutWithOptions(opts TimeoutOptions) HandlerFunc {
+	assert1(opts.Timeout > 0, "Timeout: timeout must be greater than 0")
+	if opts.StatusCode == 0 {
+		opts.StatusCode = http.StatusServiceUnavailable
+	}
+	if opts.Handler == nil {
+		opts.Handler = func(c *Context) {
+			c.String(opts.StatusCode, "%s", http.StatusText(opts.StatusCode))
+		}
+	}
+	return func(c *Context) {
+		ctx, cancel := context.WithTimeout(c.Req.Context(), opts.Timeout)
+		defer cancel()
+
+		tw := &timeoutWriter{header: make(http.Header)}
+		cp := c.copyWithWriter(tw)
+		cp.Req = c.Req.WithContext(ctx) //把截止时间传递给数据库调用等可以感知context的操作
+
+		done := make(chan struct{})
+		panicChan := make(chan any, 1)
+		go func() {
+			defer func() {
+				if p := recover(); p != nil {
+					panicChan <- p
+				}
+			}()
+			cp.Next()
+			close(done)
+		}()
+
+		select {
+		case p := <-panicChan:
+			//交给外层的Recovery处理
+			tw.discard()
+			c.Abort()
+			panic(p)
+		case <-done:
+			c.mergeFrom(cp)
+			tw.flushTo(c.Writer)
+			c.Abort()
+		case <-ctx.Done():
+			tw.discard()
+			if ctx.Err() == context.DeadlineExceeded {
+				_ = c.Error(fmt.Errorf("psygo: handler timeout after %v", opts.Timeout))
+			}
+			opts.Handler(c)
+			c.Abort()
+			//超时后处理函数还会继续运行，它产生的panic不能再交给外层处理，在这里记录一下
+			go func() {
+				select {
+				case p := <-panicChan:
+					log.Printf("psygo: panic after timeout: %v\n", p)
+				case <-done:
+				}
+			}()
+		}
+	}
+}
+
+// copyWithWriter 拷贝出一个使用w作为输出、不在engine.pool中的Context，后续的处理链从当前位置继续执行
+func (c *Context) copyWithWriter(w http.ResponseWriter) *Context {
+	cp := &Context{
+		Writer:     w,
+		Req:        c.Req,
+		Path:       c.Path,
+		Method:     c.Method,
+		Params:     append(Params(nil), c.Params...), //c回到池中后Params和handlers的底层数组会被复用
+		fullPath:   c.fullPath,
+		StatusCode: c.StatusCode,
+		queryCache: c.queryCache,
+		formCache:  c.formCache,
+		handlers:   append([]HandlerFunc(nil), c.handlers...),
+		index:      c.index,
+		sameSite:   c.sameSite,
+		Engine:     c.Engine,
+
+		maxBodySize:        c.maxBodySize, //Decompress等中间件在副本上运行时仍然要受分组的大小限制
+		maxMultipartMemory: c.maxMultipartMemory,
+	}
+	c.mu.RLock()
+	if c.Keys != nil {
+		cp.Keys = make(map[string]any, len(c.Keys))
+		for k, v := range c.Keys {
+			cp.Keys[k] = v
+		}
+	}
+	c.mu.RUnlock()
+	cp.Errors = append(cp.Errors, c.Errors...)
+	return cp
+}
+
+// mergeFrom 处理链按时完成后，把副本上产生的状态同步回原来的Context
+func (c *Context) mergeFrom(cp *Context) {
+	c.StatusCode = cp.StatusCode
+	c.Errors = cp.Errors
+	cp.mu.RLock()
+	c.mu.Lock()
+	c.Keys = cp.Keys
+	c.mu.Unlock()
+	cp.mu.RUnlock()
+}
+
+// timeoutWriter 缓存处理函数写入的头部、状态码和数据，超时之后的写入都会被丢弃
+type timeoutWriter struct {
+	mu        sync.Mutex
+	header    http.Header
+	buf       bytes.Buffer
+	code      int
+	wrote     bool
+	discarded bool
+}
+
+func (tw *timeoutWriter) Header() http.Header {
+	return tw.header
+}
+
+func (tw *timeoutWriter) Write(b []byte) (int, error) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.discarded {
+		return 0, http.ErrHandlerTimeout
+	}
+	if tw.code == 0 {
+		tw.code = http.StatusOK
+	}
+	tw.wrote = true
+	return tw.buf.Write(b)
+}
+
+func (tw *timeoutWriter) WriteHeader(code int) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.discarded || tw.wrote {
+		return
+	}
+	tw.code = code
+}
+
+// Status 实现了responseState，处理链在副本上通过它得到自己设置的状态码
+func (tw *timeoutWriter) Status() int {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	return tw.code
+}
+
+// Size 实现了responseState，返回缓存的响应体大小
+func (tw *timeoutWriter) Size() int {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	return tw.buf.Len()
+}
+
+// Written 实现了responseState，缓存的数据按时完成后一定会被写出，所以写入缓存就算已经写出
+func (tw *timeoutWriter) Written() bool {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	return tw.wrote
+}
+
+// discard 丢弃缓存的数据，之后的写入全部失败
+func (tw *timeoutWriter) discard() {
+	tw.mu.Lock()
+	tw.discarded = true
+	tw.buf.Reset()
+	tw.mu.Unlock()
+}
+
+// flushTo 把缓存的响应一次性写到真正的ResponseWriter中
+func (tw *timeoutWriter) flushTo(w http.ResponseWriter) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	dst := w.Header()
+	for k, v := range tw.header {
+		dst[k] = v
+	}
+	if tw.code != 0 {
+		w.WriteHeader(tw.code)
+	}
+	if tw.buf.Len() > 0 {
+		_, _ = w.Write(tw.buf.Bytes())
+	}
+	tw.discarded = true
+}
@@ -0,0 +1,110 @@
+package psygo
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTimeoutWithErrorHandler(t *testing.T) {
+	engine := New()
+	engine.Use(Timeout(time.Second), ErrorHandler())
+	engine.GET("/written", func(c *Context) {
+		c.String(http.StatusOK, "ok")
+		_ = c.Error(errors.New("private failure"))
+	})
+	engine.GET("/failed", func(c *Context) {
+		_ = c.AbortWithError(http.StatusConflict, errors.New("conflict")).SetType(ErrorTypePublic)
+	})
+
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/written", nil))
+	if w.Code != http.StatusOK || w.Body.String() != "ok" {
+		t.Fatalf("status = %d, body = %q", w.Code, w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/failed", nil))
+	if w.Code != http.StatusConflict || !strings.HasPrefix(w.Header().Get("Content-Type"), "application/problem+json") {
+		t.Fatalf("status = %d, content type = %q", w.Code, w.Header().Get("Content-Type"))
+	}
+}
+
+func TestTimeoutKeepsGroupBodyLimit(t *testing.T) {
+	engine := New()
+	api := engine.Group("/api")
+	api.SetMaxBodySize(1 << 10)
+	api.Use(Timeout(time.Second), ErrorHandler(), Decompress())
+	api.POST("/echo", func(c *Context) {
+		var obj struct {
+			Name string `json:"name"`
+		}
+		if err := c.BindJson(&obj); err != nil {
+			return
+		}
+		c.String(http.StatusOK, "%d", len(obj.Name))
+	})
+
+	//压缩后不到1K，解压后有64K
+	var body bytes.Buffer
+	zw := gzip.NewWriter(&body)
+	_, _ = zw.Write([]byte(`{"name":"` + strings.Repeat("a", 64<<10) + `"}`))
+	_ = zw.Close()
+	if body.Len() >= 1<<10 {
+		t.Fatalf("compressed body is %d bytes", body.Len())
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/api/echo", &body)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Content-Encoding", "gzip")
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, req)
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("status = %d, body = %q", w.Code, w.Body.String())
+	}
+}
+
+func TestTimeoutDeadline(t *testing.T) {
+	for _, status := range []int{http.StatusServiceUnavailable, http.StatusGatewayTimeout} {
+		engine := New()
+		engine.Use(TimeoutWithOptions(TimeoutOptions{Timeout: 20 * time.Millisecond, StatusCode: status}))
+		release := make(chan struct{})
+		lateDone := make(chan error, 1)
+		engine.GET("/slow", func(c *Context) {
+			<-c.Req.Context().Done()
+			<-release
+			//截止时间已过，这些头部和数据都应该被丢弃
+			c.Header("X-Late", "1")
+			c.Set("late", true)
+			_, err := c.Writer.Write([]byte("late"))
+			c.Status(http.StatusOK)
+			lateDone <- err
+		})
+		engine.GET("/fast", func(c *Context) {
+			c.String(http.StatusOK, "fast")
+		})
+
+		w := performRequest(engine, http.MethodGet, "/slow", nil)
+		if w.Code != status || w.Body.String() != http.StatusText(status) {
+			t.Fatalf("status = %d, body = %q, want one %d response", w.Code, w.Body.String(), status)
+		}
+
+		//处理函数在Context被放回池子并被下一个请求复用时才继续写，-race下不能出现竞争
+		close(release)
+		fast := performRequest(engine, http.MethodGet, "/fast", nil)
+		if err := <-lateDone; !errors.Is(err, http.ErrHandlerTimeout) {
+			t.Fatalf("late write error = %v, want http.ErrHandlerTimeout", err)
+		}
+		if fast.Code != http.StatusOK || fast.Body.String() != "fast" || fast.Header().Get("X-Late") != "" {
+			t.Fatalf("reused context got %d %q %v", fast.Code, fast.Body.String(), fast.Header())
+		}
+		if w.Code != status || w.Body.String() != http.StatusText(status) || w.Header().Get("X-Late") != "" {
+			t.Fatalf("late output reached the client: %d %q %v", w.Code, w.Body.String(), w.Header())
+		}
+	}
+}
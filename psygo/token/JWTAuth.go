@@ -5,6 +5,7 @@ import (
 	"github.com/Psychopath-H/psyweb-master/psygo"
 	"github.com/golang-jwt/jwt/v5"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -14,6 +15,8 @@ type JWTAuth struct {
 	refreshTime     time.Duration                     //token刷新续存的时间
 	secretKey       []byte                            //加密AB部分用的私钥
 	AuthFailHandler func(c *psygo.Context, err error) //认证失败处理函数
+	cookieName      string                            //设置了之后token会写入这个cookie，而不是jwt_claims头部
+	cookieOptions   psygo.CookieOptions
 }
 
 func (j *JWTAuth) SetDuration(duration time.Duration) {
@@ -24,6 +27,13 @@ func (j *JWTAuth) SetRefreshTime(refreshTime time.Duration) {
 	j.refreshTime = refreshTime
 }
 
+// SetCookie 让token通过cookie下发和读取，opts一般应设置HttpOnly和Secure，避免被脚本读取或明文传输，
+// 读取时Authorization头部仍然优先
+func (j *JWTAuth) SetCookie(name string, opts psygo.CookieOptions) {
+	j.cookieName = name
+	j.cookieOptions = opts
+}
+
 func (j *JWTAuth) SetAuthFailHandler(failHandler func(c *psygo.Context, err error)) {
 	j.AuthFailHandler = failHandler
 }
@@ -58,24 +68,40 @@ func (j *JWTAuth) CreateTokenWithDuration(c *psygo.Context, username string, use
 	if err != nil {
 		return err
 	}
-	c.Header("jwt_claims", tokenString)
+	j.writeToken(c, tokenString, duration)
 	return nil
 }
 
+// writeToken 把token返回给前端，设置了cookie时写入cookie，否则写入jwt_claims头部
+func (j *JWTAuth) writeToken(c *psygo.Context, tokenString string, duration time.Duration) {
+	if j.cookieName == "" {
+		c.Header("jwt_claims", tokenString)
+		return
+	}
+	opts := j.cookieOptions
+	if opts.MaxAge == 0 && opts.Expires.IsZero() {
+		opts.MaxAge = int(duration.Seconds())
+	}
+	c.SetCookieWithOptions(j.cookieName, tokenString, opts)
+}
+
 // VerifyToken 验证token是否有效
 func (j *JWTAuth) VerifyToken(c *psygo.Context) (*CustomClaims, *jwt.Token, bool) {
-	auth := c.Req.Header.Get("Authorization")
-	if auth == "" {
-		j.AuthErrorHandler(c, errors.New("token is nil"))
+	claims, parsedToken, err := j.parseToken(c)
+	if err != nil {
+		j.AuthErrorHandler(c, err)
 		return nil, nil, false
 	}
-	parts := strings.SplitN(auth, " ", 2)
-	if !(len(parts) == 2 && parts[0] == "Bearer") {
-		j.AuthErrorHandler(c, errors.New("token is invalid"))
-		return nil, nil, false
+	return claims, parsedToken, true
+}
+
+// parseToken 从请求的Authorization头部或cookie中解析出token，不做任何失败处理
+func (j *JWTAuth) parseToken(c *psygo.Context) (*CustomClaims, *jwt.Token, error) {
+	token, err := j.extractToken(c)
+	if err != nil {
+		return nil, nil, err
 	}
 	//解析token
-	token := parts[1]
 	claims := &CustomClaims{}
 	parsedToken, err := jwt.ParseWithClaims(token, claims, func(token *jwt.Token) (interface{}, error) { //用得哪一套签名算法给他解析回去
 		if j.secretKey != nil {
@@ -85,10 +111,42 @@ func (j *JWTAuth) VerifyToken(c *psygo.Context) (*CustomClaims, *jwt.Token, bool
 		}
 	})
 	if err != nil || !parsedToken.Valid {
-		j.AuthErrorHandler(c, errors.New("token is invalid"))
-		return nil, nil, false
+		return nil, nil, errors.New("token is invalid")
+	}
+	return claims, parsedToken, nil
+}
+
+// extractToken 优先从Authorization头部中取出token，没有的话再从cookie中取
+func (j *JWTAuth) extractToken(c *psygo.Context) (string, error) {
+	auth := c.Req.Header.Get("Authorization")
+	if auth == "" {
+		if j.cookieName != "" {
+			if token, err := c.Cookie(j.cookieName); err == nil && token != "" {
+				return token, nil
+			}
+		}
+		return "", errors.New("token is nil")
+	}
+	parts := strings.SplitN(auth, " ", 2)
+	if !(len(parts) == 2 && parts[0] == "Bearer") {
+		return "", errors.New("token is invalid")
+	}
+	return parts[1], nil
+}
+
+// SubjectKey 返回按照token主体进行限流的psygo.RateLimitKeyFunc，没有token或token无效的请求按照客户端IP限流，
+// 否则伪造token就可以绕过限流
+func (j *JWTAuth) SubjectKey() psygo.RateLimitKeyFunc {
+	return func(c *psygo.Context) string {
+		claims, _, err := j.parseToken(c)
+		if err != nil {
+			return "ip:" + c.ClientIP()
+		}
+		if claims.Subject != "" {
+			return "jwt:" + claims.Subject
+		}
+		return "jwt:" + strconv.FormatInt(claims.UserID, 10)
 	}
-	return claims, parsedToken, true
 }
 
 // AuthInterceptor jwt中间件 判断header里面是否有对应的token
@@ -121,7 +179,7 @@ func (j *JWTAuth) AuthInterceptor() psygo.HandlerFunc {
 			} else {
 				tokenString, _ = parsedToken.SignedString([]byte("moyn8y9abng7q4zkq2m73yw8tu9j5ixm"))
 			}
-			c.Header("jwt_claims", tokenString)
+			j.writeToken(c, tokenString, claims.ExpiresAt.Time.Sub(now))
 		}
 
 	}
@@ -0,0 +1,58 @@
+package token
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Psychopath-H/psyweb-master/psygo"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func TestSubjectKey(t *testing.T) {
+	j := &JWTAuth{secretKey: []byte("test-secret")}
+	engine := psygo.New()
+	engine.Use(psygo.RateLimit(psygo.RateLimitOptions{Limit: 1, Period: time.Hour, KeyFunc: j.SubjectKey()}))
+	engine.GET("/goods", func(c *psygo.Context) {
+		c.String(http.StatusOK, "ok")
+	})
+	request := func(token string) int {
+		req := httptest.NewRequest(http.MethodGet, "/goods", nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, req)
+		return w.Code
+	}
+	sign := func(userID int64) string {
+		claims := CustomClaims{UserID: userID, RegisteredClaims: jwt.RegisteredClaims{ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour))}}
+		token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(j.secretKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return token
+	}
+
+	//没有token和伪造的token都按照客户端IP限流，换一个伪造的token不能绕过限制
+	if code := request(""); code != http.StatusOK {
+		t.Fatalf("first anonymous request: status = %d", code)
+	}
+	if code := request("forged-1"); code != http.StatusTooManyRequests {
+		t.Fatalf("forged token: status = %d, want 429", code)
+	}
+	if code := request("forged-2"); code != http.StatusTooManyRequests {
+		t.Fatalf("another forged token: status = %d, want 429", code)
+	}
+	//有效的token按照主体限流
+	if code := request(sign(1)); code != http.StatusOK {
+		t.Fatalf("user 1: status = %d", code)
+	}
+	if code := request(sign(1)); code != http.StatusTooManyRequests {
+		t.Fatalf("user 1 again: status = %d, want 429", code)
+	}
+	if code := request(sign(2)); code != http.StatusOK {
+		t.Fatalf("user 2: status = %d", code)
+	}
+}
@@ -2,76 +2,165 @@ package psygo
 
 import "strings"
 
-type node struct {
-	pattern  string  //待匹配路由，例如/p/:lang
-	part     string  //路由中的一部分，例如:lang
-	children []*node // 子结点，例如[doc, tutorial, intro]
-	isWild   bool    //是否精确匹配，part含有 : 或 * 时为true
+// Param 是一个路由参数，例如/goods/:id匹配/goods/42时Key为id，Value为42
+type Param struct {
+	Key   string
+	Value string
 }
 
-// matchChild 第一个成功匹配的节点，用于插入
-func (n *node) matchChild(part string) *node {
-	for _, child := range n.children {
-		if child.part == part || child.isWild {
-			return child
+// Params 是本次路由得到的参数，按照在路由规则中出现的顺序排列
+type Params []Param
+
+// Get 返回名为name的参数的值和它是否存在
+func (ps Params) Get(name string) (string, bool) {
+	for _, p := range ps {
+		if p.Key == name {
+			return p.Value, true
 		}
 	}
-	return nil
+	return "", false
+}
+
+// ByName 返回名为name的参数的值，不存在时返回空字符串
+func (ps Params) ByName(name string) string {
+	value, _ := ps.Get(name)
+	return value
+}
+
+// node 是压缩前缀树(radix tree)的节点。静态部分按照公共前缀合并，
+// 例如/goods、/group和/goods/:id合并成 /g -> oods -> /:id 和 /g -> roup。
+// :param匹配一段，*param匹配剩下的全部(至少一个字符)，查找时静态节点优先于:param，:param优先于*param，
+// 优先的分支匹配失败时会回溯尝试其它分支
+type node struct {
+	path     string      //静态节点匹配的一段路径，参数节点为空
+	indices  string      //静态子节点path的首字母，和children一一对应
+	children []*node     //静态子节点
+	param    *node       //:param子节点
+	catchAll *node       //*param子节点
+	pattern  string      //注册时的路由规则，例如/p/:lang/doc，不是路由终点时为空
+	handler  HandlerFunc //路由的处理函数
+	keys     []string    //路由规则中参数的名字，和查找时得到的参数值按顺序一一对应，*没有名字时为空字符串
 }
 
-// matchChildren 所有匹配成功的节点，用于查找
-func (n *node) matchChildren(part string) []*node {
-	nodes := make([]*node, 0)
-	for _, child := range n.children {
-		if child.part == part || child.isWild {
-			nodes = append(nodes, child)
+// insert 插入路由规则。path是去掉了多余的/之后的规则，例如/p/:lang/doc，pattern是注册时的原始规则
+func (n *node) insert(path, pattern string, handler HandlerFunc) {
+	var keys []string
+	for _, part := range parsePattern(path) {
+		if part[0] == ':' || part[0] == '*' {
+			keys = append(keys, part[1:])
+		}
+	}
+	for {
+		if path == "" {
+			n.pattern, n.handler, n.keys = pattern, handler, keys
+			return
+		}
+		switch path[0] {
+		case ':':
+			if n.param == nil {
+				n.param = &node{}
+			}
+			n = n.param
+			path = path[segmentEnd(path):]
+			continue
+		case '*':
+			if n.catchAll == nil {
+				n.catchAll = &node{}
+			}
+			n = n.catchAll
+			path = "" //*之后的部分在parsePattern中已经被丢弃
+			continue
 		}
+		//静态部分一直到下一个以:或*开头的段
+		static := path
+		if i := wildcardStart(path); i >= 0 {
+			static = path[:i]
+		}
+		n = n.insertStatic(static)
+		path = path[len(static):]
 	}
-	return nodes
 }
 
-// 对于路由来说，最重要的当然是注册与匹配了。开发服务时，注册路由规则，映射handler；访问时，匹配路由规则，查找到对应的handler。
-// 因此，Trie 树需要支持节点的插入与查询。插入功能很简单，递归查找每一层的节点，如果没有匹配到当前part的节点，则新建一个，有一点需要注意，
-// /p/:lang/doc只有在第三层节点，即doc节点，pattern才会设置为/p/:lang/doc。p和:lang节点的pattern属性皆为空。因此，当匹配结束时，
-// 我们可以使用n.pattern == ""来判断路由规则是否匹配成功。例如，/p/python虽能成功匹配到:lang，但:lang的pattern值为空，因此匹配失败。
-
-//查询功能，同样也是递归查询每一层的节点，退出规则是，匹配到了*，匹配失败，或者匹配到了第len(parts)层节点。
+// insertStatic 在n下插入一段静态路径，必要时拆分已有的节点，返回这段路径终点的节点
+func (n *node) insertStatic(path string) *node {
+	for path != "" {
+		i := strings.IndexByte(n.indices, path[0])
+		if i < 0 {
+			child := &node{path: path}
+			n.indices += path[:1]
+			n.children = append(n.children, child)
+			return child
+		}
+		child := n.children[i]
+		l := commonPrefix(path, child.path)
+		if l < len(child.path) { //拆分child，公共前缀成为新的节点
+			rest := *child
+			rest.path = child.path[l:]
+			*child = node{path: child.path[:l], indices: rest.path[:1], children: []*node{&rest}}
+		}
+		n = child
+		path = path[l:]
+	}
+	return n
+}
 
-// insert 在前缀树中插入节点
-func (n *node) insert(pattern string, parts []string, height int) {
-	//插入到最后一个字符串了(根节点不算高度的情况下，树的高度和字符串长度相同)，把整个地址赋给当前节点
-	if len(parts) == height {
-		n.pattern = pattern
-		return
+// search 在n下查找path，找到时返回路由终点的节点，params中按顺序追加了参数的值
+func (n *node) search(path string, params *Params) *node {
+	if path == "" {
+		if n.handler != nil {
+			return n
+		}
+		return nil
+	}
+	if i := strings.IndexByte(n.indices, path[0]); i >= 0 {
+		child := n.children[i]
+		if strings.HasPrefix(path, child.path) {
+			if found := child.search(path[len(child.path):], params); found != nil {
+				return found
+			}
+		}
+	}
+	if n.param != nil {
+		if end := segmentEnd(path); end > 0 {
+			*params = append(*params, Param{Value: path[:end]})
+			if found := n.param.search(path[end:], params); found != nil {
+				return found
+			}
+			*params = (*params)[:len(*params)-1]
+		}
 	}
-	//还没到最后一个字符串
-	part := parts[height]
-	child := n.matchChild(part)
-	if child == nil { //没找到匹配的，那就新建一个
-		child = &node{part: part, isWild: part[0] == ':' || part[0] == '*'}
-		n.children = append(n.children, child)
+	if n.catchAll != nil && n.catchAll.handler != nil {
+		*params = append(*params, Param{Value: path})
+		return n.catchAll
 	}
-	child.insert(pattern, parts, height+1) //对下一个节点做插入操作
+	return nil
+}
+
+// segmentEnd 返回path中第一段的长度，即第一个/的位置
+func segmentEnd(path string) int {
+	if i := strings.IndexByte(path, '/'); i >= 0 {
+		return i
+	}
+	return len(path)
 }
 
-// search 用于在前缀树中查询节点
-func (n *node) search(parts []string, height int) *node {
-	//找到最后的字符串了，或者说遇到通配符*了，要做返回操作
-	if len(parts) == height || strings.HasPrefix(n.part, "*") {
-		if n.pattern == "" {
-			return nil
+// wildcardStart 返回path中第一个以:或*开头的段的位置，没有时返回-1
+func wildcardStart(path string) int {
+	for i := 1; i < len(path); i++ {
+		if path[i-1] == '/' && (path[i] == ':' || path[i] == '*') {
+			return i
 		}
-		return n
 	}
-	//没找到最后的字符串，继续去匹配
-	part := parts[height]
-	children := n.matchChildren(part)
-	//对每一个能匹配上的children继续搜索
-	for _, child := range children {
-		result := child.search(parts, height+1)
-		if result != nil {
-			return result
+	return -1
+}
+
+// commonPrefix 返回a和b公共前缀的长度
+func commonPrefix(a, b string) int {
+	n := min(len(a), len(b))
+	for i := 0; i < n; i++ {
+		if a[i] != b[i] {
+			return i
 		}
 	}
-	return nil
+	return n
 }
@@ -0,0 +1,375 @@
+package psygo
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/Psychopath-H/psyweb-master/psygo/psyerror"
+	"hash"
+	"io"
+	"io/fs"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// DefaultMaxFieldSize 是UploadOptions.MaxFieldSize的默认值
+const DefaultMaxFieldSize = 1 << 20 //1M
+
+// 流式上传产生的错误，可以直接交给c.Problem渲染
+var (
+	ErrFileTooLarge       = psyerror.New(http.StatusRequestEntityTooLarge, "upload.file_too_large", "uploaded file is too large")
+	ErrUploadTooLarge     = psyerror.New(http.StatusRequestEntityTooLarge, "upload.too_large", "upload is too large")
+	ErrFieldTooLarge      = psyerror.New(http.StatusRequestEntityTooLarge, "upload.field_too_large", "form field is too large")
+	ErrTooManyFiles       = psyerror.New(http.StatusRequestEntityTooLarge, "upload.too_many_files", "too many files")
+	ErrFileTypeNotAllowed = psyerror.New(http.StatusUnsupportedMediaType, "upload.type_not_allowed", "file type is not allowed")
+	ErrUnsafeFilePath     = psyerror.New(http.StatusBadRequest, "upload.unsafe_path", "unsafe file path")
+	ErrFileExists         = psyerror.New(http.StatusConflict, "upload.file_exists", "file already exists")
+	ErrMalformedUpload    = psyerror.New(http.StatusBadRequest, "upload.malformed", "malformed multipart request")
+)
+
+// UploadOptions 是流式读取multipart请求时的限制和回调，零值表示不做任何限制
+type UploadOptions struct {
+	MaxFileSize  int64 //单个文件的最大字节数，<=0表示不限制
+	MaxTotalSize int64 //所有文件和普通字段合计的最大字节数，<=0表示不限制
+	MaxFiles     int   //最多的文件个数，<=0表示不限制
+	MaxFieldSize int64 //单个普通字段的最大字节数，默认DefaultMaxFieldSize
+
+	// AllowedExts 允许的文件扩展名，例如[".jpg", ".png"]，不区分大小写，为空时不限制
+	AllowedExts []string
+	// AllowedTypes 允许的文件类型，由文件内容的前512字节嗅探得到而不是相信客户端，
+	// 例如["image/png", "video/*"]，为空时不限制
+	AllowedTypes []string
+	// Hash 不为nil时在读取文件的同时计算摘要，例如sha256.New，读完之后通过UploadPart.Sum取得
+	Hash func() hash.Hash
+	// OnProgress 每次读取文件内容之后调用，written是这个文件已经读取的字节数
+	OnProgress func(part *UploadPart, written int64)
+	// Name 返回SaveUploads保存文件时使用的相对路径，此时文件内容还没有读取。
+	// 为nil时使用随机生成的文件名加上原来的扩展名，不同用户上传的同名文件不会互相覆盖
+	Name func(part *UploadPart) string
+}
+
+// UploadPart 是multipart请求中正在读取的一个文件，读取时会检查大小限制并计算摘要
+type UploadPart struct {
+	Field       string               //表单字段名
+	Filename    string               //客户端提供的文件名，已经去掉了目录部分
+	ContentType string               //由文件内容嗅探得到的类型，例如image/png
+	Header      textproto.MIMEHeader //这一部分的头部，其中的Content-Type由客户端提供
+
+	r       *bufio.Reader
+	written int64
+	hash    hash.Hash
+	opts    *UploadOptions
+	total   *int64 //整个请求已经读取的字节数
+}
+
+// Read 读取文件内容，超过MaxFileSize或者MaxTotalSize时返回ErrFileTooLarge或ErrUploadTooLarge
+func (p *UploadPart) Read(b []byte) (int, error) {
+	n, err := p.r.Read(b)
+	if n > 0 {
+		p.written += int64(n)
+		*p.total += int64(n)
+		if p.opts.MaxFileSize > 0 && p.written > p.opts.MaxFileSize {
+			return 0, ErrFileTooLarge.WithDetail(fmt.Sprintf("%s exceeds %d bytes", p.Filename, p.opts.MaxFileSize))
+		}
+		if p.opts.MaxTotalSize > 0 && *p.total > p.opts.MaxTotalSize {
+			return 0, ErrUploadTooLarge.WithDetail(fmt.Sprintf("upload exceeds %d bytes", p.opts.MaxTotalSize))
+		}
+		if p.hash != nil {
+			p.hash.Write(b[:n])
+		}
+		if p.opts.OnProgress != nil {
+			p.opts.OnProgress(p, p.written)
+		}
+	}
+	return n, err
+}
+
+// Size 返回已经读取的字节数，读完之后就是文件的大小
+func (p *UploadPart) Size() int64 {
+	return p.written
+}
+
+// Sum 返回已经读取的内容的十六进制摘要，没有设置UploadOptions.Hash时返回空字符串
+func (p *UploadPart) Sum() string {
+	if p.hash == nil {
+		return ""
+	}
+	return hex.EncodeToString(p.hash.Sum(nil))
+}
+
+// UploadedFile 是SaveUploads保存下来的一个文件
+type UploadedFile struct {
+	Field       string `json:"field"`
+	Filename    string `json:"filename"` //客户端提供的文件名
+	Path        string `json:"path"`     //保存的位置
+	ContentType string `json:"content_type"`
+	Size        int64  `json:"size"`
+	Sum         string `json:"sum,omitempty"`
+}
+
+// MultipartReader 返回读取multipart请求体的*multipart.Reader，请求体不会被缓存到内存或临时文件中，
+// 和FormFile、MultipartForm不能同时使用
+func (c *Context) MultipartReader() (*multipart.Reader, error) {
+	return c.Req.MultipartReader()
+}
+
+// StreamUpload 按顺序流式读取multipart请求：普通字段读进返回的url.Values，
+// 每个文件在通过扩展名和类型检查后交给handle，handle从part中读取内容，例如写到对象存储。
+// 文件不需要先完整地落到磁盘上，适合很大的文件。handle返回错误时停止读取并返回这个错误，
+// handle没有读完的内容会被跳过，这时part.Size和part.Sum只对应已经读取的部分
+func (c *Context) StreamUpload(opts UploadOptions, handle func(part *UploadPart) error) (url.Values, error) {
+	mr, err := c.MultipartReader()
+	if err != nil {
+		return nil, uploadReadError(err)
+	}
+	if opts.MaxFieldSize <= 0 {
+		opts.MaxFieldSize = DefaultMaxFieldSize
+	}
+	fields := url.Values{}
+	var total int64
+	files := 0
+	for {
+		p, err := mr.NextPart()
+		if err == io.EOF {
+			return fields, nil
+		}
+		if err != nil {
+			return fields, uploadReadError(err)
+		}
+		name := p.FormName()
+		if name == "" {
+			_ = p.Close()
+			continue
+		}
+		if p.FileName() == "" { //普通字段
+			value, err := io.ReadAll(io.LimitReader(p, opts.MaxFieldSize+1))
+			_ = p.Close()
+			if err != nil {
+				return fields, uploadReadError(err)
+			}
+			if int64(len(value)) > opts.MaxFieldSize {
+				return fields, ErrFieldTooLarge.WithDetail(fmt.Sprintf("field %s exceeds %d bytes", name, opts.MaxFieldSize))
+			}
+			total += int64(len(value))
+			if opts.MaxTotalSize > 0 && total > opts.MaxTotalSize {
+				return fields, ErrUploadTooLarge.WithDetail(fmt.Sprintf("upload exceeds %d bytes", opts.MaxTotalSize))
+			}
+			fields.Add(name, string(value))
+			continue
+		}
+		files++
+		if opts.MaxFiles > 0 && files > opts.MaxFiles {
+			_ = p.Close()
+			return fields, ErrTooManyFiles.WithDetail(fmt.Sprintf("at most %d files", opts.MaxFiles))
+		}
+		part, err := newUploadPart(p, &opts, &total)
+		if err != nil {
+			_ = p.Close()
+			return fields, uploadReadError(err)
+		}
+		err = handle(part)
+		_ = p.Close()
+		var maxBytesError *http.MaxBytesError
+		if errors.As(err, &maxBytesError) {
+			//handle的其它错误(例如写磁盘失败)原样返回，只把请求体超出限制转换成413
+			return fields, uploadReadError(err)
+		}
+		if err != nil {
+			return fields, err
+		}
+	}
+}
+
+// uploadReadError 把读取请求体时的错误转换成可以直接渲染的错误：请求体超出MaxBodySize时为413的ErrUploadTooLarge，
+// 不是multipart请求、boundary错误等格式问题为400的ErrMalformedUpload，已经是PsyError的原样返回
+func uploadReadError(err error) error {
+	var maxBytesError *http.MaxBytesError
+	if errors.As(err, &maxBytesError) {
+		return ErrUploadTooLarge.WithDetail(fmt.Sprintf("request body exceeds %d bytes", maxBytesError.Limit)).Wrap(err)
+	}
+	var psyError *psyerror.PsyError
+	if errors.As(err, &psyError) {
+		return err
+	}
+	return ErrMalformedUpload.Wrap(err)
+}
+
+// newUploadPart 嗅探文件类型并检查扩展名和类型是否允许
+func newUploadPart(p *multipart.Part, opts *UploadOptions, total *int64) (*UploadPart, error) {
+	part := &UploadPart{
+		Field:    p.FormName(),
+		Filename: p.FileName(),
+		Header:   p.Header,
+		r:        bufio.NewReaderSize(p, 4096),
+		opts:     opts,
+		total:    total,
+	}
+	if len(opts.AllowedExts) > 0 && !extAllowed(part.Filename, opts.AllowedExts) {
+		return nil, ErrFileTypeNotAllowed.WithDetail(fmt.Sprintf("extension of %s is not allowed", part.Filename))
+	}
+	head, err := part.r.Peek(512)
+	if err != nil && err != io.EOF && !errors.Is(err, bufio.ErrBufferFull) {
+		return nil, err
+	}
+	part.ContentType = http.DetectContentType(head)
+	if len(opts.AllowedTypes) > 0 && !typeAllowed(part.ContentType, opts.AllowedTypes) {
+		return nil, ErrFileTypeNotAllowed.WithDetail(fmt.Sprintf("%s is %s", part.Filename, part.ContentType))
+	}
+	if opts.Hash != nil {
+		part.hash = opts.Hash()
+	}
+	return part, nil
+}
+
+// extAllowed 判断文件的扩展名是否在allowed中
+func extAllowed(filename string, allowed []string) bool {
+	ext := filepath.Ext(filename)
+	for _, a := range allowed {
+		if strings.EqualFold(ext, a) {
+			return true
+		}
+	}
+	return false
+}
+
+// typeAllowed 判断嗅探得到的类型是否在allowed中，allowed中的image/*匹配所有image类型
+func typeAllowed(contentType string, allowed []string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	for _, a := range allowed {
+		if prefix, ok := strings.CutSuffix(a, "/*"); ok {
+			if strings.HasPrefix(mediaType, prefix+"/") {
+				return true
+			}
+		} else if strings.EqualFold(mediaType, a) {
+			return true
+		}
+	}
+	return false
+}
+
+// SaveUploads 用StreamUpload读取请求，把每个文件保存到root目录下，返回保存的文件和普通字段。
+// 文件名由opts.Name决定，默认是随机生成的，客户端提供的文件名只记录在UploadedFile.Filename中。
+// 文件先写入同目录下的临时文件，完整读取并通过检查之后才放到最终的位置，同名文件已经存在时返回ErrFileExists，
+// 失败时不会留下不完整的文件，但是已经保存好的文件会保留，调用方可以根据返回的文件自行清理
+func (c *Context) SaveUploads(root string, opts UploadOptions) ([]*UploadedFile, url.Values, error) {
+	name := opts.Name
+	if name == nil {
+		name = randomUploadName
+	}
+	var saved []*UploadedFile
+	fields, err := c.StreamUpload(opts, func(part *UploadPart) error {
+		dst, err := saveStream(part, root, name(part))
+		if err != nil {
+			return err
+		}
+		saved = append(saved, &UploadedFile{
+			Field:       part.Field,
+			Filename:    part.Filename,
+			Path:        dst,
+			ContentType: part.ContentType,
+			Size:        part.Size(),
+			Sum:         part.Sum(),
+		})
+		return nil
+	})
+	return saved, fields, err
+}
+
+// randomUploadName 生成随机的文件名，保留客户端文件名中的扩展名
+func randomUploadName(part *UploadPart) string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b) + strings.ToLower(filepath.Ext(part.Filename))
+}
+
+// SaveUploadedFileSafe 把通过FormFile得到的文件保存为root目录下的name，返回保存的位置。
+// 和SaveUploadedFile不同，name只能是root之下的相对路径，含有..、绝对路径或者经过符号链接逃出root时返回ErrUnsafeFilePath，
+// name已经存在时返回ErrFileExists
+func (c *Context) SaveUploadedFileSafe(file *multipart.FileHeader, root, name string) (string, error) {
+	src, err := file.Open()
+	if err != nil {
+		return "", err
+	}
+	defer src.Close()
+	return saveStream(src, root, name)
+}
+
+// saveStream 把r的内容写入root下的name，先写临时文件再硬链接到最终的位置，
+// 和重命名不同，目标已经存在时链接会失败，不会覆盖别人的文件
+func saveStream(r io.Reader, root, name string) (string, error) {
+	dst, err := safeJoin(root, name)
+	if err != nil {
+		return "", err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(dst), ".upload-*")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp.Name()) //链接成功之后删除临时的名字，失败时删除不完整的文件
+	if _, err = io.Copy(tmp, r); err != nil {
+		_ = tmp.Close()
+		return "", err
+	}
+	if err = tmp.Close(); err != nil {
+		return "", err
+	}
+	if err = os.Link(tmp.Name(), dst); err != nil {
+		if errors.Is(err, fs.ErrExist) {
+			return "", ErrFileExists.WithDetail(fmt.Sprintf("%q already exists", name))
+		}
+		return "", err
+	}
+	return dst, nil
+}
+
+// safeJoin 把name拼接到root下并创建需要的目录，name必须是不含..的相对路径，
+// 已经存在的上级目录解析符号链接之后也必须仍然在root之内
+func safeJoin(root, name string) (string, error) {
+	name = filepath.FromSlash(name)
+	if name == "" || strings.ContainsRune(name, 0) || !filepath.IsLocal(name) {
+		return "", ErrUnsafeFilePath.WithDetail(fmt.Sprintf("%q is not a local path", name))
+	}
+	if err := os.MkdirAll(root, 0o755); err != nil {
+		return "", err
+	}
+	realRoot, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		return "", err
+	}
+	dst := filepath.Join(root, name)
+	//先检查已经存在的最深的上级目录，再创建剩下的目录，避免通过符号链接在root之外创建目录
+	existing := filepath.Dir(dst)
+	for {
+		if _, err := os.Lstat(existing); err == nil {
+			break
+		}
+		existing = filepath.Dir(existing)
+	}
+	realDir, err := filepath.EvalSymlinks(existing)
+	if err != nil {
+		return "", err
+	}
+	if rel, err := filepath.Rel(realRoot, realDir); err != nil || !filepath.IsLocal(rel) {
+		return "", ErrUnsafeFilePath.WithDetail(fmt.Sprintf("%q escapes the upload directory", name))
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+		return "", err
+	}
+	if fi, err := os.Lstat(dst); err == nil && fi.Mode()&os.ModeSymlink != 0 {
+		return "", ErrUnsafeFilePath.WithDetail(fmt.Sprintf("%q is a symbolic link", name))
+	}
+	return dst, nil
+}
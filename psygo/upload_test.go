@@ -0,0 +1,258 @@
+package psygo
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// multipartFile 是multipartRequest中的一个文件
+type multipartFile struct {
+	field, filename string
+	content         []byte
+}
+
+// multipartRequest 构造一个multipart/form-data请求，fields按照 名字, 值 成对传入
+func multipartRequest(t *testing.T, h http.Handler, target string, files []multipartFile, fields ...string) *httptest.ResponseRecorder {
+	t.Helper()
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	for i := 0; i+1 < len(fields); i += 2 {
+		if err := mw.WriteField(fields[i], fields[i+1]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, f := range files {
+		w, err := mw.CreateFormFile(f.field, f.filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, _ = w.Write(f.content)
+	}
+	if err := mw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return performRequest(h, http.MethodPost, target, &body, "Content-Type", mw.FormDataContentType())
+}
+
+// problemCode 返回problem+json响应中的code
+func problemCode(w *httptest.ResponseRecorder) string {
+	var problem struct {
+		Code string `json:"code"`
+	}
+	_ = json.Unmarshal(w.Body.Bytes(), &problem)
+	return problem.Code
+}
+
+func TestStreamUpload(t *testing.T) {
+	root := t.TempDir()
+	png := append([]byte("\x89PNG\r\n\x1a\n"), make([]byte, 600)...)
+	engine := New()
+	var progress []int64
+	engine.POST("/upload", func(c *Context) {
+		files, fields, err := c.SaveUploads(filepath.Join(root, "videos"), UploadOptions{
+			MaxFileSize:  1024,
+			MaxFiles:     2,
+			AllowedExts:  []string{".PNG"},
+			AllowedTypes: []string{"image/*"},
+			Hash:         sha256.New,
+			OnProgress: func(part *UploadPart, written int64) {
+				progress = append(progress, written)
+			},
+		})
+		if err != nil {
+			c.Problem(err)
+			return
+		}
+		c.JSON(http.StatusOK, H{"files": files, "title": fields.Get("title")})
+	})
+
+	w := multipartRequest(t, engine, "/upload", []multipartFile{{"file", "a.png", png}}, "title", "cover")
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, body = %s", w.Code, w.Body.String())
+	}
+	var resp struct {
+		Title string          `json:"title"`
+		Files []*UploadedFile `json:"files"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil || resp.Title != "cover" || len(resp.Files) != 1 {
+		t.Fatalf("body = %s", w.Body.String())
+	}
+	sum := sha256.Sum256(png)
+	file := resp.Files[0]
+	if file.Filename != "a.png" || file.ContentType != "image/png" || file.Size != int64(len(png)) || file.Sum != hex.EncodeToString(sum[:]) {
+		t.Fatalf("file = %+v", file)
+	}
+	//默认使用随机的文件名，只保留扩展名
+	if filepath.Dir(file.Path) != filepath.Join(root, "videos") || filepath.Base(file.Path) == "a.png" || filepath.Ext(file.Path) != ".png" {
+		t.Fatalf("saved path = %s", file.Path)
+	}
+	if saved, err := os.ReadFile(file.Path); err != nil || len(saved) != len(png) {
+		t.Fatalf("saved file: %v", err)
+	}
+	if len(progress) == 0 || progress[len(progress)-1] != int64(len(png)) {
+		t.Fatalf("progress = %v", progress)
+	}
+
+	tests := []struct {
+		filename string
+		content  []byte
+		status   int
+		code     string
+	}{
+		{"b.png", make([]byte, 2048), http.StatusUnsupportedMediaType, "upload.type_not_allowed"},
+		{"c.png", append(png, make([]byte, 1024)...), http.StatusRequestEntityTooLarge, "upload.file_too_large"},
+		{"d.gif", png, http.StatusUnsupportedMediaType, "upload.type_not_allowed"},
+	}
+	for _, tt := range tests {
+		w := multipartRequest(t, engine, "/upload", []multipartFile{{"file", tt.filename, tt.content}})
+		if w.Code != tt.status || problemCode(w) != tt.code {
+			t.Fatalf("%s: status = %d, body = %s", tt.filename, w.Code, w.Body.String())
+		}
+	}
+	//被拒绝的上传不会留下文件
+	entries, _ := os.ReadDir(filepath.Join(root, "videos"))
+	if len(entries) != 1 {
+		t.Fatalf("upload directory has %d entries, want 1", len(entries))
+	}
+}
+
+func TestSaveUploadsDoesNotOverwrite(t *testing.T) {
+	root := t.TempDir()
+	engine := New()
+	engine.POST("/random", func(c *Context) {
+		files, _, err := c.SaveUploads(root, UploadOptions{})
+		if err != nil {
+			c.Problem(err)
+			return
+		}
+		c.String(http.StatusOK, "%s", files[0].Path)
+	})
+	engine.POST("/named", func(c *Context) {
+		_, _, err := c.SaveUploads(root, UploadOptions{
+			Name: func(part *UploadPart) string {
+				return "users/" + part.Filename
+			},
+		})
+		if err != nil {
+			c.Problem(err)
+			return
+		}
+		c.Status(http.StatusOK)
+	})
+
+	//两个用户上传同名的文件，各自保存为不同的文件
+	first := multipartRequest(t, engine, "/random", []multipartFile{{"file", "video.mp4", []byte("first")}})
+	second := multipartRequest(t, engine, "/random", []multipartFile{{"file", "video.mp4", []byte("second")}})
+	if first.Code != http.StatusOK || second.Code != http.StatusOK || first.Body.String() == second.Body.String() {
+		t.Fatalf("paths = %q, %q", first.Body.String(), second.Body.String())
+	}
+	for w, want := range map[*httptest.ResponseRecorder]string{first: "first", second: "second"} {
+		if data, _ := os.ReadFile(w.Body.String()); string(data) != want {
+			t.Fatalf("%s = %q, want %q", w.Body.String(), data, want)
+		}
+	}
+
+	if w := multipartRequest(t, engine, "/named", []multipartFile{{"file", "a.txt", []byte("mine")}}); w.Code != http.StatusOK {
+		t.Fatalf("status = %d, body = %s", w.Code, w.Body.String())
+	}
+	w := multipartRequest(t, engine, "/named", []multipartFile{{"file", "a.txt", []byte("theirs")}})
+	if w.Code != http.StatusConflict || problemCode(w) != "upload.file_exists" {
+		t.Fatalf("status = %d, body = %s", w.Code, w.Body.String())
+	}
+	if data, _ := os.ReadFile(filepath.Join(root, "users", "a.txt")); string(data) != "mine" {
+		t.Fatalf("existing file was overwritten: %q", data)
+	}
+	entries, _ := os.ReadDir(filepath.Join(root, "users"))
+	if len(entries) != 1 {
+		t.Fatalf("temporary files left behind: %d entries", len(entries))
+	}
+}
+
+func TestSaveUploadedFileSafe(t *testing.T) {
+	root := t.TempDir()
+	outside := t.TempDir()
+	if err := os.Symlink(outside, filepath.Join(root, "link")); err != nil {
+		t.Fatal(err)
+	}
+	engine := New()
+	engine.POST("/upload", func(c *Context) {
+		file, err := c.FormFile("file")
+		if err != nil {
+			c.AbortWithStatus(http.StatusBadRequest)
+			return
+		}
+		name, _ := c.GetPostForm("name")
+		dst, err := c.SaveUploadedFileSafe(file, root, name)
+		if err != nil {
+			c.Problem(err)
+			return
+		}
+		c.String(http.StatusOK, "%s", dst)
+	})
+	upload := func(name string) *httptest.ResponseRecorder {
+		return multipartRequest(t, engine, "/upload", []multipartFile{{"file", "a.txt", []byte("hello")}}, "name", name)
+	}
+
+	if w := upload("docs/a.txt"); w.Code != http.StatusOK || w.Body.String() != filepath.Join(root, "docs", "a.txt") {
+		t.Fatalf("status = %d, body = %s", w.Code, w.Body.String())
+	}
+	if w := upload("docs/a.txt"); w.Code != http.StatusConflict {
+		t.Fatalf("overwrite: status = %d, body = %s", w.Code, w.Body.String())
+	}
+	for _, name := range []string{"../a.txt", "docs/../../a.txt", "/etc/a.txt", "link/a.txt", "link/new/a.txt", ""} {
+		if w := upload(name); w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "upload.unsafe_path") {
+			t.Fatalf("%q: status = %d, body = %s", name, w.Code, w.Body.String())
+		}
+	}
+	if entries, _ := os.ReadDir(outside); len(entries) != 0 {
+		t.Fatal("nothing should be written outside the root")
+	}
+}
+
+func TestStreamUploadRequestErrors(t *testing.T) {
+	engine := New()
+	engine.MaxBodySize = 1 << 10
+	engine.POST("/upload", func(c *Context) {
+		if _, _, err := c.SaveUploads(t.TempDir(), UploadOptions{}); err != nil {
+			c.Problem(err)
+			return
+		}
+		c.Status(http.StatusOK)
+	})
+
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		status      int
+		code        string
+	}{
+		{"not multipart", "application/json", `{"name":"a"}`, http.StatusBadRequest, "upload.malformed"},
+		{"no boundary", "multipart/form-data", "--x\r\n", http.StatusBadRequest, "upload.malformed"},
+		{"bad part", "multipart/form-data; boundary=x", "garbage without a boundary", http.StatusBadRequest, "upload.malformed"},
+	}
+	for _, tt := range tests {
+		w := performRequest(engine, http.MethodPost, "/upload", strings.NewReader(tt.body), "Content-Type", tt.contentType)
+		if w.Code != tt.status || problemCode(w) != tt.code {
+			t.Fatalf("%s: status = %d, body = %s", tt.name, w.Code, w.Body.String())
+		}
+	}
+
+	//超出MaxBodySize的文件和普通字段都返回413
+	big := bytes.Repeat([]byte("a"), 4<<10)
+	if w := multipartRequest(t, engine, "/upload", []multipartFile{{"file", "a.txt", big}}); w.Code != http.StatusRequestEntityTooLarge || problemCode(w) != "upload.too_large" {
+		t.Fatalf("file over limit: status = %d, body = %s", w.Code, w.Body.String())
+	}
+	if w := multipartRequest(t, engine, "/upload", nil, "title", string(big)); w.Code != http.StatusRequestEntityTooLarge || problemCode(w) != "upload.too_large" {
+		t.Fatalf("field over limit: status = %d, body = %s", w.Code, w.Body.String())
+	}
+}
@@ -1,16 +1,5 @@
 package psygo
 
-import "unicode"
-
-func isASCII(s string) bool {
-	for i := 0; i < len(s); i++ {
-		if s[i] > unicode.MaxASCII {
-			return false
-		}
-	}
-	return true
-}
-
 func filterFlags(content string) string {
 	for i, char := range content {
 		if char == ' ' || char == ';' {